@@ -0,0 +1,258 @@
+package connections
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// NewConnectionsCommand creates the "connections" command group for managing the named
+// connections of an application, stored in ~/.config/<appName>/connections.yaml.
+// The connections can then be used with the --connection flag of the sql-connection section,
+// provided sql.ConnectionsAppName is set to the same application name.
+func NewConnectionsCommand(appName string) (*cobra.Command, error) {
+	cobraCmd := &cobra.Command{
+		Use:   "connections",
+		Short: fmt.Sprintf("Manage %s database connections", appName),
+	}
+
+	var connectionsFile string
+	cobraCmd.PersistentFlags().StringVar(&connectionsFile, "connections-file", "",
+		fmt.Sprintf("Connections file (default ~/.config/%s/connections.yaml)", appName))
+
+	getEditor := func() (*sql.ConnectionsEditor, error) {
+		path := connectionsFile
+		if path == "" {
+			var err error
+			path, err = sql.GetConnectionsPathForApp(appName)
+			if err != nil {
+				return nil, fmt.Errorf("could not get connections path for %s: %w", appName, err)
+			}
+		}
+
+		log.Debug().Str("connections_path", path).Msg("using connections file")
+		return sql.NewConnectionsEditor(path)
+	}
+
+	cobraCmd.AddCommand(newAddCommand(getEditor))
+	cobraCmd.AddCommand(newListCommand(getEditor))
+	cobraCmd.AddCommand(newGetCommand(getEditor))
+	cobraCmd.AddCommand(newRemoveCommand(getEditor))
+	cobraCmd.AddCommand(newTestCommand(getEditor))
+
+	return cobraCmd, nil
+}
+
+// --- Subcommand implementations --- //
+
+func newAddCommand(getEditor func() (*sql.ConnectionsEditor, error)) *cobra.Command {
+	connection := &sql.Connection{}
+	var dsnOptions []string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add a connection",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			editor, err := getEditor()
+			if err != nil {
+				return err
+			}
+
+			name := args[0]
+			if editor.HasConnection(name) && !force {
+				return fmt.Errorf("connection '%s' already exists, use --force to replace it", name)
+			}
+
+			for _, option := range dsnOptions {
+				key, value, ok := strings.Cut(option, ":")
+				if !ok || key == "" {
+					return fmt.Errorf("invalid dsn option '%s', expected key:value", option)
+				}
+				if connection.DSNOptions == nil {
+					connection.DSNOptions = map[string]string{}
+				}
+				connection.DSNOptions[key] = value
+			}
+
+			if connection.DSN == "" && connection.Type == "" {
+				return fmt.Errorf("either --db-type or --dsn is required")
+			}
+
+			if err := editor.SetConnection(name, connection); err != nil {
+				return err
+			}
+			if err := editor.Save(); err != nil {
+				return fmt.Errorf("failed to save connections: %w", err)
+			}
+
+			fmt.Printf("Saved connection '%s' to %s\n", name, editor.Path())
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&connection.Type, "db-type", "t", "", "Database type (mysql, pgx, sqlite3, duckdb)")
+	flags.StringVarP(&connection.Host, "host", "H", "", "Database host")
+	flags.IntVarP(&connection.Port, "port", "P", 0, "Database port (default: the port of the database type)")
+	flags.StringVarP(&connection.User, "user", "u", "", "Database user")
+	flags.StringVarP(&connection.Password, "password", "p", "", "Database password")
+	flags.StringVarP(&connection.Database, "database", "D", "", "Database name")
+	flags.StringVarP(&connection.Schema, "schema", "s", "", "Database schema (when applicable)")
+	flags.StringVar(&connection.DSN, "dsn", "", "Database DSN")
+	flags.StringVar(&connection.Driver, "driver", "", "Database driver")
+	flags.BoolVar(&connection.SSLDisable, "ssl-disable", false, "Disable SSL/TLS when connecting")
+	flags.BoolVar(&connection.ReadOnly, "read-only", false, "Open the connection in read-only mode")
+	flags.StringSliceVar(&dsnOptions, "dsn-options", nil, "Additional driver parameters as key:value pairs")
+	flags.BoolVarP(&force, "force", "f", false, "Replace an existing connection")
+
+	return cmd
+}
+
+func newListCommand(getEditor func() (*sql.ConnectionsEditor, error)) *cobra.Command {
+	var concise bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List all connections",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			editor, err := getEditor()
+			if err != nil {
+				return err
+			}
+
+			names, err := editor.ListConnections()
+			if err != nil {
+				return fmt.Errorf("failed to list connections: %w", err)
+			}
+			if len(names) == 0 {
+				fmt.Println("No connections defined.")
+				return nil
+			}
+
+			for _, name := range names {
+				if concise {
+					fmt.Println(name)
+					continue
+				}
+				connection, err := editor.GetConnection(name)
+				if err != nil {
+					return err
+				}
+				config := connection.ToDatabaseConfig()
+				fmt.Printf("%s: %s (%s)\n", name, config.ToString(), connectionType(connection))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&concise, "concise", "c", false, "Only show connection names")
+	return cmd
+}
+
+func connectionType(connection *sql.Connection) string {
+	if connection.DSN != "" && connection.Driver != "" {
+		return connection.Driver
+	}
+	if connection.DSN != "" {
+		return "dsn"
+	}
+	return connection.Type
+}
+
+func newGetCommand(getEditor func() (*sql.ConnectionsEditor, error)) *cobra.Command {
+	var showPassword bool
+	cmd := &cobra.Command{
+		Use:   "get <name>",
+		Short: "Show a connection",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			editor, err := getEditor()
+			if err != nil {
+				return err
+			}
+
+			connection, err := editor.GetConnection(args[0])
+			if err != nil {
+				return err
+			}
+			// secret references (env:, file:, cmd:) don't contain the password
+			if connection.Password != "" && !showPassword && !sql.IsSecretReference(connection.Password) {
+				connection.Password = "****"
+			}
+
+			encoder := yaml.NewEncoder(os.Stdout)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(connection); err != nil {
+				return fmt.Errorf("failed to print connection: %w", err)
+			}
+			return encoder.Close()
+		},
+	}
+
+	cmd.Flags().BoolVar(&showPassword, "show-password", false, "Show the password instead of masking it")
+	return cmd
+}
+
+func newRemoveCommand(getEditor func() (*sql.ConnectionsEditor, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a connection",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			editor, err := getEditor()
+			if err != nil {
+				return err
+			}
+
+			name := args[0]
+			if err := editor.RemoveConnection(name); err != nil {
+				return err
+			}
+			if err := editor.Save(); err != nil {
+				return fmt.Errorf("failed to save connections: %w", err)
+			}
+
+			fmt.Printf("Removed connection '%s'\n", name)
+			return nil
+		},
+	}
+}
+
+func newTestCommand(getEditor func() (*sql.ConnectionsEditor, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   "test <name>",
+		Short: "Check that a connection can be opened",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			editor, err := getEditor()
+			if err != nil {
+				return err
+			}
+
+			name := args[0]
+			connection, err := editor.GetConnection(name)
+			if err != nil {
+				return err
+			}
+
+			start := time.Now()
+			db, err := connection.ToDatabaseConfig().Connect(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("connection '%s' failed: %w", name, err)
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			fmt.Printf("Connection '%s' OK (%s)\n", name, time.Since(start).Round(time.Millisecond))
+			return nil
+		},
+	}
+}
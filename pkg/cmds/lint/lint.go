@@ -0,0 +1,209 @@
+package lint
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/go-go-golems/clay/pkg/repositories"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cli"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+	"github.com/go-go-golems/glazed/pkg/cmds/schema"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// QueryTemplateCommand is implemented by commands running a SQL query template, so that
+// the lint command can check them. Commands that don't implement it are checked if the
+// YAML they were loaded from (see repositories.CommandWithSource) or their ToYAML output
+// has query and subqueries keys.
+type QueryTemplateCommand interface {
+	QueryTemplate() (query string, subQueries map[string]string)
+}
+
+// LintCommand checks the SQL query templates of the commands of a repository.
+type LintCommand struct {
+	*glazed_cmds.CommandDescription
+	commands []glazed_cmds.Command
+}
+
+var _ glazed_cmds.GlazeCommand = (*LintCommand)(nil)
+
+// LintSettings holds the parameters of the lint command.
+type LintSettings struct {
+	Commands []string `glazed:"commands"`
+	Dialect  string   `glazed:"dialect"`
+}
+
+// NewLintCommand creates the "lint" command, reporting the undefined parameters, missing
+// sub-queries, unused flags and invalid SQL of the query templates of allCommands.
+func NewLintCommand(allCommands []glazed_cmds.Command) (*cobra.Command, error) {
+	lintCmd, err := newLintCommand(allCommands)
+	if err != nil {
+		return nil, err
+	}
+	return cli.BuildCobraCommand(lintCmd)
+}
+
+func newLintCommand(allCommands []glazed_cmds.Command) (*LintCommand, error) {
+	glazedSection, err := settings.NewGlazedSection()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed section")
+	}
+
+	return &LintCommand{
+		commands: allCommands,
+		CommandDescription: glazed_cmds.NewCommandDescription(
+			"lint",
+			glazed_cmds.WithShort("Check the SQL query templates of the commands"),
+			glazed_cmds.WithLong(`Reports the parameters used by the query templates that aren't flags of their command, the sub-queries that aren't defined, the flags and sub-queries that aren't used, and the queries that don't render to a single valid SQL statement.`),
+			glazed_cmds.WithFlags(
+				fields.New(
+					"dialect",
+					fields.TypeChoice,
+					fields.WithHelp("SQL dialect to check the rendered queries against"),
+					fields.WithChoices("ansi", "mysql", "postgres", "sqlite", "duckdb"),
+					fields.WithDefault("ansi"),
+				),
+			),
+			glazed_cmds.WithArguments(
+				fields.New(
+					"commands",
+					fields.TypeStringList,
+					fields.WithHelp("Full paths of the commands to check, or of their parents (default: all)"),
+				),
+			),
+			glazed_cmds.WithSections(glazedSection),
+		),
+	}, nil
+}
+
+// RunIntoGlazeProcessor outputs a row per issue found.
+func (c *LintCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedValues *values.Values,
+	gp middlewares.Processor,
+) error {
+	s := &LintSettings{}
+	if err := parsedValues.DecodeSectionInto(schema.DefaultSlug, s); err != nil {
+		return errors.Wrap(err, "failed to initialize settings")
+	}
+	dialect := sql.DialectForDriver(s.Dialect)
+
+	for _, cmd := range c.commands {
+		description := cmd.Description()
+		path := description.FullPath()
+		if !matchesCommandPaths(path, s.Commands) {
+			continue
+		}
+
+		var issues []sql.LintIssue
+		template, ok, err := getQueryTemplate(cmd)
+		switch {
+		case err != nil:
+			issues = []sql.LintIssue{{
+				Severity: sql.LintSeverityError,
+				Kind:     "source-error",
+				Message:  err.Error(),
+			}}
+		case !ok:
+			issues = []sql.LintIssue{{
+				Severity: sql.LintSeverityInfo,
+				Kind:     "not-checked",
+				Message:  "the command has no SQL query template that can be checked",
+			}}
+		default:
+			issues = sql.LintQueryTemplate(ctx, template.description, template.query, template.subQueries,
+				sql.WithLintDialect(dialect))
+		}
+		for _, issue := range issues {
+			row := types.NewRow(
+				types.MRP("command", path),
+				types.MRP("source", description.Source),
+			)
+			for pair := issue.ToRow().Oldest(); pair != nil; pair = pair.Next() {
+				row.Set(pair.Key, pair.Value)
+			}
+			if err := gp.AddRow(ctx, row); err != nil {
+				return errors.Wrapf(err, "could not add row for command '%s'", path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchesCommandPaths returns true if paths is empty, or path is one of paths or below it.
+// The paths are separated by / as returned by FullPath, or by spaces as on the command line.
+func matchesCommandPaths(path string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		p = strings.TrimSuffix(strings.Join(strings.Fields(p), "/"), "/")
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// queryTemplate is the query of a command, along with the description declaring its fields.
+type queryTemplate struct {
+	query       string
+	subQueries  map[string]string
+	description *glazed_cmds.CommandDescription
+}
+
+// getQueryTemplate returns the query template and sub-queries of cmd, from its
+// QueryTemplate method, from the YAML it was loaded from, or from its ToYAML output.
+// Commands loaded as YAML without a schema (for example RawCommands) are checked
+// against the flags and arguments declared in the YAML.
+func getQueryTemplate(cmd glazed_cmds.Command) (*queryTemplate, bool, error) {
+	description := cmd.Description()
+	if c, ok := cmd.(QueryTemplateCommand); ok {
+		query, subQueries := c.QueryTemplate()
+		return &queryTemplate{query: query, subQueries: subQueries, description: description}, query != "", nil
+	}
+
+	var source []byte
+	if c, ok := cmd.(repositories.CommandWithSource); ok {
+		source = c.SourceContent()
+	}
+	if len(source) == 0 {
+		buf := &bytes.Buffer{}
+		if err := cmd.ToYAML(buf); err != nil {
+			return nil, false, errors.Wrap(err, "could not serialize the command")
+		}
+		source = buf.Bytes()
+	}
+
+	content := struct {
+		Query      string               `yaml:"query"`
+		SubQueries map[string]string    `yaml:"subqueries"`
+		Flags      []*fields.Definition `yaml:"flags"`
+		Arguments  []*fields.Definition `yaml:"arguments"`
+	}{}
+	if err := yaml.Unmarshal(source, &content); err != nil {
+		return nil, false, errors.Wrap(err, "could not parse the YAML of the command")
+	}
+	if content.Query == "" {
+		return nil, false, nil
+	}
+
+	if description.Schema == nil {
+		description = glazed_cmds.NewCommandDescription(description.Name,
+			glazed_cmds.WithParents(description.Parents...),
+			glazed_cmds.WithFlags(content.Flags...),
+			glazed_cmds.WithArguments(content.Arguments...),
+		)
+	}
+	return &queryTemplate{query: content.Query, subQueries: content.SubQueries, description: description}, true, nil
+}
@@ -0,0 +1,112 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	clay_cmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories"
+	"github.com/go-go-golems/glazed/pkg/cmds/runner"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/go-go-golems/glazed/pkg/types"
+)
+
+func TestMatchesCommandPaths(t *testing.T) {
+	tests := []struct {
+		path  string
+		paths []string
+		want  bool
+	}{
+		{"foo/bar", nil, true},
+		{"foo/bar", []string{"foo/bar"}, true},
+		{"foo/bar", []string{"foo"}, true},
+		{"foo/bar", []string{"foo/"}, true},
+		{"foo/bar/baz", []string{"foo bar"}, true},
+		{"foo/bar", []string{"other", "foo"}, true},
+		{"foobar/baz", []string{"foo"}, false},
+		{"foo", []string{"foo/bar"}, false},
+		{"other/bar", []string{"foo"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchesCommandPaths(tt.path, tt.paths); got != tt.want {
+			t.Errorf("matchesCommandPaths(%q, %v) = %v, want %v", tt.path, tt.paths, got, tt.want)
+		}
+	}
+}
+
+const testLintQueryYAML = `name: ls
+short: List things
+flags:
+  - name: limit
+    type: int
+query: |
+  SELECT * FROM things WHERE name = {{ .name | sqlString }} LIMIT {{ .limit }}
+`
+
+const testLintShellYAML = `name: hello
+short: Say hello
+`
+
+type lintRowCollector struct {
+	rows []types.Row
+}
+
+func (c *lintRowCollector) AddRow(ctx context.Context, row types.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *lintRowCollector) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestLintCommandChecksLoadedCommands(t *testing.T) {
+	r := repositories.NewRepository(
+		repositories.WithCommandLoader(clay_cmds.NewRawCommandLoader()),
+		repositories.WithDirectories(repositories.Directory{
+			FS: fstest.MapFS{
+				"queries/things/ls.yaml":  {Data: []byte(testLintQueryYAML)},
+				"queries/misc/hello.yaml": {Data: []byte(testLintShellYAML)},
+			},
+			RootDirectory: "queries",
+			Name:          "test",
+		}),
+	)
+	if err := r.LoadCommands(help.NewHelpSystem()); err != nil {
+		t.Fatalf("could not load commands: %v", err)
+	}
+
+	lintCmd, err := newLintCommand(r.CollectCommands([]string{}, true))
+	if err != nil {
+		t.Fatalf("could not create lint command: %v", err)
+	}
+	parsedValues, err := runner.ParseCommandValues(lintCmd)
+	if err != nil {
+		t.Fatalf("could not parse values: %v", err)
+	}
+
+	c := &lintRowCollector{}
+	if err := lintCmd.RunIntoGlazeProcessor(context.Background(), parsedValues, c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, row := range c.rows {
+		command, _ := row.Get("command")
+		kind, _ := row.Get("kind")
+		name, _ := row.Get("name")
+		found[fmt.Sprintf("%s %s %s", command, kind, name)] = true
+	}
+	if !found["things/ls undefined-parameter name"] {
+		t.Errorf("expected an undefined-parameter issue for name, got %v", found)
+	}
+	// limit is declared in the YAML of the command
+	if found["things/ls undefined-parameter limit"] {
+		t.Errorf("expected limit to be declared, got %v", found)
+	}
+	if !found["misc/hello not-checked "] {
+		t.Errorf("expected hello to be reported as not checked, got %v", found)
+	}
+}
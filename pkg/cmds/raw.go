@@ -26,6 +26,11 @@ func (r *RawCommand) ToYAML(w io.Writer) error {
 	return yaml.NewEncoder(w).Encode(r.YAMLContent)
 }
 
+// SourceContent returns the original YAML the command was loaded from.
+func (r *RawCommand) SourceContent() []byte {
+	return r.Content
+}
+
 func (r *RawCommandLoader) LoadCommands(
 	f fs.FS, entryName string,
 	options []cmds.CommandDescriptionOption,
@@ -1,9 +1,8 @@
 package builder
 
 import (
-	"fmt"
-
 	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/rs/zerolog/log"
 )
 
 // FilterBuilder provides methods for building and combining filters
@@ -30,7 +29,7 @@ func (f *FilterBuilder) And(others ...*FilterBuilder) *FilterBuilder {
 	for i, other := range others {
 		queries[i+1] = other.query
 	}
-	fmt.Printf("Creating conjunction query with %d queries\n", len(queries))
+	log.Debug().Int("queries", len(queries)).Msg("Creating conjunction query")
 	return NewFilterBuilder(
 		query.NewConjunctionQuery(queries),
 		f.opts,
@@ -44,7 +43,7 @@ func (f *FilterBuilder) Or(others ...*FilterBuilder) *FilterBuilder {
 	for i, other := range others {
 		queries[i+1] = other.query
 	}
-	fmt.Printf("Creating disjunction query with %d queries\n", len(queries))
+	log.Debug().Int("queries", len(queries)).Msg("Creating disjunction query")
 	return NewFilterBuilder(
 		query.NewDisjunctionQuery(queries),
 		f.opts,
@@ -11,19 +11,19 @@ import (
 // FilterSettings contains all the filter parameters used by the list command.
 // These correspond to the filter methods provided by the Builder.
 type FilterSettings struct {
-	Type          string   `glazed:"type" help:"Filter by command type"`
-	Types         []string `glazed:"types" help:"Filter by multiple types (OR)"`
-	Tag           string   `glazed:"tag" help:"Filter by single tag"`
-	Tags          []string `glazed:"tags" help:"Filter by any of multiple tags (OR)"`
-	AllTags       []string `glazed:"all-tags" help:"Must have all specified tags (AND)"`
-	AnyTags       []string `glazed:"any-tags" help:"Must have any of specified tags (OR) (alias for --tags)"`
-	Path          string   `glazed:"path" help:"Exact path match (e.g., 'queries es')"`
-	PathGlob      string   `glazed:"path-glob" help:"Path glob pattern (e.g., 'queries/*')"`
-	PathPrefix    string   `glazed:"path-prefix" help:"Path prefix match (e.g., 'queries/')"`
-	Name          string   `glazed:"name" help:"Exact command name match (last part of path)"`
-	NamePattern   string   `glazed:"name-pattern" help:"Command name pattern match (e.g., 'list*')"`
-	MetadataKey   string   `glazed:"metadata-key" help:"Metadata key to match"`
-	MetadataValue string   `glazed:"metadata-value" help:"Metadata value to match (requires --metadata-key)"`
+	Type          string   `glazed:"type" yaml:"type,omitempty" help:"Filter by command type"`
+	Types         []string `glazed:"types" yaml:"types,omitempty" help:"Filter by multiple types (OR)"`
+	Tag           string   `glazed:"tag" yaml:"tag,omitempty" help:"Filter by single tag"`
+	Tags          []string `glazed:"tags" yaml:"tags,omitempty" help:"Filter by any of multiple tags (OR)"`
+	AllTags       []string `glazed:"all-tags" yaml:"all-tags,omitempty" help:"Must have all specified tags (AND)"`
+	AnyTags       []string `glazed:"any-tags" yaml:"any-tags,omitempty" help:"Must have any of specified tags (OR) (alias for --tags)"`
+	Path          string   `glazed:"path" yaml:"path,omitempty" help:"Exact path match (e.g., 'queries es')"`
+	PathGlob      string   `glazed:"path-glob" yaml:"path-glob,omitempty" help:"Path glob pattern (e.g., 'queries/*')"`
+	PathPrefix    string   `glazed:"path-prefix" yaml:"path-prefix,omitempty" help:"Path prefix match (e.g., 'queries/')"`
+	Name          string   `glazed:"name" yaml:"name,omitempty" help:"Exact command name match (last part of path)"`
+	NamePattern   string   `glazed:"name-pattern" yaml:"name-pattern,omitempty" help:"Command name pattern match (e.g., 'list*')"`
+	MetadataKey   string   `glazed:"metadata-key" yaml:"metadata-key,omitempty" help:"Metadata key to match"`
+	MetadataValue string   `glazed:"metadata-value" yaml:"metadata-value,omitempty" help:"Metadata value to match (requires --metadata-key)"`
 }
 
 // FilterSectionSlug is the slug for the filter section.
@@ -73,7 +73,7 @@ func NewCommandIndex(commands []*cmds.CommandDescription) (*CommandIndex, error)
 			}
 			return nil, err
 		}
-		if err := index.Index(cmd.Name, doc); err != nil {
+		if err := index.Index(doc.FullPath, doc); err != nil {
 			if closeErr := index.Close(); closeErr != nil {
 				log.Error().Err(closeErr).Msg("Error closing index after indexing failure")
 			}
@@ -114,7 +114,7 @@ func (ci *CommandIndex) Search(ctx context.Context, filter *builder.FilterBuilde
 	matches := make([]*cmds.CommandDescription, 0, len(searchResult.Hits))
 	for _, hit := range searchResult.Hits {
 		for _, cmd := range commands {
-			if cmd.Name == hit.ID {
+			if cmd.FullPath() == hit.ID {
 				matches = append(matches, cmd)
 				break
 			}
@@ -24,6 +24,7 @@ type MemoCache[H Hasher, T any] struct {
 	cache         map[string]*list.Element // The cache holding the results
 	evictionList  *list.List               // A list to keep track of the order for LRU
 	hashableItems map[string]T             // This map keeps track of the original hashable items (optional)
+	evictions     int                      // Number of items evicted because the cache was full
 }
 
 // NewMemoCache creates a new MemoCache given a certain capacity.
@@ -81,6 +82,7 @@ func (m *MemoCache[H, T]) Set(h H, value T) {
 			evictedEntry := m.evictionList.Remove(toEvict).(*entry[T])
 			delete(m.cache, evictedEntry.key)
 			delete(m.hashableItems, evictedEntry.key) // if you're keeping track of original items
+			m.evictions++
 		}
 	}
 
@@ -94,6 +96,26 @@ func (m *MemoCache[H, T]) Set(h H, value T) {
 	m.hashableItems[hashedKey] = value // if you're keeping track of original items
 }
 
+// Delete removes the value for the hashable item, if present.
+func (m *MemoCache[H, T]) Delete(h H) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	hashedKey := h.Hash()
+	if element, found := m.cache[hashedKey]; found {
+		m.evictionList.Remove(element)
+		delete(m.cache, hashedKey)
+		delete(m.hashableItems, hashedKey)
+	}
+}
+
+// Evictions returns the number of items evicted because the cache was at capacity.
+func (m *MemoCache[H, T]) Evictions() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.evictions
+}
+
 type HString string
 
 func (h HString) Hash() string {
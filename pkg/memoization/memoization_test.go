@@ -239,3 +239,26 @@ func FuzzCache(f *testing.F) {
 		}
 	})
 }
+
+func TestDeleteAndEvictions(t *testing.T) {
+	cache := NewMemoCache[HString, int](2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Fatalf("expected a to be deleted")
+	}
+	cache.Delete("missing")
+	if cache.Size() != 1 || cache.Evictions() != 0 {
+		t.Fatalf("got size %d, evictions %d, want 1, 0", cache.Size(), cache.Evictions())
+	}
+
+	cache.Set("c", 3)
+	cache.Set("d", 4)
+	if cache.Evictions() != 1 {
+		t.Fatalf("expected 1 eviction, got %d", cache.Evictions())
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+}
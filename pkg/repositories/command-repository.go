@@ -2,12 +2,15 @@ package repositories
 
 import (
 	"context"
+	"strings"
+	"sync/atomic"
 
 	"github.com/go-go-golems/clay/pkg/repositories/mcp"
 	"github.com/go-go-golems/clay/pkg/repositories/trie"
 	"github.com/go-go-golems/clay/pkg/watcher"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/pkg/errors"
 )
 
 // CommandRepository is a simple repository that just manages commands in memory.
@@ -15,6 +18,8 @@ import (
 type CommandRepository struct {
 	root *trie.TrieNode
 	name string
+	// changes counts the calls to Add, AddUnderPath and Remove, see ChangeCounter.
+	changes atomic.Uint64
 }
 
 type CommandRepositoryOption func(*CommandRepository)
@@ -45,6 +50,7 @@ func (r *CommandRepository) LoadCommands(_ *help.HelpSystem, _ ...cmds.CommandDe
 
 // Add adds one or more commands to the repository, optionally under a specific path
 func (r *CommandRepository) Add(commands ...cmds.Command) {
+	defer r.changes.Add(1)
 	for _, command := range commands {
 		prefix := command.Description().Parents
 		r.root.InsertCommand(prefix, command)
@@ -53,6 +59,7 @@ func (r *CommandRepository) Add(commands ...cmds.Command) {
 
 // AddUnderPath adds commands under a specific path prefix
 func (r *CommandRepository) AddUnderPath(pathPrefix []string, commands ...cmds.Command) {
+	defer r.changes.Add(1)
 	for _, command := range commands {
 		// Create a new slice to avoid modifying the original command's parents
 		newPrefix := append([]string{}, pathPrefix...)
@@ -63,11 +70,17 @@ func (r *CommandRepository) AddUnderPath(pathPrefix []string, commands ...cmds.C
 
 // Remove removes commands with the given prefixes from the repository
 func (r *CommandRepository) Remove(prefixes ...[]string) {
+	defer r.changes.Add(1)
 	for _, prefix := range prefixes {
 		r.root.Remove(prefix)
 	}
 }
 
+// Changes returns the number of calls to Add, AddUnderPath and Remove.
+func (r *CommandRepository) Changes() uint64 {
+	return r.changes.Load()
+}
+
 // CollectCommands returns all commands under a given prefix
 func (r *CommandRepository) CollectCommands(prefix []string, recurse bool) []cmds.Command {
 	return r.root.CollectCommands(prefix, recurse)
@@ -129,6 +142,29 @@ func (r *CommandRepository) ListTools(ctx context.Context, cursor string) ([]mcp
 	return tools, "", nil
 }
 
+// ListResources returns the source of all commands that keep it as MCP resources
+func (r *CommandRepository) ListResources(ctx context.Context, cursor string) ([]mcp.Resource, string, error) {
+	return CommandsToResources(r.root.CollectCommands([]string{}, true)), "", nil
+}
+
+// ReadResource returns the source of the command referenced by a clay://command/<path> URI.
+// CommandRepository has no help sections.
+func (r *CommandRepository) ReadResource(ctx context.Context, uri string) ([]mcp.ResourceContent, error) {
+	kind, name, err := mcp.ParseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if kind != mcp.ResourceKindCommand {
+		return nil, errors.Wrapf(mcp.ErrResourceNotFound, "%s", uri)
+	}
+
+	cmd, ok := r.root.FindCommand(strings.Split(name, "/"))
+	if !ok {
+		return nil, errors.Wrapf(mcp.ErrResourceNotFound, "command %s", name)
+	}
+	return ReadCommandResource(cmd, uri)
+}
+
 // Watch is a no-op since CommandRepository doesn't support file watching
 func (r *CommandRepository) Watch(ctx context.Context, options ...watcher.Option) error {
 	return nil
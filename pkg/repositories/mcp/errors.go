@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+type ToolCallErrorCode string
+
+const (
+	ToolCallErrorUnknownTool          ToolCallErrorCode = "unknown_tool"
+	ToolCallErrorDenied               ToolCallErrorCode = "denied"
+	ToolCallErrorConfirmationRequired ToolCallErrorCode = "confirmation_required"
+)
+
+// ToolCallError is returned by CallTool when a call is rejected before the tool runs,
+// so that hosts can tell a blocked call apart from a failing one.
+type ToolCallError struct {
+	Tool   string
+	Code   ToolCallErrorCode
+	Reason string
+}
+
+func (e *ToolCallError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("tool %s: %s", e.Tool, e.Code)
+	}
+	return fmt.Sprintf("tool %s: %s: %s", e.Tool, e.Code, e.Reason)
+}
+
+// IsToolCallError returns the ToolCallError wrapped in err, if any.
+func IsToolCallError(err error) (*ToolCallError, bool) {
+	var toolCallError *ToolCallError
+	if errors.As(err, &toolCallError) {
+		return toolCallError, true
+	}
+	return nil, false
+}
+
+type confirmationKey struct{}
+
+// ContextWithConfirmation marks ctx as carrying the user's confirmation for a tool call.
+// Hosts call this after asking the user, before calling a tool with RequiresConfirmation set.
+func ContextWithConfirmation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, confirmationKey{}, true)
+}
+
+// IsConfirmed returns true if the context was created with ContextWithConfirmation.
+func IsConfirmed(ctx context.Context) bool {
+	confirmed, ok := ctx.Value(confirmationKey{}).(bool)
+	return ok && confirmed
+}
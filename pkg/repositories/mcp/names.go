@@ -0,0 +1,267 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MaxToolNameLength is the maximum tool name length accepted by most MCP clients.
+const MaxToolNameLength = 64
+
+// toolNameHashLength is the number of hex characters of the path hash appended
+// to truncated or colliding tool names.
+const toolNameHashLength = 8
+
+// ToolNameEncoder turns a command path (components separated by /) into a tool name
+// that MCP clients accept. Encoders don't need to be reversible on their own,
+// ToolNameMapper keeps track of the mapping and resolves collisions.
+type ToolNameEncoder interface {
+	Encode(path string) string
+}
+
+type ToolNameEncoderFunc func(path string) string
+
+func (f ToolNameEncoderFunc) Encode(path string) string {
+	return f(path)
+}
+
+// DefaultToolNameEncoder strips leading and trailing slashes, replaces path separators
+// with Separator and every other character outside of [a-zA-Z0-9_-] with an underscore.
+// Names longer than MaxLength are truncated and suffixed with a hash of the full path.
+type DefaultToolNameEncoder struct {
+	Separator string
+	MaxLength int
+}
+
+func NewDefaultToolNameEncoder() *DefaultToolNameEncoder {
+	return &DefaultToolNameEncoder{
+		Separator: "__",
+		MaxLength: MaxToolNameLength,
+	}
+}
+
+func (e *DefaultToolNameEncoder) Encode(path string) string {
+	components := strings.Split(strings.Trim(path, "/"), "/")
+	for i, c := range components {
+		components[i] = sanitizeToolName(c)
+	}
+	name := strings.Join(components, e.Separator)
+
+	if e.MaxLength > 0 && len(name) > e.MaxLength {
+		name = withPathHash(name, path, e.MaxLength)
+	}
+
+	return name
+}
+
+func isValidToolNameChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c == '_' || c == '-'
+}
+
+func sanitizeToolName(s string) string {
+	return strings.Map(func(c rune) rune {
+		if isValidToolNameChar(c) {
+			return c
+		}
+		return '_'
+	}, s)
+}
+
+// IsValidToolName returns true if name only contains [a-zA-Z0-9_-] and is
+// at most MaxToolNameLength characters long.
+func IsValidToolName(name string) bool {
+	if name == "" || len(name) > MaxToolNameLength {
+		return false
+	}
+	for _, c := range name {
+		if !isValidToolNameChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func pathHash(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(sum[:])[:toolNameHashLength]
+}
+
+// withPathHash truncates name so that name + "_" + hash(path) fits into maxLength.
+func withPathHash(name string, path string, maxLength int) string {
+	suffix := "_" + pathHash(path)
+	if maxLength > 0 && len(name)+len(suffix) > maxLength {
+		keep := maxLength - len(suffix)
+		if keep < 0 {
+			keep = 0
+		}
+		name = name[:keep]
+	}
+	return name + suffix
+}
+
+// ToolNameMapper is a lookup table between command paths and encoded tool names.
+// When several paths encode to the same name, each of them gets a hash suffix derived
+// from its path, so that every registered name resolves to exactly one path and the
+// names only depend on the set of registered paths, not on the order of registration.
+type ToolNameMapper struct {
+	encoder   ToolNameEncoder
+	maxLength int
+
+	mu         sync.RWMutex
+	nameToPath map[string]string
+	pathToName map[string]string
+}
+
+type ToolNameMapperOption func(*ToolNameMapper)
+
+func WithToolNameEncoder(encoder ToolNameEncoder) ToolNameMapperOption {
+	return func(m *ToolNameMapper) {
+		m.encoder = encoder
+	}
+}
+
+// WithMaxToolNameLength sets the length that names get truncated to when a hash
+// suffix is added to resolve a collision.
+func WithMaxToolNameLength(maxLength int) ToolNameMapperOption {
+	return func(m *ToolNameMapper) {
+		m.maxLength = maxLength
+	}
+}
+
+func NewToolNameMapper(options ...ToolNameMapperOption) *ToolNameMapper {
+	ret := &ToolNameMapper{
+		encoder:    NewDefaultToolNameEncoder(),
+		maxLength:  MaxToolNameLength,
+		nameToPath: map[string]string{},
+		pathToName: map[string]string{},
+	}
+	for _, opt := range options {
+		opt(ret)
+	}
+	return ret
+}
+
+// Register returns the tool name for path, encoding and recording it if it
+// hasn't been registered yet. If the name of path collides with the name of a
+// registered path, both get a hash suffix, so the name previously returned for the
+// other path doesn't resolve anymore.
+func (m *ToolNameMapper) Register(path string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if name, ok := m.pathToName[path]; ok {
+		return name, nil
+	}
+
+	paths := make([]string, 0, len(m.pathToName)+1)
+	for p := range m.pathToName {
+		paths = append(paths, p)
+	}
+	paths = append(paths, path)
+	if err := m.build(paths); err != nil {
+		return "", err
+	}
+	return m.pathToName[path], nil
+}
+
+// Rebuild replaces the lookup table with the names of paths. The table is left
+// unchanged if an error is returned.
+func (m *ToolNameMapper) Rebuild(paths []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.build(paths)
+}
+
+func (m *ToolNameMapper) build(paths []string) error {
+	encoded := map[string][]string{}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		name := m.encoder.Encode(path)
+		if name == "" {
+			return errors.Errorf("could not encode tool name for %s", path)
+		}
+		encoded[name] = append(encoded[name], path)
+	}
+
+	names := make([]string, 0, len(encoded))
+	for name := range encoded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nameToPath := map[string]string{}
+	pathToName := map[string]string{}
+	add := func(name string, path string) error {
+		if existing, ok := nameToPath[name]; ok {
+			return errors.Errorf("tool name %s for %s collides with %s", name, path, existing)
+		}
+		nameToPath[name] = path
+		pathToName[path] = name
+		return nil
+	}
+	for _, name := range names {
+		group := encoded[name]
+		if len(group) == 1 {
+			continue
+		}
+		sort.Strings(group)
+		for _, path := range group {
+			if err := add(withPathHash(name, path, m.maxLength), path); err != nil {
+				return err
+			}
+		}
+	}
+	// the unique names are added last, so that a hashed name taking the name of
+	// another path is reported no matter the order of the paths
+	for _, name := range names {
+		if group := encoded[name]; len(group) == 1 {
+			if err := add(name, group[0]); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.nameToPath = nameToPath
+	m.pathToName = pathToName
+	return nil
+}
+
+// Resolve returns the command path registered for the tool name.
+func (m *ToolNameMapper) Resolve(name string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	path, ok := m.nameToPath[name]
+	return path, ok
+}
+
+// Name returns the tool name registered for path.
+func (m *ToolNameMapper) Name(path string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	name, ok := m.pathToName[path]
+	return name, ok
+}
+
+// Reset clears the lookup table.
+func (m *ToolNameMapper) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nameToPath = map[string]string{}
+	m.pathToName = map[string]string{}
+}
@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultToolNameEncoder(t *testing.T) {
+	encoder := NewDefaultToolNameEncoder()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{name: "simple name", path: "ls", expected: "ls"},
+		{name: "nested path", path: "db/tables/ls", expected: "db__tables__ls"},
+		{name: "leading slash", path: "/mysql/ls", expected: "mysql__ls"},
+		{name: "invalid characters", path: "/my repo/ls.v2", expected: "my_repo__ls_v2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := encoder.Encode(tt.path)
+			assert.Equal(t, tt.expected, name)
+			assert.True(t, IsValidToolName(name))
+		})
+	}
+}
+
+func TestDefaultToolNameEncoderTruncation(t *testing.T) {
+	encoder := NewDefaultToolNameEncoder()
+
+	long1 := "/" + strings.Repeat("a", 50) + "/" + strings.Repeat("b", 50) + "/one"
+	long2 := "/" + strings.Repeat("a", 50) + "/" + strings.Repeat("b", 50) + "/two"
+
+	name1 := encoder.Encode(long1)
+	name2 := encoder.Encode(long2)
+
+	assert.Len(t, name1, MaxToolNameLength)
+	assert.Len(t, name2, MaxToolNameLength)
+	assert.True(t, IsValidToolName(name1))
+	assert.True(t, IsValidToolName(name2))
+	assert.NotEqual(t, name1, name2)
+	// encoding is deterministic
+	assert.Equal(t, name1, encoder.Encode(long1))
+}
+
+func TestToolNameMapper(t *testing.T) {
+	mapper := NewToolNameMapper()
+
+	name1, err := mapper.Register("/db/ls")
+	require.NoError(t, err)
+	assert.Equal(t, "db__ls", name1)
+
+	// a trailing slash encodes to the same name as "/db/ls", so both get a hash suffix
+	name2, err := mapper.Register("db/ls/")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name2, "db__ls_"))
+	assert.True(t, IsValidToolName(name2))
+
+	name1, err = mapper.Register("/db/ls")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name1, "db__ls_"))
+	assert.NotEqual(t, name1, name2)
+
+	_, ok := mapper.Resolve("db__ls")
+	assert.False(t, ok)
+
+	path, ok := mapper.Resolve(name1)
+	require.True(t, ok)
+	assert.Equal(t, "/db/ls", path)
+
+	path, ok = mapper.Resolve(name2)
+	require.True(t, ok)
+	assert.Equal(t, "db/ls/", path)
+
+	_, ok = mapper.Resolve("unknown")
+	assert.False(t, ok)
+
+	mapper.Reset()
+	_, ok = mapper.Resolve(name1)
+	assert.False(t, ok)
+}
+
+func TestToolNameMapperIsDeterministic(t *testing.T) {
+	paths := []string{"/db/ls", "db/ls/", "/db/ps", "/my repo/ls", "/my_repo/ls"}
+
+	mapper1 := NewToolNameMapper()
+	require.NoError(t, mapper1.Rebuild(paths))
+
+	mapper2 := NewToolNameMapper()
+	for i := len(paths) - 1; i >= 0; i-- {
+		_, err := mapper2.Register(paths[i])
+		require.NoError(t, err)
+	}
+
+	for _, path := range paths {
+		name1, ok := mapper1.Name(path)
+		require.True(t, ok, path)
+		name2, ok := mapper2.Name(path)
+		require.True(t, ok, path)
+		assert.Equal(t, name1, name2, path)
+
+		resolved, ok := mapper1.Resolve(name1)
+		require.True(t, ok)
+		assert.Equal(t, path, resolved)
+	}
+
+	name, _ := mapper1.Name("/db/ps")
+	assert.Equal(t, "db__ps", name)
+
+	// rebuilding drops the paths that are gone
+	require.NoError(t, mapper1.Rebuild([]string{"/db/ls"}))
+	name, _ = mapper1.Name("/db/ls")
+	assert.Equal(t, "db__ls", name)
+	_, ok := mapper1.Name("/db/ps")
+	assert.False(t, ok)
+}
+
+func TestToolNameMapperCustomEncoder(t *testing.T) {
+	mapper := NewToolNameMapper(WithToolNameEncoder(ToolNameEncoderFunc(func(path string) string {
+		return "tool"
+	})))
+
+	name1, err := mapper.Register("a")
+	require.NoError(t, err)
+	name2, err := mapper.Register("b")
+	require.NoError(t, err)
+
+	assert.Equal(t, "tool", name1)
+	assert.NotEqual(t, name1, name2)
+
+	path, ok := mapper.Resolve(name2)
+	require.True(t, ok)
+	assert.Equal(t, "b", path)
+}
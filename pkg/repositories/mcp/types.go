@@ -11,11 +11,16 @@ type Tool struct {
 	Name        string
 	Description string
 	InputSchema json.RawMessage
+	// RequiresConfirmation marks tools that the host should only call after
+	// the user confirmed the call, see ContextWithConfirmation.
+	RequiresConfirmation bool
 }
 
 type ToolResult struct {
 	Content []ToolContent
 	IsError bool
+	// Truncated is set when the output was cut because of a row or size limit.
+	Truncated bool
 }
 
 type ToolContent struct {
@@ -33,6 +38,36 @@ type ResourceContent struct {
 	Blob     string
 }
 
+type Resource struct {
+	URI         string
+	Name        string
+	Description string
+	MimeType    string
+}
+
+type Prompt struct {
+	Name        string
+	Description string
+	Arguments   []PromptArgument
+}
+
+type PromptArgument struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+type PromptMessage struct {
+	// Role is either "user" or "assistant"
+	Role    string
+	Content ToolContent
+}
+
+type PromptResult struct {
+	Description string
+	Messages    []PromptMessage
+}
+
 type ToolProvider interface {
 	// ListTools returns a list of available tools with optional pagination
 	ListTools(ctx context.Context, cursor string) ([]Tool, string, error)
@@ -40,3 +75,19 @@ type ToolProvider interface {
 	// CallTool invokes a specific tool with the given arguments
 	CallTool(ctx context.Context, name string, arguments map[string]interface{}) (*ToolResult, error)
 }
+
+type ResourceProvider interface {
+	// ListResources returns a list of available resources with optional pagination
+	ListResources(ctx context.Context, cursor string) ([]Resource, string, error)
+
+	// ReadResource returns the contents of the resource with the given URI
+	ReadResource(ctx context.Context, uri string) ([]ResourceContent, error)
+}
+
+type PromptProvider interface {
+	// ListPrompts returns a list of available prompts with optional pagination
+	ListPrompts(ctx context.Context, cursor string) ([]Prompt, string, error)
+
+	// GetPrompt renders the prompt with the given arguments
+	GetPrompt(ctx context.Context, name string, arguments map[string]string) (*PromptResult, error)
+}
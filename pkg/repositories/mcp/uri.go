@@ -0,0 +1,56 @@
+package mcp
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ResourceScheme = "clay://"
+
+	HelpResourcePrefix    = ResourceScheme + "help/"
+	CommandResourcePrefix = ResourceScheme + "command/"
+)
+
+// ErrResourceNotFound is returned (wrapped) by ReadResource when no resource matches the URI.
+var ErrResourceNotFound = errors.New("resource not found")
+
+type ResourceKind string
+
+const (
+	ResourceKindHelp    ResourceKind = "help"
+	ResourceKindCommand ResourceKind = "command"
+)
+
+// HelpResourceURI returns the URI under which the help section with the given slug is published.
+func HelpResourceURI(slug string) string {
+	return HelpResourcePrefix + slug
+}
+
+// CommandResourceURI returns the URI under which the source of the command with the
+// given full path (components separated by /) is published.
+func CommandResourceURI(path string) string {
+	return CommandResourcePrefix + strings.TrimPrefix(path, "/")
+}
+
+// ParseResourceURI splits a clay resource URI into its kind and its name
+// (the help slug or the command path).
+func ParseResourceURI(uri string) (ResourceKind, string, error) {
+	switch {
+	case strings.HasPrefix(uri, HelpResourcePrefix):
+		slug := strings.TrimPrefix(uri, HelpResourcePrefix)
+		if slug == "" {
+			return "", "", errors.Errorf("missing help slug in resource URI %s", uri)
+		}
+		return ResourceKindHelp, slug, nil
+	case strings.HasPrefix(uri, CommandResourcePrefix):
+		path := strings.Trim(strings.TrimPrefix(uri, CommandResourcePrefix), "/")
+		if path == "" {
+			return "", "", errors.Errorf("missing command path in resource URI %s", uri)
+		}
+		return ResourceKindCommand, path, nil
+	default:
+		return "", "", errors.Errorf("unknown resource URI %s", uri)
+	}
+}
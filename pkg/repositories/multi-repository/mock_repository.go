@@ -9,6 +9,7 @@ import (
 	"github.com/go-go-golems/clay/pkg/watcher"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/pkg/errors"
 )
 
 type MockRepository struct {
@@ -23,6 +24,8 @@ type MockRepository struct {
 	renderNodeOk bool
 	tools        []mcp.Tool
 	toolsError   error
+	resources    []mcp.Resource
+	contents     map[string][]mcp.ResourceContent
 }
 
 func NewMockRepository(commands []cmds.Command) *MockRepository {
@@ -75,6 +78,18 @@ func (m *MockRepository) ListTools(ctx context.Context, cursor string) ([]mcp.To
 	return m.tools, "", m.toolsError
 }
 
+func (m *MockRepository) ListResources(ctx context.Context, cursor string) ([]mcp.Resource, string, error) {
+	return m.resources, "", nil
+}
+
+func (m *MockRepository) ReadResource(ctx context.Context, uri string) ([]mcp.ResourceContent, error) {
+	contents, ok := m.contents[uri]
+	if !ok {
+		return nil, errors.Wrapf(mcp.ErrResourceNotFound, "%s", uri)
+	}
+	return contents, nil
+}
+
 func (m *MockRepository) Watch(ctx context.Context, options ...watcher.Option) error {
 	return nil
 }
@@ -2,6 +2,8 @@ package multi_repository
 
 import (
 	"context"
+	"encoding/binary"
+	"hash/fnv"
 	"path"
 	"strings"
 
@@ -23,6 +25,8 @@ type MountedRepository struct {
 
 type MultiRepository struct {
 	repositories []MountedRepository
+	// mounts counts the calls to Mount and Unmount, see Changes.
+	mounts uint64
 }
 
 func NewMultiRepository() *MultiRepository {
@@ -38,6 +42,7 @@ func (m *MultiRepository) Mount(mountPath string, repo repositories.RepositoryIn
 		Path:       mountPath,
 		Repository: repo,
 	})
+	m.mounts++
 }
 
 func (m *MultiRepository) Unmount(mountPath string) {
@@ -45,11 +50,28 @@ func (m *MultiRepository) Unmount(mountPath string) {
 	for i, repo := range m.repositories {
 		if repo.Path == mountPath {
 			m.repositories = append(m.repositories[:i], m.repositories[i+1:]...)
+			m.mounts++
 			return
 		}
 	}
 }
 
+// Changes combines the number of mounts and the changes of the mounted repositories
+// implementing repositories.ChangeCounter.
+func (m *MultiRepository) Changes() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], m.mounts)
+	_, _ = h.Write(buf[:])
+	for _, repo := range m.repositories {
+		if counter, ok := repo.Repository.(repositories.ChangeCounter); ok {
+			binary.LittleEndian.PutUint64(buf[:], counter.Changes())
+			_, _ = h.Write(buf[:])
+		}
+	}
+	return h.Sum64()
+}
+
 func (m *MultiRepository) LoadCommands(helpSystem *help.HelpSystem, options ...cmds.CommandDescriptionOption) error {
 	for _, repo := range m.repositories {
 		if err := repo.Repository.LoadCommands(helpSystem, options...); err != nil {
@@ -241,6 +263,74 @@ func (m *MultiRepository) ListTools(ctx context.Context, cursor string) ([]mcp.T
 	return allTools, "", nil
 }
 
+func (m *MultiRepository) ListResources(ctx context.Context, cursor string) ([]mcp.Resource, string, error) {
+	var allResources []mcp.Resource
+	for _, repo := range m.repositories {
+		resources, _, err := repo.Repository.ListResources(ctx, cursor)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to list resources for repository mounted at %s", repo.Path)
+		}
+
+		// Prepend mount path to each command resource, unless it's root mounted.
+		// Help sections are global and keep their URI.
+		for i := range resources {
+			kind, name, err := mcp.ParseResourceURI(resources[i].URI)
+			if err != nil || kind != mcp.ResourceKindCommand {
+				continue
+			}
+			if repo.Path != "/" {
+				resources[i].URI = mcp.CommandResourceURI(path.Join(repo.Path, name))
+			}
+		}
+		allResources = append(allResources, resources...)
+	}
+
+	return allResources, "", nil
+}
+
+func (m *MultiRepository) ReadResource(ctx context.Context, uri string) ([]mcp.ResourceContent, error) {
+	kind, name, err := mcp.ParseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case mcp.ResourceKindCommand:
+		name = path.Clean("/" + name)
+		for _, repo := range m.repositories {
+			if repo.Path != "/" && !strings.HasPrefix(name, repo.Path+"/") {
+				continue
+			}
+			subPath := strings.TrimPrefix(strings.TrimPrefix(name, repo.Path), "/")
+			contents, err := repo.Repository.ReadResource(ctx, mcp.CommandResourceURI(subPath))
+			if err != nil {
+				if errors.Is(err, mcp.ErrResourceNotFound) {
+					continue
+				}
+				return nil, errors.Wrapf(err, "failed to read resource from repository mounted at %s", repo.Path)
+			}
+			for i := range contents {
+				contents[i].URI = uri
+			}
+			return contents, nil
+		}
+
+	case mcp.ResourceKindHelp:
+		for _, repo := range m.repositories {
+			contents, err := repo.Repository.ReadResource(ctx, uri)
+			if err != nil {
+				if errors.Is(err, mcp.ErrResourceNotFound) {
+					continue
+				}
+				return nil, errors.Wrapf(err, "failed to read resource from repository mounted at %s", repo.Path)
+			}
+			return contents, nil
+		}
+	}
+
+	return nil, errors.Wrapf(mcp.ErrResourceNotFound, "%s", uri)
+}
+
 func (m *MultiRepository) Watch(ctx context.Context, options ...watcher.Option) error {
 	g, ctx := errgroup.WithContext(ctx)
 
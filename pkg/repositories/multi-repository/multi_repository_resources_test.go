@@ -0,0 +1,72 @@
+package multi_repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListResources(t *testing.T) {
+	mr := NewMultiRepository()
+
+	rootRepo := NewMockRepository(nil)
+	rootRepo.resources = []mcp.Resource{
+		{URI: "clay://command/cmd1"},
+		{URI: "clay://help/topic1"},
+	}
+	mr.Mount("/", rootRepo)
+
+	mountedRepo := NewMockRepository(nil)
+	mountedRepo.resources = []mcp.Resource{
+		{URI: "clay://command/sub/cmd2"},
+		{URI: "clay://help/topic2"},
+	}
+	mr.Mount("/test", mountedRepo)
+
+	resources, _, err := mr.ListResources(context.Background(), "")
+	require.NoError(t, err)
+
+	uris := []string{}
+	for _, resource := range resources {
+		uris = append(uris, resource.URI)
+	}
+	assert.ElementsMatch(t, []string{
+		"clay://command/cmd1",
+		"clay://help/topic1",
+		"clay://command/test/sub/cmd2",
+		"clay://help/topic2",
+	}, uris)
+}
+
+func TestReadResource(t *testing.T) {
+	mr := NewMultiRepository()
+
+	mountedRepo := NewMockRepository(nil)
+	mountedRepo.contents = map[string][]mcp.ResourceContent{
+		"clay://command/sub/cmd2": {{URI: "clay://command/sub/cmd2", Text: "query: SELECT 1"}},
+		"clay://help/topic2":      {{URI: "clay://help/topic2", Text: "# Topic 2"}},
+	}
+	mr.Mount("/test", mountedRepo)
+
+	ctx := context.Background()
+
+	contents, err := mr.ReadResource(ctx, "clay://command/test/sub/cmd2")
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Equal(t, "query: SELECT 1", contents[0].Text)
+	assert.Equal(t, "clay://command/test/sub/cmd2", contents[0].URI)
+
+	contents, err = mr.ReadResource(ctx, "clay://help/topic2")
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Equal(t, "# Topic 2", contents[0].Text)
+
+	_, err = mr.ReadResource(ctx, "clay://command/other/cmd2")
+	assert.ErrorIs(t, err, mcp.ErrResourceNotFound)
+
+	_, err = mr.ReadResource(ctx, "clay://help/missing")
+	assert.ErrorIs(t, err, mcp.ErrResourceNotFound)
+}
@@ -0,0 +1,230 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+	"github.com/go-go-golems/glazed/pkg/cmds/runner"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+	"github.com/pkg/errors"
+)
+
+// DefaultPromptType is the command type published as prompts if no type or tag is configured.
+const DefaultPromptType = "prompt"
+
+// PromptCommand is implemented by commands that render directly into prompt messages.
+// Commands that don't implement it but are WriterCommands (for example glazed's
+// TemplateCommand) are rendered into a single user message.
+type PromptCommand interface {
+	cmds.Command
+	RenderPrompt(ctx context.Context, parsedValues *values.Values) ([]mcp.PromptMessage, error)
+}
+
+// PromptProvider publishes the commands of a repository with a given type or tag as MCP prompts.
+// The flags and arguments of the default section become the prompt arguments.
+//
+// Like ToolProvider, it keeps the names of all the prompts of the repository, rebuilt when the
+// repository changes, so prompts added or removed by Watch are picked up without further setup
+// and can be fetched without being listed first.
+type PromptProvider struct {
+	repository   RepositoryInterface
+	mapper       *mcp.ToolNameMapper
+	names        *toolNameTable
+	types        []string
+	tags         []string
+	parseOptions []runner.ParseOption
+}
+
+type PromptProviderOption func(*PromptProvider)
+
+// WithPromptTypes publishes commands of the given types as prompts.
+func WithPromptTypes(types ...string) PromptProviderOption {
+	return func(p *PromptProvider) {
+		p.types = append(p.types, types...)
+	}
+}
+
+// WithPromptTags publishes commands with any of the given tags as prompts.
+func WithPromptTags(tags ...string) PromptProviderOption {
+	return func(p *PromptProvider) {
+		p.tags = append(p.tags, tags...)
+	}
+}
+
+// WithPromptNameMapper sets the mapper used to encode and resolve prompt names.
+func WithPromptNameMapper(mapper *mcp.ToolNameMapper) PromptProviderOption {
+	return func(p *PromptProvider) {
+		p.mapper = mapper
+	}
+}
+
+// WithPromptParseOptions adds parse options used when parsing the prompt arguments.
+func WithPromptParseOptions(options ...runner.ParseOption) PromptProviderOption {
+	return func(p *PromptProvider) {
+		p.parseOptions = append(p.parseOptions, options...)
+	}
+}
+
+func NewPromptProvider(repository RepositoryInterface, options ...PromptProviderOption) *PromptProvider {
+	ret := &PromptProvider{
+		repository: repository,
+	}
+	for _, opt := range options {
+		opt(ret)
+	}
+	if ret.mapper == nil {
+		ret.mapper = mcp.NewToolNameMapper()
+	}
+	if len(ret.types) == 0 && len(ret.tags) == 0 {
+		ret.types = []string{DefaultPromptType}
+	}
+	ret.names = &toolNameTable{
+		repository: repository,
+		mapper:     ret.mapper,
+		filter:     ret.promptPaths,
+	}
+	return ret
+}
+
+// promptPaths returns the paths of the commands published as prompts.
+func (p *PromptProvider) promptPaths(ctx context.Context, commands map[string]cmds.Command) ([]string, error) {
+	ret := make([]string, 0, len(commands))
+	for path, cmd := range commands {
+		if p.IsPrompt(cmd) {
+			ret = append(ret, path)
+		}
+	}
+	return ret, nil
+}
+
+var _ mcp.PromptProvider = (*PromptProvider)(nil)
+
+// IsPrompt returns true if cmd has one of the configured types or tags.
+func (p *PromptProvider) IsPrompt(cmd cmds.Command) bool {
+	desc := cmd.Description()
+	for _, t := range p.types {
+		if desc.Type == t {
+			return true
+		}
+	}
+	for _, tag := range p.tags {
+		for _, t := range desc.Tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func promptArguments(desc *cmds.CommandDescription) []mcp.PromptArgument {
+	ret := []mcp.PromptArgument{}
+	if desc.Schema == nil {
+		return ret
+	}
+	add := func(definition *fields.Definition) {
+		ret = append(ret, mcp.PromptArgument{
+			Name:        definition.Name,
+			Description: definition.Help,
+			Required:    definition.Required,
+		})
+	}
+	desc.GetDefaultFlags().ForEach(add)
+	desc.GetDefaultArguments().ForEach(add)
+	return ret
+}
+
+// ListPrompts returns the prompt commands of the repository. Listing the first page
+// rebuilds the names.
+func (p *PromptProvider) ListPrompts(ctx context.Context, cursor string) ([]mcp.Prompt, string, error) {
+	if cursor == "" {
+		if err := p.names.rebuild(ctx); err != nil {
+			return nil, "", err
+		}
+	} else if err := p.names.refresh(ctx); err != nil {
+		return nil, "", err
+	}
+
+	tools, commands, nextCursor, err := collectToolCommands(ctx, p.repository, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := []mcp.Prompt{}
+	for _, tool := range tools {
+		cmd := commands[tool.Name]
+		if !p.IsPrompt(cmd) {
+			continue
+		}
+		name, ok := p.names.name(tool.Name)
+		if !ok {
+			continue
+		}
+		desc := cmd.Description()
+		ret = append(ret, mcp.Prompt{
+			Name:        name,
+			Description: desc.Short,
+			Arguments:   promptArguments(desc),
+		})
+	}
+
+	return ret, nextCursor, nil
+}
+
+func (p *PromptProvider) GetPrompt(ctx context.Context, name string, arguments map[string]string) (*mcp.PromptResult, error) {
+	_, cmd, ok, err := p.names.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.Errorf("unknown prompt %s", name)
+	}
+
+	for _, argument := range promptArguments(cmd.Description()) {
+		if _, ok := arguments[argument.Name]; argument.Required && !ok {
+			return nil, errors.Errorf("missing required argument %s for prompt %s", argument.Name, name)
+		}
+	}
+
+	args := make(map[string]interface{}, len(arguments))
+	for k, v := range arguments {
+		args[k] = v
+	}
+	parsedValues, err := parseCommandArguments(cmd, args, p.parseOptions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse arguments for prompt %s", name)
+	}
+
+	messages, err := renderPrompt(ctx, cmd, parsedValues)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not render prompt %s", name)
+	}
+
+	return &mcp.PromptResult{
+		Description: cmd.Description().Short,
+		Messages:    messages,
+	}, nil
+}
+
+func renderPrompt(ctx context.Context, cmd cmds.Command, parsedValues *values.Values) ([]mcp.PromptMessage, error) {
+	switch c := cmd.(type) {
+	case PromptCommand:
+		return c.RenderPrompt(ctx, parsedValues)
+	case cmds.WriterCommand:
+		buf := &bytes.Buffer{}
+		if err := c.RunIntoWriter(ctx, parsedValues, buf); err != nil {
+			return nil, err
+		}
+		return []mcp.PromptMessage{
+			{
+				Role:    "user",
+				Content: mcp.ToolContent{Type: "text", Text: buf.String()},
+			},
+		}, nil
+	default:
+		return nil, errors.Errorf("command %s can't be rendered as a prompt", cmd.Description().FullPath())
+	}
+}
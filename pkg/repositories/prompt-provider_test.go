@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReviewPrompt() *cmds.TemplateCommand {
+	return cmds.NewTemplateCommand(
+		"review",
+		"Please review the {{ .language }} code in {{ .file }}.",
+		cmds.WithShort("Review code"),
+		cmds.WithType("prompt"),
+		cmds.WithParents("prompts"),
+		cmds.WithFlags(
+			fields.New("language", fields.TypeString,
+				fields.WithHelp("Programming language"),
+				fields.WithDefault("go"),
+			),
+		),
+		cmds.WithArguments(
+			fields.New("file", fields.TypeString,
+				fields.WithHelp("File to review"),
+				fields.WithRequired(true),
+			),
+		),
+	)
+}
+
+func TestPromptProviderListPrompts(t *testing.T) {
+	r := NewRepository()
+	r.Add(newReviewPrompt(), newEchoCommand([]string{"util"}, "echo"))
+
+	p := NewPromptProvider(r)
+	prompts, _, err := p.ListPrompts(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, prompts, 1)
+
+	prompt := prompts[0]
+	assert.Equal(t, "prompts__review", prompt.Name)
+	assert.Equal(t, "Review code", prompt.Description)
+	require.Len(t, prompt.Arguments, 2)
+	assert.Equal(t, "language", prompt.Arguments[0].Name)
+	assert.False(t, prompt.Arguments[0].Required)
+	assert.Equal(t, "file", prompt.Arguments[1].Name)
+	assert.True(t, prompt.Arguments[1].Required)
+}
+
+func TestPromptProviderGetPrompt(t *testing.T) {
+	r := NewRepository()
+	r.Add(newReviewPrompt())
+
+	p := NewPromptProvider(r)
+	ctx := context.Background()
+	_, _, err := p.ListPrompts(ctx, "")
+	require.NoError(t, err)
+
+	result, err := p.GetPrompt(ctx, "prompts__review", map[string]string{
+		"file": "main.go",
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, "user", result.Messages[0].Role)
+	assert.Equal(t, "Please review the go code in main.go.", result.Messages[0].Content.Text)
+
+	_, err = p.GetPrompt(ctx, "prompts__review", map[string]string{})
+	assert.Error(t, err)
+
+	_, err = p.GetPrompt(ctx, "unknown", nil)
+	assert.Error(t, err)
+}
+
+func TestPromptProviderTags(t *testing.T) {
+	r := NewRepository()
+	echo := newEchoCommand([]string{"util"}, "echo")
+	echo.Tags = []string{"prompt-template"}
+	r.Add(newReviewPrompt(), echo)
+
+	p := NewPromptProvider(r, WithPromptTags("prompt-template"))
+	prompts, _, err := p.ListPrompts(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, prompts, 1)
+	assert.Equal(t, "util__echo", prompts[0].Name)
+
+	// echo is a GlazeCommand, which can't be rendered as a prompt
+	_, err = p.GetPrompt(context.Background(), "util__echo", nil)
+	assert.Error(t, err)
+}
+
+func TestPromptProviderGetPromptWithoutListing(t *testing.T) {
+	r := NewRepository()
+	p := NewPromptProvider(r)
+	ctx := context.Background()
+
+	_, err := p.GetPrompt(ctx, "prompts__review", map[string]string{"file": "main.go"})
+	assert.Error(t, err)
+
+	// prompts added later can be fetched right away
+	r.Add(newReviewPrompt())
+	result, err := p.GetPrompt(ctx, "prompts__review", map[string]string{"file": "main.go"})
+	require.NoError(t, err)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, "Please review the go code in main.go.", result.Messages[0].Content.Text)
+}
+
+func TestToolProviderExcludesPrompts(t *testing.T) {
+	r := NewRepository()
+	r.Add(newReviewPrompt(), newEchoCommand([]string{"util"}, "echo"))
+	ctx := context.Background()
+
+	tools, _, err := NewToolProvider(r).ListTools(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, tools, 2)
+
+	p := NewToolProvider(r, WithToolExcludedPrompts(NewPromptProvider(r)))
+	tools, _, err = p.ListTools(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "util__echo", tools[0].Name)
+
+	_, err = p.CallTool(ctx, "prompts__review", map[string]interface{}{"file": "main.go"})
+	toolCallError, ok := mcp.IsToolCallError(err)
+	require.True(t, ok, err)
+	assert.Equal(t, mcp.ToolCallErrorUnknownTool, toolCallError.Code)
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-go-golems/clay/pkg/repositories/mcp"
 	"github.com/go-go-golems/clay/pkg/repositories/trie"
@@ -15,6 +17,7 @@ import (
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
 	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/go-go-golems/glazed/pkg/help/model"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -48,6 +51,15 @@ type Repository struct {
 
 	// loader is used to load all commands on startup
 	loader loaders.CommandLoader
+
+	// mu guards helpSections, which are replaced when the watcher reloads the commands.
+	mu sync.RWMutex
+	// helpSections are the sections loaded from the RootDocDirectory of each directory,
+	// kept around so they can be published as resources.
+	helpSections []*model.Section
+
+	// changes counts the calls to Add and Remove, see ChangeCounter.
+	changes atomic.Uint64
 }
 
 type RepositoryOption func(*Repository)
@@ -107,6 +119,8 @@ func (r *Repository) LoadCommands(helpSystem *help.HelpSystem, options ...cmds.C
 	if r.loader != nil {
 		commands := make([]cmds.Command, 0)
 		aliases := make([]*alias.CommandAlias, 0)
+		// the sections are reloaded along with the commands, e.g. by the watcher
+		helpSections := make([]*model.Section, 0)
 
 		// Load from directories
 		for _, directory := range r.Directories {
@@ -170,11 +184,18 @@ func (r *Repository) LoadCommands(helpSystem *help.HelpSystem, options ...cmds.C
 			_ = file.Close()
 
 			// If directory exists, proceed with loading sections
-			err = helpSystem.LoadSectionsFromFS(directory.FS, directory.RootDocDirectory)
+			sections, err := loadHelpSectionsFromFS(directory.FS, directory.RootDocDirectory)
 			if err != nil {
 				return err
 			}
+			for _, section := range sections {
+				helpSystem.AddSection(section)
+			}
+			helpSections = append(helpSections, sections...)
 		}
+		r.mu.Lock()
+		r.helpSections = helpSections
+		r.mu.Unlock()
 
 		// Load from individual files
 		for _, file := range r.Files {
@@ -223,6 +244,8 @@ func (r *Repository) LoadCommands(helpSystem *help.HelpSystem, options ...cmds.C
 }
 
 func (r *Repository) Add(commands ...cmds.Command) {
+	defer r.changes.Add(1)
+
 	aliases := []*alias.CommandAlias{}
 
 	for _, command := range commands {
@@ -264,6 +287,8 @@ func (r *Repository) Add(commands ...cmds.Command) {
 }
 
 func (r *Repository) Remove(prefixes ...[]string) {
+	defer r.changes.Add(1)
+
 	for _, prefix := range prefixes {
 		removedCommands := r.Root.Remove(prefix)
 		for _, command := range removedCommands {
@@ -277,6 +302,11 @@ func (r *Repository) Remove(prefixes ...[]string) {
 	}
 }
 
+// Changes returns the number of calls to Add and Remove.
+func (r *Repository) Changes() uint64 {
+	return r.changes.Load()
+}
+
 func (r *Repository) CollectCommands(prefix []string, recurse bool) []cmds.Command {
 	return r.Root.CollectCommands(prefix, recurse)
 }
@@ -352,3 +382,41 @@ func (r *Repository) ListTools(ctx context.Context, cursor string) ([]mcp.Tool,
 	// For now, return all tools without pagination
 	return tools, "", nil
 }
+
+func (r *Repository) getHelpSections() []*model.Section {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.helpSections
+}
+
+// ListResources returns the help sections loaded from the repository directories
+// and the source of all commands that keep it, as MCP resources.
+func (r *Repository) ListResources(ctx context.Context, cursor string) ([]mcp.Resource, string, error) {
+	resources := HelpSectionsToResources(r.getHelpSections())
+	resources = append(resources, CommandsToResources(r.Root.CollectCommands([]string{}, true))...)
+
+	// For now, return all resources without pagination
+	return resources, "", nil
+}
+
+// ReadResource returns the content of a help section (clay://help/<slug>) or
+// of a command source (clay://command/<path>).
+func (r *Repository) ReadResource(ctx context.Context, uri string) ([]mcp.ResourceContent, error) {
+	kind, name, err := mcp.ParseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case mcp.ResourceKindHelp:
+		return ReadHelpSectionResource(r.getHelpSections(), uri, name)
+	case mcp.ResourceKindCommand:
+		cmd, ok := r.Root.FindCommand(strings.Split(name, "/"))
+		if !ok {
+			return nil, errors.Wrapf(mcp.ErrResourceNotFound, "command %s", name)
+		}
+		return ReadCommandResource(cmd, uri)
+	default:
+		return nil, errors.Wrapf(mcp.ErrResourceNotFound, "%s", uri)
+	}
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/go-go-golems/glazed/pkg/help/model"
+	"github.com/go-go-golems/glazed/pkg/help/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	HelpResourceMimeType    = "text/markdown"
+	CommandResourceMimeType = "application/yaml"
+)
+
+// CommandWithSource is implemented by commands that keep the original content
+// they were loaded from (for example the YAML of a RawCommand), so that it can
+// be published as an MCP resource.
+type CommandWithSource interface {
+	cmds.Command
+	SourceContent() []byte
+}
+
+// loadHelpSectionsFromFS loads all markdown help sections below dir with the loader of
+// help.HelpSystem, into a scratch help system, and returns them so that the repository
+// can keep track of the sections it loaded.
+func loadHelpSectionsFromFS(f fs.FS, dir string) ([]*model.Section, error) {
+	st, err := store.NewInMemory()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create help section store")
+	}
+	defer func() {
+		_ = st.Close()
+	}()
+
+	if err := help.NewHelpSystemWithStore(st).LoadSectionsFromFS(f, dir); err != nil {
+		return nil, err
+	}
+	return st.List(context.Background(), "")
+}
+
+// HelpSectionsToResources converts help sections to MCP resources.
+func HelpSectionsToResources(sections []*model.Section) []mcp.Resource {
+	ret := make([]mcp.Resource, 0, len(sections))
+	for _, section := range sections {
+		ret = append(ret, mcp.Resource{
+			URI:         mcp.HelpResourceURI(section.Slug),
+			Name:        section.Title,
+			Description: section.Short,
+			MimeType:    HelpResourceMimeType,
+		})
+	}
+	return ret
+}
+
+// CommandsToResources converts all commands that keep their source content to MCP resources.
+// Commands that don't implement CommandWithSource are skipped.
+func CommandsToResources(commands []cmds.Command) []mcp.Resource {
+	ret := make([]mcp.Resource, 0, len(commands))
+	for _, cmd := range commands {
+		if _, ok := cmd.(CommandWithSource); !ok {
+			continue
+		}
+		desc := cmd.Description()
+		ret = append(ret, mcp.Resource{
+			URI:         mcp.CommandResourceURI(desc.FullPath()),
+			Name:        desc.Name,
+			Description: desc.Short,
+			MimeType:    CommandResourceMimeType,
+		})
+	}
+	return ret
+}
+
+// ReadHelpSectionResource looks up the section with the given slug and returns its content.
+func ReadHelpSectionResource(sections []*model.Section, uri string, slug string) ([]mcp.ResourceContent, error) {
+	for _, section := range sections {
+		if section.Slug == slug {
+			return []mcp.ResourceContent{
+				{
+					URI:      uri,
+					MimeType: HelpResourceMimeType,
+					Text:     section.Content,
+				},
+			}, nil
+		}
+	}
+	return nil, errors.Wrapf(mcp.ErrResourceNotFound, "help section %s", slug)
+}
+
+// ReadCommandResource returns the source content of cmd.
+func ReadCommandResource(cmd cmds.Command, uri string) ([]mcp.ResourceContent, error) {
+	cmdWithSource, ok := cmd.(CommandWithSource)
+	if !ok {
+		return nil, errors.Wrapf(mcp.ErrResourceNotFound, "command %s has no source", cmd.Description().FullPath())
+	}
+	return []mcp.ResourceContent{
+		{
+			URI:      uri,
+			MimeType: CommandResourceMimeType,
+			Text:     string(cmdWithSource.SourceContent()),
+		},
+	}, nil
+}
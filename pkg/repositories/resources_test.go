@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	clay_cmds "github.com/go-go-golems/clay/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/go-go-golems/glazed/pkg/help"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testQueryYAML = `name: ls
+short: List things
+query: SELECT * FROM things
+`
+
+const testHelpSection = `---
+Title: Querying things
+Slug: querying-things
+Short: How to query things
+SectionType: GeneralTopic
+---
+
+Use the ls command.
+`
+
+func newResourceTestRepository(t *testing.T) *Repository {
+	fs := fstest.MapFS{
+		"queries/things/ls.yaml":  {Data: []byte(testQueryYAML)},
+		"queries/doc/querying.md": {Data: []byte(testHelpSection)},
+	}
+
+	r := NewRepository(
+		WithCommandLoader(clay_cmds.NewRawCommandLoader()),
+		WithDirectories(Directory{
+			FS:               fs,
+			RootDirectory:    "queries",
+			RootDocDirectory: "queries/doc",
+			Name:             "test",
+		}),
+	)
+	helpSystem := help.NewHelpSystem()
+	require.NoError(t, r.LoadCommands(helpSystem))
+
+	section, err := helpSystem.GetSectionWithSlug("querying-things")
+	require.NoError(t, err)
+	assert.Equal(t, "Querying things", section.Title)
+
+	return r
+}
+
+func TestRepositoryListResources(t *testing.T) {
+	r := newResourceTestRepository(t)
+
+	resources, cursor, err := r.ListResources(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "", cursor)
+
+	uris := []string{}
+	for _, resource := range resources {
+		uris = append(uris, resource.URI)
+	}
+	assert.ElementsMatch(t, []string{
+		"clay://help/querying-things",
+		"clay://command/things/ls",
+	}, uris)
+}
+
+func TestRepositoryReadResource(t *testing.T) {
+	r := newResourceTestRepository(t)
+	ctx := context.Background()
+
+	contents, err := r.ReadResource(ctx, "clay://command/things/ls")
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Equal(t, testQueryYAML, contents[0].Text)
+	assert.Equal(t, CommandResourceMimeType, contents[0].MimeType)
+
+	contents, err = r.ReadResource(ctx, "clay://help/querying-things")
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Contains(t, contents[0].Text, "Use the ls command.")
+	assert.Equal(t, HelpResourceMimeType, contents[0].MimeType)
+
+	_, err = r.ReadResource(ctx, "clay://command/things/missing")
+	assert.ErrorIs(t, err, mcp.ErrResourceNotFound)
+
+	_, err = r.ReadResource(ctx, "clay://help/missing")
+	assert.ErrorIs(t, err, mcp.ErrResourceNotFound)
+
+	_, err = r.ReadResource(ctx, "http://example.com")
+	assert.Error(t, err)
+}
+
+func TestRepositoryReloadKeepsResources(t *testing.T) {
+	r := newResourceTestRepository(t)
+	require.NoError(t, r.LoadCommands(help.NewHelpSystem()))
+
+	resources, _, err := r.ListResources(context.Background(), "")
+	require.NoError(t, err)
+	uris := []string{}
+	for _, resource := range resources {
+		uris = append(uris, resource.URI)
+	}
+	assert.ElementsMatch(t, []string{
+		"clay://help/querying-things",
+		"clay://command/things/ls",
+	}, uris)
+}
+
+func TestRepositoryReadHelpResourceDuringReload(t *testing.T) {
+	r := newResourceTestRepository(t)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, r.LoadCommands(help.NewHelpSystem()))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		contents, err := r.ReadResource(ctx, "clay://help/querying-things")
+		require.NoError(t, err)
+		require.Len(t, contents, 1)
+	}
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// ToolLimitsMetadataKey is the command metadata key under which per-command
+// tool call limits can be set, for example:
+//
+//	metadata:
+//	  tool-limits:
+//	    timeout: 2m
+//	    max-rows: 1000
+//	    max-bytes: 65536
+const ToolLimitsMetadataKey = "tool-limits"
+
+// ToolCallLimits bounds the execution of a single tool call. Zero values mean no limit.
+type ToolCallLimits struct {
+	// Timeout is applied to the context the command runs with.
+	Timeout time.Duration
+	// MaxRows stops the glaze processor after that many rows.
+	MaxRows int
+	// MaxBytes truncates the text returned in the ToolResult.
+	MaxBytes int
+}
+
+// DefaultToolCallLimits are the limits used by a ToolProvider unless overridden with WithToolCallLimits.
+var DefaultToolCallLimits = ToolCallLimits{
+	Timeout:  60 * time.Second,
+	MaxRows:  1000,
+	MaxBytes: 256 * 1024,
+}
+
+// ForCommand returns the limits overridden by the tool-limits metadata of cmd.
+func (l ToolCallLimits) ForCommand(cmd cmds.Command) (ToolCallLimits, error) {
+	metadata := cmd.Description().Metadata
+	if metadata == nil {
+		return l, nil
+	}
+	v, ok := metadata[ToolLimitsMetadataKey]
+	if !ok {
+		return l, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return l, errors.Errorf("%s metadata of %s must be a map", ToolLimitsMetadataKey, cmd.Description().FullPath())
+	}
+
+	ret := l
+	if v, ok := m["timeout"]; ok {
+		timeout, err := parseLimitDuration(v)
+		if err != nil {
+			return l, errors.Wrapf(err, "invalid timeout for %s", cmd.Description().FullPath())
+		}
+		ret.Timeout = timeout
+	}
+	if v, ok := m["max-rows"]; ok {
+		maxRows, err := parseLimitInt(v)
+		if err != nil {
+			return l, errors.Wrapf(err, "invalid max-rows for %s", cmd.Description().FullPath())
+		}
+		ret.MaxRows = maxRows
+	}
+	if v, ok := m["max-bytes"]; ok {
+		maxBytes, err := parseLimitInt(v)
+		if err != nil {
+			return l, errors.Wrapf(err, "invalid max-bytes for %s", cmd.Description().FullPath())
+		}
+		ret.MaxBytes = maxBytes
+	}
+
+	return ret, nil
+}
+
+// parseLimitDuration accepts duration strings ("30s") or a number of seconds.
+func parseLimitDuration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case string:
+		return time.ParseDuration(v)
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	default:
+		return 0, errors.Errorf("could not parse duration %v", v)
+	}
+}
+
+func parseLimitInt(v interface{}) (int, error) {
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	default:
+		return 0, errors.Errorf("could not parse integer %v", v)
+	}
+}
+
+// errRowLimitReached is returned by rowLimitProcessor once the row limit is reached,
+// to make the command stop producing rows.
+var errRowLimitReached = errors.New("row limit reached")
+
+// rowLimitProcessor forwards up to maxRows rows to the wrapped processor.
+type rowLimitProcessor struct {
+	middlewares.Processor
+	maxRows      int
+	rows         int
+	limitReached bool
+}
+
+var _ middlewares.Processor = (*rowLimitProcessor)(nil)
+
+func (p *rowLimitProcessor) AddRow(ctx context.Context, row types.Row) error {
+	if p.maxRows > 0 && p.rows >= p.maxRows {
+		p.limitReached = true
+		return errRowLimitReached
+	}
+	p.rows++
+	return p.Processor.AddRow(ctx, row)
+}
+
+// truncateOutput cuts text to at most maxBytes, after the last full line that fits.
+// If the first line doesn't fit, it is cut on a rune boundary.
+func truncateOutput(text string, maxBytes int) (string, bool) {
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text, false
+	}
+	if i := strings.LastIndexByte(text[:maxBytes], '\n'); i >= 0 {
+		return text[:i+1], true
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut], true
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countCommand emits rows until the processor refuses them, or waits for the
+// context to be done if blocking is set. If failure is set, it ignores the errors
+// of the processor and returns failure once all rows are emitted.
+type countCommand struct {
+	*cmds.CommandDescription
+	rows     int
+	blocking bool
+	failure  error
+}
+
+func (c *countCommand) RunIntoGlazeProcessor(ctx context.Context, _ *values.Values, gp middlewares.Processor) error {
+	if c.blocking {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	for i := 0; i < c.rows; i++ {
+		if err := gp.AddRow(ctx, types.NewRow(types.MRP("i", i))); err != nil && c.failure == nil {
+			return err
+		}
+	}
+	return c.failure
+}
+
+func newCountCommand(name string, rows int, metadata map[string]interface{}) *countCommand {
+	return &countCommand{
+		CommandDescription: cmds.NewCommandDescription(name, cmds.WithMetadata(metadata)),
+		rows:               rows,
+	}
+}
+
+func TestToolCallLimitsForCommand(t *testing.T) {
+	cmd := newCountCommand("count", 0, map[string]interface{}{
+		ToolLimitsMetadataKey: map[string]interface{}{
+			"timeout":  "2m",
+			"max-rows": 10,
+		},
+	})
+
+	limits, err := DefaultToolCallLimits.ForCommand(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, limits.Timeout)
+	assert.Equal(t, 10, limits.MaxRows)
+	assert.Equal(t, DefaultToolCallLimits.MaxBytes, limits.MaxBytes)
+
+	cmd = newCountCommand("count", 0, map[string]interface{}{
+		ToolLimitsMetadataKey: map[string]interface{}{"max-rows": "many"},
+	})
+	_, err = DefaultToolCallLimits.ForCommand(cmd)
+	assert.Error(t, err)
+}
+
+func TestToolProviderRowLimit(t *testing.T) {
+	r := NewRepository()
+	r.Add(
+		newCountCommand("count", 100, nil),
+		newCountCommand("count-few", 100, map[string]interface{}{
+			ToolLimitsMetadataKey: map[string]interface{}{"max-rows": 2},
+		}),
+	)
+
+	p := NewToolProvider(r, WithToolCallLimits(ToolCallLimits{MaxRows: 5}))
+	ctx := context.Background()
+	_, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+
+	result, err := p.CallTool(ctx, "count", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content)
+	assert.True(t, result.Truncated)
+	require.Len(t, result.Content, 2)
+	assert.Len(t, decodeRows(t, result.Content[0].Text), 5)
+	assert.Equal(t, "[output truncated after 5 rows]", result.Content[1].Text)
+
+	result, err = p.CallTool(ctx, "count-few", nil)
+	require.NoError(t, err)
+	assert.True(t, result.Truncated)
+	assert.Len(t, decodeRows(t, result.Content[0].Text), 2)
+
+	// errors other than the row limit are reported
+	failing := newCountCommand("failing", 100, nil)
+	failing.failure = errors.New("connection lost")
+	r.Add(failing)
+	result, err = p.CallTool(ctx, "failing", nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].Text, "connection lost")
+}
+
+func TestToolProviderByteLimit(t *testing.T) {
+	r := NewRepository()
+	r.Add(newCountCommand("count", 100, nil))
+
+	p := NewToolProvider(r, WithToolCallLimits(ToolCallLimits{MaxBytes: 100}))
+	ctx := context.Background()
+	_, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+
+	result, err := p.CallTool(ctx, "count", nil)
+	require.NoError(t, err)
+	assert.True(t, result.Truncated)
+	require.Len(t, result.Content, 2)
+	assert.LessOrEqual(t, len(result.Content[0].Text), 100)
+	rows := decodeRows(t, result.Content[0].Text)
+	assert.NotEmpty(t, rows)
+	assert.Less(t, len(rows), 100)
+	assert.Contains(t, result.Content[1].Text, fmt.Sprintf("[output truncated after %d rows", len(rows)))
+}
+
+// decodeRows checks that text is a JSON list of rows and returns it.
+func decodeRows(t *testing.T, text string) []map[string]interface{} {
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(text), &rows), text)
+	return rows
+}
+
+func TestToolProviderTimeout(t *testing.T) {
+	r := NewRepository()
+	cmd := newCountCommand("wait", 0, nil)
+	cmd.blocking = true
+	r.Add(cmd)
+
+	p := NewToolProvider(r, WithToolCallLimits(ToolCallLimits{Timeout: 20 * time.Millisecond}))
+	ctx := context.Background()
+	_, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+
+	result, err := p.CallTool(ctx, "wait", nil)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].Text, "timed out after")
+}
+
+func TestTruncateOutput(t *testing.T) {
+	text, truncated := truncateOutput("hello", 10)
+	assert.False(t, truncated)
+	assert.Equal(t, "hello", text)
+
+	// cut after the last full line
+	text, truncated = truncateOutput("one\ntwo\nthree\n", 10)
+	assert.True(t, truncated)
+	assert.Equal(t, "one\ntwo\n", text)
+
+	// don't cut in the middle of a multi-byte rune
+	text, truncated = truncateOutput("aé", 2)
+	assert.True(t, truncated)
+	assert.Equal(t, "a", text)
+}
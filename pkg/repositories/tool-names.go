@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+)
+
+// toolNameTable keeps the names of the tools (or prompts) of a repository in a ToolNameMapper.
+//
+// The table is built from all the commands selected by the filter, so that the names don't
+// depend on the order in which commands are listed or called. It is rebuilt when the
+// repository reports a change (see ChangeCounter), when a name can't be resolved, and
+// when a name resolves to a command that is gone.
+type toolNameTable struct {
+	repository RepositoryInterface
+	mapper     *mcp.ToolNameMapper
+	// filter returns the paths of commands that get a name.
+	filter func(ctx context.Context, commands map[string]cmds.Command) ([]string, error)
+
+	mu      sync.Mutex
+	built   bool
+	changes uint64
+}
+
+// rebuild registers the names of the current commands of the repository.
+func (t *toolNameTable) rebuild(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rebuildLocked(ctx)
+}
+
+func (t *toolNameTable) rebuildLocked(ctx context.Context) error {
+	changes := t.repositoryChanges()
+
+	commands, err := collectAllToolCommands(ctx, t.repository)
+	if err != nil {
+		return err
+	}
+	paths, err := t.filter(ctx, commands)
+	if err != nil {
+		return err
+	}
+	if err := t.mapper.Rebuild(paths); err != nil {
+		return err
+	}
+
+	t.built = true
+	t.changes = changes
+	return nil
+}
+
+func (t *toolNameTable) repositoryChanges() uint64 {
+	if counter, ok := t.repository.(ChangeCounter); ok {
+		return counter.Changes()
+	}
+	return 0
+}
+
+// refresh rebuilds the table if it hasn't been built yet or the repository changed since.
+func (t *toolNameTable) refresh(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.built && t.changes == t.repositoryChanges() {
+		return nil
+	}
+	return t.rebuildLocked(ctx)
+}
+
+// name returns the name of the command at path.
+func (t *toolNameTable) name(path string) (string, bool) {
+	return t.mapper.Name(path)
+}
+
+// resolve returns the path and command for name. A miss rebuilds the table once before
+// giving up, so that commands added since the last rebuild can be called right away.
+func (t *toolNameTable) resolve(ctx context.Context, name string) (string, cmds.Command, bool, error) {
+	if err := t.refresh(ctx); err != nil {
+		return "", nil, false, err
+	}
+	if path, cmd, ok := t.lookup(name); ok {
+		return path, cmd, true, nil
+	}
+
+	if err := t.rebuild(ctx); err != nil {
+		return "", nil, false, err
+	}
+	path, cmd, ok := t.lookup(name)
+	return path, cmd, ok, nil
+}
+
+func (t *toolNameTable) lookup(name string) (string, cmds.Command, bool) {
+	path, ok := t.mapper.Resolve(name)
+	if !ok {
+		return "", nil, false
+	}
+	cmd, ok := t.repository.GetCommand(path)
+	if !ok {
+		return "", nil, false
+	}
+	return path, cmd, true
+}
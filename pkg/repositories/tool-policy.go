@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/go-go-golems/clay/pkg/filters/command"
+	"github.com/go-go-golems/clay/pkg/filters/command/builder"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// untypedCommandType is used to index commands without a type, since the command
+// index requires one. Rules can match these commands with `type: untyped`.
+const untypedCommandType = "untyped"
+
+// ToolPolicy decides which commands of a repository are exposed as MCP tools.
+//
+// Each rule is a command filter, the same settings as the filter section of the
+// `commands filter` verb. The fields of a single rule are combined with AND.
+//
+//	allow:
+//	  - path-prefix: reports/
+//	  - tags: [read-only]
+//	deny:
+//	  - metadata-key: mutating
+//	    metadata-value: "true"
+//	confirm:
+//	  - tags: [expensive]
+//
+// If there are allow rules, a command must match at least one of them to be exposed.
+// Deny rules override allow rules. Commands matching a confirm rule are exposed,
+// but can only be called with a confirmed context (see mcp.ContextWithConfirmation).
+//
+// Paths are matched against the full tool path without leading slash, including the
+// mount path for multi-repositories.
+type ToolPolicy struct {
+	Allow   []*builder.FilterSettings `yaml:"allow,omitempty"`
+	Deny    []*builder.FilterSettings `yaml:"deny,omitempty"`
+	Confirm []*builder.FilterSettings `yaml:"confirm,omitempty"`
+}
+
+// ToolDecision is the outcome of evaluating a ToolPolicy for a single command.
+type ToolDecision struct {
+	Allowed              bool
+	RequiresConfirmation bool
+	Reason               string
+}
+
+// LoadToolPolicyFromFile reads a ToolPolicy from a YAML file.
+func LoadToolPolicyFromFile(path string) (*ToolPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read tool policy %s", path)
+	}
+	return LoadToolPolicyFromYAML(data)
+}
+
+// LoadToolPolicyFromYAML parses a ToolPolicy.
+func LoadToolPolicyFromYAML(data []byte) (*ToolPolicy, error) {
+	policy := &ToolPolicy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, errors.Wrap(err, "could not parse tool policy")
+	}
+	return policy, nil
+}
+
+// policyDescription returns a copy of the description of cmd, placed at path
+// and with a type, so that it can be indexed by the command filter.
+func policyDescription(path string, cmd cmds.Command) *cmds.CommandDescription {
+	desc := cmd.Description()
+	components := strings.Split(strings.Trim(path, "/"), "/")
+
+	ret := &cmds.CommandDescription{
+		Name:     components[len(components)-1],
+		Parents:  components[:len(components)-1],
+		Type:     desc.Type,
+		Tags:     desc.Tags,
+		Metadata: desc.Metadata,
+	}
+	if ret.Type == "" {
+		ret.Type = untypedCommandType
+	}
+	return ret
+}
+
+// matchRules returns the set of full paths matching any of the rules.
+func matchRules(
+	ctx context.Context,
+	index *command.CommandIndex,
+	descriptions []*cmds.CommandDescription,
+	rules []*builder.FilterSettings,
+) (map[string]bool, error) {
+	b := builder.New()
+	ret := map[string]bool{}
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		matches, err := index.Search(ctx, builder.BuildFilterFromSettings(rule, b), descriptions)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			ret[match.FullPath()] = true
+		}
+	}
+	return ret, nil
+}
+
+// Evaluate returns the decision for each command, keyed by the tool path it was passed with.
+// A nil policy allows everything.
+func (p *ToolPolicy) Evaluate(ctx context.Context, commands map[string]cmds.Command) (map[string]ToolDecision, error) {
+	ret := map[string]ToolDecision{}
+	if p == nil || (len(p.Allow) == 0 && len(p.Deny) == 0 && len(p.Confirm) == 0) {
+		for path := range commands {
+			ret[path] = ToolDecision{Allowed: true}
+		}
+		return ret, nil
+	}
+
+	descriptions := make([]*cmds.CommandDescription, 0, len(commands))
+	paths := map[string]string{}
+	for path, cmd := range commands {
+		desc := policyDescription(path, cmd)
+		descriptions = append(descriptions, desc)
+		paths[desc.FullPath()] = path
+	}
+
+	index, err := command.NewCommandIndex(descriptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not index commands for tool policy")
+	}
+	defer func() {
+		_ = index.Close()
+	}()
+
+	allowed, err := matchRules(ctx, index, descriptions, p.Allow)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := matchRules(ctx, index, descriptions, p.Deny)
+	if err != nil {
+		return nil, err
+	}
+	confirm, err := matchRules(ctx, index, descriptions, p.Confirm)
+	if err != nil {
+		return nil, err
+	}
+
+	for fullPath, path := range paths {
+		decision := ToolDecision{Allowed: true}
+		switch {
+		case denied[fullPath]:
+			decision = ToolDecision{Reason: "denied by tool policy"}
+		case len(p.Allow) > 0 && !allowed[fullPath]:
+			decision = ToolDecision{Reason: "not allowed by tool policy"}
+		case confirm[fullPath]:
+			decision.RequiresConfirmation = true
+		}
+		ret[path] = decision
+	}
+
+	return ret, nil
+}
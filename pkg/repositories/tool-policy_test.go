@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-go-golems/clay/pkg/filters/command/builder"
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToolPolicy = `
+allow:
+  - path-prefix: reports/
+  - tags: [read-only]
+deny:
+  - metadata-key: mutating
+    metadata-value: "yes"
+confirm:
+  - tags: [expensive]
+`
+
+func newPolicyTestRepository() *Repository {
+	r := NewRepository()
+
+	report := newEchoCommand([]string{"reports"}, "daily")
+	report.Type = "sql"
+
+	expensive := newEchoCommand([]string{"reports"}, "yearly")
+	expensive.Type = "sql"
+	expensive.Tags = []string{"expensive"}
+
+	readOnly := newEchoCommand([]string{"util"}, "echo")
+	readOnly.Tags = []string{"read-only"}
+
+	mutating := newEchoCommand([]string{"reports"}, "cleanup")
+	mutating.Type = "sql"
+	mutating.Metadata = map[string]interface{}{"mutating": "yes"}
+
+	other := newEchoCommand([]string{"admin"}, "drop")
+
+	r.Add(report, expensive, readOnly, mutating, other)
+	return r
+}
+
+func TestLoadToolPolicyFromYAML(t *testing.T) {
+	policy, err := LoadToolPolicyFromYAML([]byte(testToolPolicy))
+	require.NoError(t, err)
+
+	require.Len(t, policy.Allow, 2)
+	assert.Equal(t, "reports/", policy.Allow[0].PathPrefix)
+	assert.Equal(t, []string{"read-only"}, policy.Allow[1].Tags)
+	require.Len(t, policy.Deny, 1)
+	assert.Equal(t, "mutating", policy.Deny[0].MetadataKey)
+	require.Len(t, policy.Confirm, 1)
+}
+
+func TestToolProviderPolicy(t *testing.T) {
+	policy, err := LoadToolPolicyFromYAML([]byte(testToolPolicy))
+	require.NoError(t, err)
+
+	p := NewToolProvider(newPolicyTestRepository(), WithToolPolicy(policy))
+	ctx := context.Background()
+
+	tools, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+
+	confirmations := map[string]bool{}
+	for _, tool := range tools {
+		confirmations[tool.Name] = tool.RequiresConfirmation
+	}
+	assert.Equal(t, map[string]bool{
+		"reports__daily":  false,
+		"reports__yearly": true,
+		"util__echo":      false,
+	}, confirmations)
+
+	result, err := p.CallTool(ctx, "reports__daily", nil)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+
+	_, err = p.CallTool(ctx, "reports__yearly", nil)
+	toolCallError, ok := mcp.IsToolCallError(err)
+	require.True(t, ok, err)
+	assert.Equal(t, mcp.ToolCallErrorConfirmationRequired, toolCallError.Code)
+
+	result, err = p.CallTool(mcp.ContextWithConfirmation(ctx), "reports__yearly", nil)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+
+	// denied tools are not listed, so their name is unknown
+	_, err = p.CallTool(ctx, "reports__cleanup", nil)
+	toolCallError, ok = mcp.IsToolCallError(err)
+	require.True(t, ok, err)
+	assert.Equal(t, mcp.ToolCallErrorUnknownTool, toolCallError.Code)
+}
+
+func TestToolProviderPolicyDeniesRegisteredTool(t *testing.T) {
+	r := newPolicyTestRepository()
+	p := NewToolProvider(r)
+	ctx := context.Background()
+
+	_, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+
+	// tighten the policy after the tools have been listed
+	p.policy = &ToolPolicy{
+		Deny: []*builder.FilterSettings{{PathPrefix: "admin/"}},
+	}
+
+	_, err = p.CallTool(ctx, "admin__drop", nil)
+	toolCallError, ok := mcp.IsToolCallError(err)
+	require.True(t, ok, err)
+	assert.Equal(t, mcp.ToolCallErrorDenied, toolCallError.Code)
+
+	result, err := p.CallTool(ctx, "util__echo", nil)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+}
+
+func TestToolProviderEvaluatesPolicyOncePerLoad(t *testing.T) {
+	policy, err := LoadToolPolicyFromYAML([]byte(testToolPolicy))
+	require.NoError(t, err)
+
+	r := NewRepository()
+	echo := newEchoCommand([]string{"util"}, "echo")
+	echo.Tags = []string{"read-only"}
+	r.Add(echo)
+
+	p := NewToolProvider(r, WithToolPolicy(policy))
+	ctx := context.Background()
+
+	tools, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	assert.False(t, tools[0].RequiresConfirmation)
+
+	// the decisions are kept until the repository changes
+	echo.Tags = []string{"read-only", "expensive"}
+	_, decision, err := p.ResolveCommand(ctx, "util__echo")
+	require.NoError(t, err)
+	assert.False(t, decision.RequiresConfirmation)
+
+	r.Add(newEchoCommand([]string{"util"}, "other"))
+	_, decision, err = p.ResolveCommand(ctx, "util__echo")
+	require.NoError(t, err)
+	assert.True(t, decision.RequiresConfirmation)
+}
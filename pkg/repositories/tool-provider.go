@@ -0,0 +1,458 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/runner"
+	"github.com/go-go-golems/glazed/pkg/cmds/schema"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+	"github.com/go-go-golems/glazed/pkg/formatters/json"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// ToolProvider exposes the commands of a repository as MCP tools.
+//
+// The repositories list tools by their command path, which contains slashes
+// (and a leading slash for mounted repositories). ToolProvider encodes these
+// paths into names that MCP clients accept and keeps a lookup table so that
+// CallTool can resolve the names back to commands. The table is built from all
+// the tools allowed by the policy, and rebuilt when the repository changes, so
+// tools can be called without being listed first.
+type ToolProvider struct {
+	repository   RepositoryInterface
+	mapper       *mcp.ToolNameMapper
+	names        *toolNameTable
+	policy       *ToolPolicy
+	limits       ToolCallLimits
+	parseOptions []runner.ParseOption
+	callContext  func(ctx context.Context, cmd cmds.Command) (context.Context, error)
+	prompts      *PromptProvider
+
+	decisionsMutex sync.Mutex
+	// decisions caches the policy decisions for all commands of the repository, since
+	// evaluating the policy indexes the commands. It is valid for decisionsPolicy and
+	// decisionsChanges, see decide.
+	decisions        map[string]ToolDecision
+	decisionsPolicy  *ToolPolicy
+	decisionsChanges uint64
+}
+
+type ToolProviderOption func(*ToolProvider)
+
+// WithToolNameMapper sets the mapper used to encode and resolve tool names.
+func WithToolNameMapper(mapper *mcp.ToolNameMapper) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.mapper = mapper
+	}
+}
+
+// WithToolPolicy sets the policy deciding which commands are exposed and
+// which ones require confirmation.
+func WithToolPolicy(policy *ToolPolicy) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.policy = policy
+	}
+}
+
+// WithToolCallLimits sets the default execution limits of tool calls.
+// Commands can override them with the tool-limits metadata.
+func WithToolCallLimits(limits ToolCallLimits) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.limits = limits
+	}
+}
+
+// WithToolParseOptions adds parse options (additional middlewares, env prefix, ...)
+// used when parsing the values of a called command.
+func WithToolParseOptions(options ...runner.ParseOption) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.parseOptions = append(p.parseOptions, options...)
+	}
+}
+
+// WithToolCallContext sets a function preparing the context a command runs with, for
+// example to attach a query cache if the metadata of the command opts into it.
+func WithToolCallContext(f func(ctx context.Context, cmd cmds.Command) (context.Context, error)) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.callContext = f
+	}
+}
+
+// WithToolExcludedPrompts leaves the commands published as prompts by prompts out of the
+// tools, so that prompt templates aren't offered to the model as tools as well.
+func WithToolExcludedPrompts(prompts *PromptProvider) ToolProviderOption {
+	return func(p *ToolProvider) {
+		p.prompts = prompts
+	}
+}
+
+func NewToolProvider(repository RepositoryInterface, options ...ToolProviderOption) *ToolProvider {
+	ret := &ToolProvider{
+		repository: repository,
+		limits:     DefaultToolCallLimits,
+	}
+	for _, opt := range options {
+		opt(ret)
+	}
+	if ret.mapper == nil {
+		ret.mapper = mcp.NewToolNameMapper()
+	}
+	ret.names = &toolNameTable{
+		repository: repository,
+		mapper:     ret.mapper,
+		filter:     ret.allowedPaths,
+	}
+	return ret
+}
+
+// allowedPaths returns the paths of the commands allowed by the policy, leaving out
+// the excluded prompts.
+func (p *ToolProvider) allowedPaths(ctx context.Context, commands map[string]cmds.Command) ([]string, error) {
+	decisions, err := p.decide(ctx, commands)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(commands))
+	for path, cmd := range commands {
+		if p.prompts != nil && p.prompts.IsPrompt(cmd) {
+			continue
+		}
+		if decisions[path].Allowed {
+			ret = append(ret, path)
+		}
+	}
+	return ret, nil
+}
+
+var _ mcp.ToolProvider = (*ToolProvider)(nil)
+
+// decide returns the policy decisions for commands. For repositories implementing
+// ChangeCounter, the policy is evaluated once for all the commands of the repository,
+// and again when the repository or the policy changes. Other repositories are
+// evaluated on every call.
+func (p *ToolProvider) decide(ctx context.Context, commands map[string]cmds.Command) (map[string]ToolDecision, error) {
+	counter, ok := p.repository.(ChangeCounter)
+	if !ok {
+		return p.policy.Evaluate(ctx, commands)
+	}
+
+	p.decisionsMutex.Lock()
+	defer p.decisionsMutex.Unlock()
+
+	changes := counter.Changes()
+	if p.decisions == nil || p.decisionsPolicy != p.policy || p.decisionsChanges != changes {
+		all, err := collectAllToolCommands(ctx, p.repository)
+		if err != nil {
+			return nil, err
+		}
+		decisions, err := p.policy.Evaluate(ctx, all)
+		if err != nil {
+			return nil, err
+		}
+		p.decisions = decisions
+		p.decisionsPolicy = p.policy
+		p.decisionsChanges = changes
+	}
+
+	ret := make(map[string]ToolDecision, len(commands))
+	missing := map[string]cmds.Command{}
+	for path, cmd := range commands {
+		if decision, ok := p.decisions[path]; ok {
+			ret[path] = decision
+		} else {
+			missing[path] = cmd
+		}
+	}
+	if len(missing) > 0 {
+		decisions, err := p.policy.Evaluate(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for path, decision := range decisions {
+			ret[path] = decision
+		}
+	}
+	return ret, nil
+}
+
+// ListTools returns the tools of the repository allowed by the policy,
+// renamed with the tool name encoder. Listing the first page rebuilds the names.
+func (p *ToolProvider) ListTools(ctx context.Context, cursor string) ([]mcp.Tool, string, error) {
+	if cursor == "" {
+		if err := p.names.rebuild(ctx); err != nil {
+			return nil, "", err
+		}
+	} else if err := p.names.refresh(ctx); err != nil {
+		return nil, "", err
+	}
+
+	tools, commands, nextCursor, err := collectToolCommands(ctx, p.repository, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	decisions, err := p.decide(ctx, commands)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		decision := decisions[tool.Name]
+		if !decision.Allowed {
+			continue
+		}
+		name, ok := p.names.name(tool.Name)
+		if !ok {
+			continue
+		}
+		tool.Name = name
+		tool.RequiresConfirmation = decision.RequiresConfirmation
+		ret = append(ret, tool)
+	}
+
+	return ret, nextCursor, nil
+}
+
+// ResolveCommand returns the command behind an encoded tool name, as well as
+// the policy decision for it.
+func (p *ToolProvider) ResolveCommand(ctx context.Context, name string) (cmds.Command, ToolDecision, error) {
+	path, cmd, ok, err := p.names.resolve(ctx, name)
+	if err != nil {
+		return nil, ToolDecision{}, err
+	}
+	if !ok {
+		return nil, ToolDecision{}, &mcp.ToolCallError{Tool: name, Code: mcp.ToolCallErrorUnknownTool}
+	}
+
+	decisions, err := p.decide(ctx, map[string]cmds.Command{path: cmd})
+	if err != nil {
+		return nil, ToolDecision{}, err
+	}
+	return cmd, decisions[path], nil
+}
+
+// CallTool resolves the tool name, parses the arguments into the default section of the
+// command and runs it. Errors while running the command are returned as a ToolResult
+// with IsError set, so that the client can show them to the model.
+//
+// Calls to unknown tools, tools denied by the policy and unconfirmed calls to tools
+// requiring confirmation return a *mcp.ToolCallError.
+func (p *ToolProvider) CallTool(ctx context.Context, name string, arguments map[string]interface{}) (*mcp.ToolResult, error) {
+	cmd, decision, err := p.ResolveCommand(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Allowed {
+		return nil, &mcp.ToolCallError{Tool: name, Code: mcp.ToolCallErrorDenied, Reason: decision.Reason}
+	}
+	if decision.RequiresConfirmation && !mcp.IsConfirmed(ctx) {
+		return nil, &mcp.ToolCallError{
+			Tool:   name,
+			Code:   mcp.ToolCallErrorConfirmationRequired,
+			Reason: "the call has to be confirmed by the user",
+		}
+	}
+
+	limits, err := p.limits.ForCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedValues, err := parseCommandArguments(cmd, arguments, p.parseOptions)
+	if err != nil {
+		return newErrorToolResult(err), nil
+	}
+
+	if p.callContext != nil {
+		ctx, err = p.callContext(ctx, cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	text, truncation, err := runCommandToText(ctx, cmd, parsedValues, limits)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = errors.Errorf("tool call %s timed out after %s", name, time.Since(start).Round(time.Millisecond))
+		}
+		return newErrorToolResult(err), nil
+	}
+
+	ret := &mcp.ToolResult{
+		Content: []mcp.ToolContent{
+			{Type: "text", Text: text},
+		},
+	}
+	// the marker is a content item of its own, so that the output stays valid JSON
+	if truncation != "" {
+		ret.Content = append(ret.Content, mcp.ToolContent{Type: "text", Text: truncation})
+		ret.Truncated = true
+	}
+	return ret, nil
+}
+
+// collectToolCommands lists the tools of repository and looks up the command behind
+// each of them, keyed by tool path.
+func collectToolCommands(
+	ctx context.Context,
+	repository RepositoryInterface,
+	cursor string,
+) ([]mcp.Tool, map[string]cmds.Command, string, error) {
+	tools, nextCursor, err := repository.ListTools(ctx, cursor)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	commands := map[string]cmds.Command{}
+	for _, tool := range tools {
+		cmd, ok := repository.GetCommand(tool.Name)
+		if !ok {
+			return nil, nil, "", errors.Errorf("command %s for tool not found", tool.Name)
+		}
+		commands[tool.Name] = cmd
+	}
+
+	return tools, commands, nextCursor, nil
+}
+
+// collectAllToolCommands returns the commands of all the tools of repository, keyed by tool path.
+func collectAllToolCommands(ctx context.Context, repository RepositoryInterface) (map[string]cmds.Command, error) {
+	ret := map[string]cmds.Command{}
+	cursor := ""
+	for {
+		_, commands, nextCursor, err := collectToolCommands(ctx, repository, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for path, cmd := range commands {
+			ret[path] = cmd
+		}
+		if nextCursor == "" {
+			return ret, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// parseCommandArguments parses arguments into the default section of cmd.
+func parseCommandArguments(
+	cmd cmds.Command,
+	arguments map[string]interface{},
+	parseOptions []runner.ParseOption,
+) (*values.Values, error) {
+	options := append([]runner.ParseOption{}, parseOptions...)
+	options = append(options, runner.WithValuesForSections(map[string]map[string]interface{}{
+		schema.DefaultSlug: arguments,
+	}))
+	return runner.ParseCommandValues(cmd, options...)
+}
+
+// runCommandToText runs cmd and returns its output as text, cut to the limits. Glaze
+// commands are stopped after limits.MaxRows rows, and their output is cut to the rows
+// that fit into limits.MaxBytes. The output of other commands is cut at a line boundary.
+// If the output was cut, truncation describes what was left out.
+func runCommandToText(
+	ctx context.Context,
+	cmd cmds.Command,
+	parsedValues *values.Values,
+	limits ToolCallLimits,
+) (text string, truncation string, err error) {
+	switch c := cmd.(type) {
+	case cmds.GlazeCommand:
+		// the null table middleware makes the processor keep the rows in its table
+		gp := middlewares.NewTableProcessor(middlewares.WithTableMiddleware(&table.NullTableMiddleware{}))
+		limiter := &rowLimitProcessor{Processor: gp, maxRows: limits.MaxRows}
+		err = c.RunIntoGlazeProcessor(ctx, parsedValues, limiter)
+		if err != nil && !errors.Is(err, errRowLimitReached) {
+			return "", "", err
+		}
+		if err = gp.Close(ctx); err != nil {
+			return "", "", err
+		}
+
+		table_ := gp.GetTable()
+		text, rows, err := formatRowsWithin(ctx, table_, limits.MaxBytes)
+		if err != nil {
+			return "", "", err
+		}
+		switch {
+		case rows < len(table_.Rows):
+			truncation = fmt.Sprintf("[output truncated after %d rows to fit into %d bytes]", rows, limits.MaxBytes)
+		case limiter.limitReached:
+			truncation = fmt.Sprintf("[output truncated after %d rows]", limiter.rows)
+		}
+		return text, truncation, nil
+
+	default:
+		buf := &bytes.Buffer{}
+		err = runner.RunCommand(ctx, cmd, parsedValues, runner.WithWriter(buf))
+		if err != nil {
+			return "", "", err
+		}
+		text, truncated := truncateOutput(buf.String(), limits.MaxBytes)
+		if truncated {
+			truncation = fmt.Sprintf("[output truncated: %d of %d bytes]", len(text), buf.Len())
+		}
+		return text, truncation, nil
+	}
+}
+
+// formatRowsWithin formats the first rows of table_ as JSON, as many as fit into maxBytes
+// (if > 0), and returns the number of formatted rows.
+func formatRowsWithin(ctx context.Context, table_ *types.Table, maxBytes int) (string, int, error) {
+	format := func(rows int) (string, error) {
+		buf := &bytes.Buffer{}
+		t := &types.Table{Columns: table_.Columns, Rows: table_.Rows[:rows]}
+		if err := json.NewOutputFormatter().OutputTable(ctx, t, buf); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	text, err := format(len(table_.Rows))
+	if err != nil || maxBytes <= 0 || len(text) <= maxBytes {
+		return text, len(table_.Rows), err
+	}
+
+	// the largest number of rows whose output fits, found by bisection
+	low, high := 0, len(table_.Rows)-1
+	for low < high {
+		mid := (low + high + 1) / 2
+		text, err = format(mid)
+		if err != nil {
+			return "", 0, err
+		}
+		if len(text) <= maxBytes {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	text, err = format(low)
+	return text, low, err
+}
+
+func newErrorToolResult(err error) *mcp.ToolResult {
+	return &mcp.ToolResult{
+		Content: []mcp.ToolContent{
+			{Type: "text", Text: err.Error()},
+		},
+		IsError: true,
+	}
+}
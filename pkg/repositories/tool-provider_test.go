@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-go-golems/clay/pkg/repositories/mcp"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type echoSettings struct {
+	Message string `glazed:"message"`
+}
+
+type echoCommand struct {
+	*cmds.CommandDescription
+}
+
+func newEchoCommand(parents []string, name string) *echoCommand {
+	return &echoCommand{
+		CommandDescription: cmds.NewCommandDescription(name,
+			cmds.WithShort("Echo a message"),
+			cmds.WithParents(parents...),
+			cmds.WithFlags(
+				fields.New("message", fields.TypeString, fields.WithDefault("hello")),
+			),
+		),
+	}
+}
+
+func (e *echoCommand) RunIntoGlazeProcessor(ctx context.Context, parsedValues *values.Values, gp middlewares.Processor) error {
+	s := &echoSettings{}
+	if err := parsedValues.DecodeSectionInto("default", s); err != nil {
+		return err
+	}
+	return gp.AddRow(ctx, types.NewRow(types.MRP("message", s.Message)))
+}
+
+var _ cmds.GlazeCommand = (*echoCommand)(nil)
+
+func TestToolProviderListAndCallTool(t *testing.T) {
+	r := NewRepository()
+	r.Add(newEchoCommand([]string{"util", "text"}, "echo"))
+
+	p := NewToolProvider(r)
+	ctx := context.Background()
+
+	tools, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "util__text__echo", tools[0].Name)
+
+	result, err := p.CallTool(ctx, "util__text__echo", map[string]interface{}{
+		"message": "hi there",
+	})
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content)
+	require.Len(t, result.Content, 1)
+	assert.Contains(t, result.Content[0].Text, "hi there")
+
+	_, err = p.CallTool(ctx, "util/text/echo", nil)
+	assert.Error(t, err)
+}
+
+type callContextKey struct{}
+
+type contextEchoCommand struct {
+	*echoCommand
+}
+
+func (e *contextEchoCommand) RunIntoGlazeProcessor(ctx context.Context, parsedValues *values.Values, gp middlewares.Processor) error {
+	message, _ := ctx.Value(callContextKey{}).(string)
+	return gp.AddRow(ctx, types.NewRow(types.MRP("message", message)))
+}
+
+func TestToolProviderCallContext(t *testing.T) {
+	r := NewRepository()
+	r.Add(&contextEchoCommand{newEchoCommand(nil, "echo")})
+
+	var called []string
+	p := NewToolProvider(r, WithToolCallContext(func(ctx context.Context, cmd cmds.Command) (context.Context, error) {
+		called = append(called, cmd.Description().Name)
+		return context.WithValue(ctx, callContextKey{}, "from context"), nil
+	}))
+	ctx := context.Background()
+	_, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+
+	result, err := p.CallTool(ctx, "echo", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content)
+	assert.Contains(t, result.Content[0].Text, "from context")
+	assert.Equal(t, []string{"echo"}, called)
+}
+
+func TestToolProviderResolvesWithoutListing(t *testing.T) {
+	r := NewRepository()
+	r.Add(newEchoCommand([]string{"util"}, "echo"))
+
+	p := NewToolProvider(r)
+	ctx := context.Background()
+
+	result, err := p.CallTool(ctx, "util__echo", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content)
+
+	// commands added later can be called right away
+	r.Add(newEchoCommand([]string{"util"}, "other"))
+	result, err = p.CallTool(ctx, "util__other", nil)
+	require.NoError(t, err)
+	require.False(t, result.IsError, result.Content)
+}
+
+func TestToolProviderRebuildsNamesOnReload(t *testing.T) {
+	r := NewRepository()
+	r.Add(newEchoCommand([]string{"util"}, "echo"))
+
+	p := NewToolProvider(r)
+	ctx := context.Background()
+
+	tools, _, err := p.ListTools(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	assert.Equal(t, "util__echo", tools[0].Name)
+
+	// a top-level "util__echo" command encodes to the same name, so both commands get a hash suffix
+	r.Add(newEchoCommand(nil, "util__echo"))
+
+	_, err = p.CallTool(ctx, "util__echo", nil)
+	toolCallError, ok := mcp.IsToolCallError(err)
+	require.True(t, ok, err)
+	assert.Equal(t, mcp.ToolCallErrorUnknownTool, toolCallError.Code)
+
+	tools, _, err = p.ListTools(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+
+	// the names don't depend on the order in which the commands were added
+	r2 := NewRepository()
+	r2.Add(newEchoCommand(nil, "util__echo"), newEchoCommand([]string{"util"}, "echo"))
+	tools2, _, err := NewToolProvider(r2).ListTools(ctx, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, toolNames(tools), toolNames(tools2))
+
+	for _, tool := range tools {
+		assert.True(t, strings.HasPrefix(tool.Name, "util__echo_"), tool.Name)
+		result, err := p.CallTool(ctx, tool.Name, nil)
+		require.NoError(t, err)
+		assert.False(t, result.IsError, result.Content)
+	}
+
+	r.Remove([]string{"util", "echo"})
+	_, err = p.CallTool(ctx, tools[0].Name, nil)
+	assert.Error(t, err)
+}
+
+func toolNames(tools []mcp.Tool) []string {
+	ret := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		ret = append(ret, tool.Name)
+	}
+	return ret
+}
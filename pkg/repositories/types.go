@@ -36,6 +36,20 @@ type RepositoryInterface interface {
 	// ListTools returns all commands as tools for MCP compatibility
 	ListTools(ctx context.Context, cursor string) ([]mcp.Tool, string, error)
 
+	// ListResources returns help sections and command sources as MCP resources
+	ListResources(ctx context.Context, cursor string) ([]mcp.Resource, string, error)
+
+	// ReadResource returns the content of the resource with the given URI
+	ReadResource(ctx context.Context, uri string) ([]mcp.ResourceContent, error)
+
 	// Watch sets up file system watching for the repository
 	Watch(ctx context.Context, options ...watcher.Option) error
 }
+
+// ChangeCounter is implemented by repositories that count the changes to their commands,
+// for example when they are reloaded by LoadCommands or Watch. The tool and prompt
+// providers rebuild their name tables when the count changes.
+type ChangeCounter interface {
+	// Changes returns a value that changes whenever commands are added or removed.
+	Changes() uint64
+}
@@ -0,0 +1,140 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/go-go-golems/glazed/pkg/helpers/cast"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// QueryArgs collects the values bound by the template functions while rendering a query.
+// Instead of splicing values into the SQL text, the functions emit a placeholder in the
+// style of bindType (sqlx.QUESTION, sqlx.DOLLAR, sqlx.NAMED or sqlx.AT) and record the value,
+// so that the query can be executed with real parameters.
+type QueryArgs struct {
+	bindType int
+	args     []interface{}
+}
+
+func NewQueryArgs(bindType int) *QueryArgs {
+	return &QueryArgs{bindType: bindType}
+}
+
+// BindTypeForDB returns the placeholder style of the driver of db.
+// Drivers unknown to sqlx (for example duckdb) and a nil db use `?`.
+func BindTypeForDB(db *sqlx.DB) int {
+	if db == nil {
+		return sqlx.QUESTION
+	}
+	bindType := sqlx.BindType(db.DriverName())
+	if bindType == sqlx.UNKNOWN {
+		return sqlx.QUESTION
+	}
+	return bindType
+}
+
+// Bind records value and returns its placeholder.
+// Named placeholders are called p1, p2, ... and bound as sql.NamedArg.
+func (a *QueryArgs) Bind(value interface{}) string {
+	n := len(a.args) + 1
+	name := fmt.Sprintf("p%d", n)
+	switch a.bindType {
+	case sqlx.DOLLAR:
+		a.args = append(a.args, value)
+		return fmt.Sprintf("$%d", n)
+	case sqlx.NAMED:
+		a.args = append(a.args, sql.Named(name, value))
+		return ":" + name
+	case sqlx.AT:
+		a.args = append(a.args, sql.Named(name, value))
+		return "@" + name
+	default:
+		a.args = append(a.args, value)
+		return "?"
+	}
+}
+
+// BindList binds every value and returns the comma-separated placeholders.
+// An empty list renders as NULL, so that `x IN ({{ sqlIn .list }})` stays valid
+// and matches no rows.
+func (a *QueryArgs) BindList(values []interface{}) string {
+	if len(values) == 0 {
+		return "NULL"
+	}
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = a.Bind(v)
+	}
+	return strings.Join(placeholders, ",")
+}
+
+// Args returns the values bound so far, in placeholder order.
+func (a *QueryArgs) Args() []interface{} {
+	return a.args
+}
+
+// bindLikeContains binds a LIKE pattern matching strings containing value.
+func (a *QueryArgs) bindLikeContains(value string) string {
+	return a.Bind("%"+EscapeLike(value)+"%") + likeEscapeClause
+}
+
+// TemplateFuncs returns the value functions of CreateTemplate, rewritten to bind
+// their values instead of interpolating them.
+func (a *QueryArgs) TemplateFuncs() template.FuncMap {
+	return a.TemplateFuncsForDialect(DialectANSI)
+}
+
+// TemplateFuncsForDialect returns the value functions of CreateTemplate, rewritten to bind
+// their values instead of interpolating them. sqlDate and sqlDateTime format the dates
+// for dialect.
+func (a *QueryArgs) TemplateFuncsForDialect(dialect *Dialect) template.FuncMap {
+	bindDate := func(format func(date interface{}) (string, error)) func(date interface{}) (string, error) {
+		return func(date interface{}) (string, error) {
+			s, err := format(date)
+			if err != nil {
+				return "", err
+			}
+			return a.Bind(s), nil
+		}
+	}
+	bindDateLayouts := func(fullFormat string, defaultFormat string) func(date interface{}) (string, error) {
+		return bindDate(func(date interface{}) (string, error) {
+			return formatSqlDate(date, fullFormat, defaultFormat)
+		})
+	}
+
+	return template.FuncMap{
+		"sqlArg":        a.Bind,
+		"sqlString":     func(value string) string { return a.Bind(value) },
+		"sqlStringLike": a.bindLikeContains,
+		"sqlLike":       a.bindLikeContains,
+		"sqlStringIn": func(values interface{}) (string, error) {
+			strList, err := cast.CastListToStringList(values)
+			if err != nil {
+				return "", errors.Errorf("could not cast %v to []string", values)
+			}
+			list := make([]interface{}, len(strList))
+			for i, s := range strList {
+				list[i] = s
+			}
+			return a.BindList(list), nil
+		},
+		"sqlIn": a.BindList,
+		"sqlIntIn": func(values interface{}) string {
+			intList, _ := cast.CastInterfaceToIntList[int64](values)
+			list := make([]interface{}, len(intList))
+			for i, v := range intList {
+				list[i] = v
+			}
+			return a.BindList(list)
+		},
+		"sqlDate":        bindDate(dialect.FormatDate),
+		"sqlDateTime":    bindDate(dialect.FormatDateTime),
+		"sqliteDate":     bindDateLayouts("2006-01-02", "2006-01-02"),
+		"sqliteDateTime": bindDateLayouts("2006-01-02 15:04:05", "2006-01-02 15:04:05"),
+	}
+}
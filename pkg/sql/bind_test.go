@@ -0,0 +1,171 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestRenderQueryBindsValues(t *testing.T) {
+	query := `SELECT * FROM items
+WHERE name = {{ .name | sqlString }}
+  AND id IN ({{ sqlIntIn .ids }})
+  AND label LIKE {{ sqlLike .label }}`
+	data := map[string]interface{}{
+		"name":  "it's",
+		"ids":   []int{1, 2},
+		"label": "foo",
+	}
+	wantArgs := []interface{}{"it's", int64(1), int64(2), "%foo%"}
+
+	tests := []struct {
+		name      string
+		bindType  int
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:     "question",
+			bindType: sqlx.QUESTION,
+			wantQuery: `SELECT * FROM items
+WHERE name = ?
+  AND id IN (?,?)
+  AND label LIKE ? ESCAPE '!'`,
+			wantArgs: wantArgs,
+		},
+		{
+			name:     "dollar",
+			bindType: sqlx.DOLLAR,
+			wantQuery: `SELECT * FROM items
+WHERE name = $1
+  AND id IN ($2,$3)
+  AND label LIKE $4 ESCAPE '!'`,
+			wantArgs: wantArgs,
+		},
+		{
+			name:     "named",
+			bindType: sqlx.NAMED,
+			wantQuery: `SELECT * FROM items
+WHERE name = :p1
+  AND id IN (:p2,:p3)
+  AND label LIKE :p4 ESCAPE '!'`,
+			wantArgs: []interface{}{
+				sql.Named("p1", "it's"),
+				sql.Named("p2", int64(1)),
+				sql.Named("p3", int64(2)),
+				sql.Named("p4", "%foo%"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := RenderQuery(context.Background(), nil, query, nil, data, WithBindType(tt.bindType))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.wantQuery {
+				t.Errorf("got query %q, want %q", got, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("got args %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRenderQueryInterpolated(t *testing.T) {
+	got, args, err := RenderQuery(
+		context.Background(), nil,
+		"SELECT * FROM items WHERE id IN ({{ sqlIntIn .ids }}) AND name = {{ sqlArg .name }}",
+		nil,
+		map[string]interface{}{"ids": []int{1, 2}, "name": "it's"},
+		WithInterpolatedValues(),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "SELECT * FROM items WHERE id IN (1,2) AND name = 'it''s'"; got != want {
+		t.Errorf("got query %q, want %q", got, want)
+	}
+	if args != nil {
+		t.Errorf("expected no args, got %v", args)
+	}
+}
+
+func TestRenderInterpolatedQuery(t *testing.T) {
+	got, err := RenderInterpolatedQuery(
+		context.Background(), nil,
+		"SELECT * FROM items WHERE name IN ({{ sqlStringIn .names }})",
+		nil,
+		map[string]interface{}{"names": []string{"a", "b"}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "SELECT * FROM items WHERE name IN ('a','b')"; got != want {
+		t.Errorf("got query %q, want %q", got, want)
+	}
+}
+
+func TestRunQueryUsesBoundArguments(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	for _, stmt := range []string{
+		"CREATE TABLE items (id INTEGER, name TEXT)",
+		"INSERT INTO items VALUES (1, 'foo'), (2, 'bar'), (3, 'baz')",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("could not set up database: %v", err)
+		}
+	}
+
+	subQueries := map[string]string{
+		"ids": "SELECT id FROM items WHERE name IN ({{ sqlStringIn .names }}) ORDER BY id",
+	}
+	data := map[string]interface{}{
+		"names":     []string{"foo", "baz"},
+		"injection": "' OR 1=1 --",
+	}
+
+	query, args, err := RenderQuery(ctx, db,
+		`SELECT name FROM items WHERE id IN ({{ sqlIn (sqlColumn (subQuery "ids")) }})
+{{ if .injection }}AND name != {{ .injection | sqlString }}{{ end }}
+ORDER BY id`,
+		subQueries, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(args) != 3 {
+		t.Fatalf("expected 3 args, got %v", args)
+	}
+
+	var names []string
+	if err := db.SelectContext(ctx, &names, query, args...); err != nil {
+		t.Fatalf("could not run query %s: %v", query, err)
+	}
+	if want := []string{"foo", "baz"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	_, rows, err := RunQuery(ctx, nil, "SELECT id FROM items WHERE name = {{ .injection | sqlString }}", data, db)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	if rows.Next() {
+		t.Errorf("expected bound string to match no rows")
+	}
+}
@@ -0,0 +1,329 @@
+package sql
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-go-golems/clay/pkg/memoization"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// QueryCacheKey identifies a query result: the connection, the rendered SQL and its
+// arguments, and the kind of result (glaze rows, or the value of a template function).
+type QueryCacheKey struct {
+	Connection string
+	Kind       string
+	Query      string
+	Args       []interface{}
+}
+
+func (k QueryCacheKey) Hash() string {
+	h := sha256.New()
+	for _, s := range []string{k.Connection, k.Kind, k.Query} {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	for _, arg := range k.Args {
+		s := fmt.Sprintf("%T:%v", arg, arg)
+		_, _ = fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+type queryCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// QueryCacheStats are the counters of a QueryCache.
+type QueryCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Expired   int64
+	Evictions int
+	Size      int
+}
+
+// QueryCache is an LRU cache of query results, keyed by connection, rendered SQL and arguments.
+// It is used by RunQueryIntoGlaze (see WithQueryCache) and by the sqlColumn, sqlSlice,
+// sqlSingle and sqlMap template functions (see ContextWithQueryCache).
+type QueryCache struct {
+	cache      *memoization.MemoCache[QueryCacheKey, *queryCacheEntry]
+	defaultTTL time.Duration
+	now        func() time.Time
+
+	mutex   sync.Mutex
+	hits    int64
+	misses  int64
+	expired int64
+}
+
+type QueryCacheOption func(*QueryCache)
+
+// WithDefaultTTL sets the time to live of the entries cached without a TTL.
+// Zero (the default) keeps them until they are evicted.
+func WithDefaultTTL(ttl time.Duration) QueryCacheOption {
+	return func(c *QueryCache) {
+		c.defaultTTL = ttl
+	}
+}
+
+// NewQueryCache creates a cache holding the results of up to capacity queries.
+func NewQueryCache(capacity int, options ...QueryCacheOption) *QueryCache {
+	ret := &QueryCache{
+		cache: memoization.NewMemoCache[QueryCacheKey, *queryCacheEntry](capacity),
+		now:   time.Now,
+	}
+	for _, o := range options {
+		o(ret)
+	}
+	return ret
+}
+
+func (c *QueryCache) Stats() QueryCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return QueryCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Expired:   c.expired,
+		Evictions: c.cache.Evictions(),
+		Size:      c.cache.Size(),
+	}
+}
+
+// get returns the cached value for key, if present and not expired.
+func (c *QueryCache) get(key QueryCacheKey) (interface{}, bool) {
+	entry, ok := c.cache.Get(key)
+	isExpired := ok && !entry.expires.IsZero() && !c.now().Before(entry.expires)
+	if isExpired {
+		c.cache.Delete(key)
+	}
+
+	c.mutex.Lock()
+	switch {
+	case isExpired:
+		c.expired++
+		c.misses++
+	case ok:
+		c.hits++
+	default:
+		c.misses++
+	}
+	c.mutex.Unlock()
+
+	c.logAccess(key, ok && !isExpired)
+	if !ok || isExpired {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// set caches value for ttl, or the default TTL of the cache if ttl is zero.
+func (c *QueryCache) set(key QueryCacheKey, value interface{}, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = c.defaultTTL
+	}
+	entry := &queryCacheEntry{value: value}
+	if ttl > 0 {
+		entry.expires = c.now().Add(ttl)
+	}
+	c.cache.Set(key, entry)
+}
+
+func (c *QueryCache) logAccess(key QueryCacheKey, hit bool) {
+	stats := c.Stats()
+	log.Debug().
+		Str("kind", key.Kind).
+		Bool("hit", hit).
+		Int64("hits", stats.Hits).
+		Int64("misses", stats.Misses).
+		Int64("expired", stats.Expired).
+		Int("evictions", stats.Evictions).
+		Int("size", stats.Size).
+		Msg("Query cache lookup")
+}
+
+const (
+	// MetadataCache is the command metadata key to opt into caching, e.g. cache: true
+	MetadataCache = "cache"
+	// MetadataCacheTTL is the command metadata key of the TTL of the cached results, as a
+	// Go duration, e.g. cache-ttl: 10m. It implies cache: true.
+	MetadataCacheTTL = "cache-ttl"
+)
+
+// CacheSettingsFromMetadata returns whether the command with the given metadata opted into
+// result caching, and the TTL of its results (zero for the default TTL of the cache).
+func CacheSettingsFromMetadata(metadata map[string]interface{}) (bool, time.Duration, error) {
+	enabled := false
+	switch v := metadata[MetadataCache].(type) {
+	case nil:
+	case bool:
+		enabled = v
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, 0, errors.Errorf("metadata %s must be a boolean, got %q", MetadataCache, v)
+		}
+		enabled = b
+	default:
+		return false, 0, errors.Errorf("metadata %s must be a boolean, got %v", MetadataCache, v)
+	}
+
+	ttl := time.Duration(0)
+	if v, ok := metadata[MetadataCacheTTL]; ok && v != nil {
+		var err error
+		ttl, err = parseDurationSetting("metadata "+MetadataCacheTTL, fmt.Sprintf("%v", v))
+		if err != nil {
+			return false, 0, err
+		}
+		// an explicit cache: false wins
+		if _, hasCache := metadata[MetadataCache]; !hasCache {
+			enabled = true
+		}
+	}
+
+	return enabled, ttl, nil
+}
+
+// ContextForCommand returns ctx with the cache attached (see ContextWithQueryCache) if the
+// metadata of cmd opts into caching, with the TTL of its metadata. It can be passed to
+// repositories.WithToolCallContext to cache the queries of tool calls.
+func (c *QueryCache) ContextForCommand(ctx context.Context, cmd cmds.Command) (context.Context, error) {
+	description := cmd.Description()
+	enabled, ttl, err := CacheSettingsFromMetadata(description.Metadata)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid cache metadata for %s", description.FullPath())
+	}
+	if !enabled {
+		return ctx, nil
+	}
+	return ContextWithQueryCache(ctx, c, ttl), nil
+}
+
+type queryCacheContextKey struct{}
+
+type queryCacheContext struct {
+	cache *QueryCache
+	ttl   time.Duration
+}
+
+// ContextWithQueryCache makes the sub-query template functions (sqlColumn, sqlSlice, sqlSingle,
+// sqlMap) of templates created with ctx, and RunQueryIntoGlaze and RunNamedQueryIntoGlaze
+// called with ctx without WithQueryCache, cache their results in cache, for ttl (zero for
+// the default TTL of the cache).
+func ContextWithQueryCache(ctx context.Context, cache *QueryCache, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, queryCacheContextKey{}, &queryCacheContext{cache: cache, ttl: ttl})
+}
+
+func queryCacheFromContext(ctx context.Context) (*QueryCache, time.Duration) {
+	if c, ok := ctx.Value(queryCacheContextKey{}).(*queryCacheContext); ok && c.cache != nil {
+		return c.cache, c.ttl
+	}
+	return nil, 0
+}
+
+// WithQueryCache makes RunQueryIntoGlaze and RunNamedQueryIntoGlaze replay the rows of an
+// identical query from cache, and cache the rows of successful queries for ttl (zero for the
+// default TTL of the cache).
+func WithQueryCache(cache *QueryCache, ttl time.Duration) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.cache = cache
+		s.cacheTTL = ttl
+	}
+}
+
+// withContextCache uses the cache of ctx (see ContextWithQueryCache) if WithQueryCache
+// isn't set.
+func (s *queryResultSettings) withContextCache(ctx context.Context) *queryResultSettings {
+	if s.cache == nil {
+		s.cache, s.cacheTTL = queryCacheFromContext(ctx)
+	}
+	return s
+}
+
+// cachedRows are the rows and column types of a query, as passed to the processor.
+type cachedRows struct {
+	columns []*ColumnType
+	rows    []types.Row
+}
+
+// replay passes the cached rows to gp.
+func (r *cachedRows) replay(ctx context.Context, gp middlewares.Processor) error {
+	if receiver, ok := gp.(ColumnTypesReceiver); ok && r.columns != nil {
+		if err := receiver.SetColumnTypes(ctx, r.columns); err != nil {
+			return err
+		}
+	}
+	for _, row := range r.rows {
+		// processors may modify the rows they receive
+		if err := gp.AddRow(ctx, types.NewRowFromRow(row)); err != nil {
+			return errors.Wrapf(err, "Could not process input object")
+		}
+	}
+	return nil
+}
+
+// recordingProcessor records the rows and column types passed to the wrapped processor.
+type recordingProcessor struct {
+	middlewares.Processor
+	recorded cachedRows
+}
+
+func (p *recordingProcessor) AddRow(ctx context.Context, row types.Row) error {
+	p.recorded.rows = append(p.recorded.rows, types.NewRowFromRow(row))
+	return p.Processor.AddRow(ctx, row)
+}
+
+func (p *recordingProcessor) SetColumnTypes(ctx context.Context, columns []*ColumnType) error {
+	p.recorded.columns = columns
+	if receiver, ok := p.Processor.(ColumnTypesReceiver); ok {
+		return receiver.SetColumnTypes(ctx, columns)
+	}
+	return nil
+}
+
+// cacheKey returns the key of the rows of query. The result settings are part of the
+// key, since they change the rows.
+func (s *queryResultSettings) cacheKey(db *sqlx.DB, query string, args []interface{}) QueryCacheKey {
+	return QueryCacheKey{
+		Connection: connectionIdentity(db),
+		Kind: fmt.Sprintf("rows:%t:%t:%v:%v:%v",
+			s.normalize, s.decodeJSON, s.decimalMode, s.binaryMode, s.location),
+		Query: query,
+		Args:  args,
+	}
+}
+
+// runCachedIntoGlaze replays the rows of key from the cache of settings, or runs the query
+// with run and caches its rows.
+func runCachedIntoGlaze(
+	ctx context.Context,
+	settings *queryResultSettings,
+	key QueryCacheKey,
+	gp middlewares.Processor,
+	run func(gp middlewares.Processor) error,
+) error {
+	if settings.cache == nil {
+		return run(gp)
+	}
+
+	if v, ok := settings.cache.get(key); ok {
+		return v.(*cachedRows).replay(ctx, gp)
+	}
+
+	recorder := &recordingProcessor{Processor: gp}
+	if err := run(recorder); err != nil {
+		return err
+	}
+	settings.cache.set(key, &recorder.recorded, settings.cacheTTL)
+	return nil
+}
@@ -0,0 +1,252 @@
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/jmoiron/sqlx"
+)
+
+func openCacheTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	for _, stmt := range []string{
+		"CREATE TABLE items (id INTEGER, name TEXT)",
+		"INSERT INTO items VALUES (1, 'foo'), (2, 'bar')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("could not set up database: %v", err)
+		}
+	}
+	return db
+}
+
+func insertItem(t *testing.T, db *sqlx.DB) {
+	if _, err := db.Exec("INSERT INTO items VALUES (3, 'baz')"); err != nil {
+		t.Fatalf("could not insert: %v", err)
+	}
+}
+
+func TestQueryCacheTemplateSubQueries(t *testing.T) {
+	db := openCacheTestDB(t)
+	cache := NewQueryCache(10)
+	ctx := ContextWithQueryCache(context.Background(), cache, 0)
+
+	query := `{{ range $i := sqlColumn "SELECT id FROM items WHERE id > {{ .min }} ORDER BY id" "min" 0 }}` +
+		`{{ $i }}:{{ sqlSingle "SELECT COUNT(*) FROM items" }} {{ end }}`
+	render := func() string {
+		ret, _, err := RenderQuery(ctx, db, query, nil, map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("could not render: %v", err)
+		}
+		return ret
+	}
+
+	first := render()
+	if first != "1:2 2:2" {
+		t.Fatalf("unexpected query %q", first)
+	}
+	// the sqlSingle call in the loop hits the cache
+	if stats := cache.Stats(); stats.Misses != 2 || stats.Hits != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	insertItem(t, db)
+	if got := render(); got != first {
+		t.Fatalf("expected cached results, got %q", got)
+	}
+
+	// without the cache, the new row shows up
+	ret, _, err := RenderQuery(context.Background(), db, query, nil, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("could not render: %v", err)
+	}
+	if ret != "1:3 2:3 3:3" {
+		t.Fatalf("unexpected uncached query %q", ret)
+	}
+}
+
+func TestQueryCacheRunQueryIntoGlaze(t *testing.T) {
+	db := openCacheTestDB(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewQueryCache(10, WithDefaultTTL(time.Hour))
+	cache.now = func() time.Time { return now }
+
+	run := func(ttl time.Duration, args ...interface{}) *rowCollector {
+		c := &rowCollector{}
+		err := RunQueryIntoGlaze(context.Background(), db, "SELECT id, name FROM items WHERE id >= ? ORDER BY id", args, c,
+			WithQueryCache(cache, ttl))
+		if err != nil {
+			t.Fatalf("could not run query: %v", err)
+		}
+		return c
+	}
+
+	first := run(0, 1)
+	if len(first.rows) != 2 || first.columns == nil {
+		t.Fatalf("unexpected result %+v", first)
+	}
+	// modifying the rows doesn't change the cached rows
+	first.rows[0].Set("name", "modified")
+
+	insertItem(t, db)
+	second := run(0, 1)
+	if len(second.rows) != 2 {
+		t.Fatalf("expected cached rows, got %d rows", len(second.rows))
+	}
+	if name, _ := second.rows[0].Get("name"); name != "foo" {
+		t.Fatalf("expected the cached row to be unchanged, got %v", name)
+	}
+	if !reflect.DeepEqual(second.columns, first.columns) {
+		t.Fatalf("expected the column types to be replayed")
+	}
+
+	// different arguments are a different query
+	if c := run(0, 2); len(c.rows) != 2 {
+		t.Fatalf("expected 2 rows for id >= 2, got %d", len(c.rows))
+	}
+
+	// after the default TTL, the query runs again
+	now = now.Add(2 * time.Hour)
+	if c := run(0, 1); len(c.rows) != 3 {
+		t.Fatalf("expected expired entry to be refreshed, got %d rows", len(c.rows))
+	}
+
+	stats := cache.Stats()
+	want := QueryCacheStats{Hits: 1, Misses: 3, Expired: 1, Evictions: 0, Size: 2}
+	if stats != want {
+		t.Fatalf("got stats %+v, want %+v", stats, want)
+	}
+}
+
+func TestQueryCacheEvictions(t *testing.T) {
+	db := openCacheTestDB(t)
+	cache := NewQueryCache(1)
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 1"} {
+		err := RunQueryIntoGlaze(context.Background(), db, query, nil, &rowCollector{}, WithQueryCache(cache, 0))
+		if err != nil {
+			t.Fatalf("could not run query: %v", err)
+		}
+	}
+	if stats := cache.Stats(); stats.Evictions != 2 || stats.Misses != 3 || stats.Size != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCacheSettingsFromMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		enabled  bool
+		ttl      time.Duration
+		wantErr  bool
+	}{
+		{name: "no metadata", metadata: nil},
+		{name: "cache", metadata: map[string]interface{}{"cache": true}, enabled: true},
+		{name: "cache string", metadata: map[string]interface{}{"cache": "true"}, enabled: true},
+		{name: "ttl implies cache", metadata: map[string]interface{}{"cache-ttl": "10m"}, enabled: true, ttl: 10 * time.Minute},
+		{name: "cache disabled", metadata: map[string]interface{}{"cache": false, "cache-ttl": "10m"}, ttl: 10 * time.Minute},
+		{name: "invalid ttl", metadata: map[string]interface{}{"cache-ttl": "soon"}, wantErr: true},
+		{name: "invalid cache", metadata: map[string]interface{}{"cache": 3}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled, ttl, err := CacheSettingsFromMetadata(tt.metadata)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if enabled != tt.enabled || ttl != tt.ttl {
+				t.Fatalf("got %v, %v, want %v, %v", enabled, ttl, tt.enabled, tt.ttl)
+			}
+		})
+	}
+}
+
+func TestConnectionIdentity(t *testing.T) {
+	ctx := context.Background()
+	connect := func(database string) *sqlx.DB {
+		db, err := (&DatabaseConfig{Type: "sqlite", Database: database}).Connect(ctx)
+		if err != nil {
+			t.Fatalf("could not connect: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		return db
+	}
+	open := func(database string) *sqlx.DB {
+		db, err := sqlx.Open("sqlite3", database)
+		if err != nil {
+			t.Fatalf("could not open database: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		return db
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	if a, b := connect(path), connect(path); connectionIdentity(a) != connectionIdentity(b) {
+		t.Errorf("expected connections to the same database to share an identity")
+	}
+	if a, b := connect(":memory:"), connect(":memory:"); connectionIdentity(a) == connectionIdentity(b) {
+		t.Errorf("expected in-memory databases to have different identities")
+	}
+	a, b := open(path), open(path)
+	if connectionIdentity(a) == connectionIdentity(b) || connectionIdentity(a) != connectionIdentity(a) {
+		t.Errorf("expected pools not opened by Connect to have a stable identity of their own")
+	}
+}
+
+func TestQueryCacheContextForCommand(t *testing.T) {
+	db := openCacheTestDB(t)
+	cache := NewQueryCache(10)
+	query := "SELECT id, name FROM items ORDER BY id"
+
+	run := func(cmd cmds.Command) int {
+		ctx, err := cache.ContextForCommand(context.Background(), cmd)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		c := &rowCollector{}
+		if err := RunQueryIntoGlaze(ctx, db, query, nil, c); err != nil {
+			t.Fatalf("could not run query: %v", err)
+		}
+		return len(c.rows)
+	}
+
+	uncached := cmds.NewCommandDescription("uncached")
+	cached := cmds.NewCommandDescription("cached", cmds.WithMetadata(map[string]interface{}{"cache": true}))
+
+	if n := run(cached); n != 2 {
+		t.Fatalf("expected 2 rows, got %d", n)
+	}
+	insertItem(t, db)
+	if n := run(cached); n != 2 {
+		t.Errorf("expected the cached rows, got %d rows", n)
+	}
+	if n := run(uncached); n != 3 {
+		t.Errorf("expected the rows of the database without cache metadata, got %d rows", n)
+	}
+
+	invalid := cmds.NewCommandDescription("invalid", cmds.WithMetadata(map[string]interface{}{"cache-ttl": "soon"}))
+	if _, err := cache.ContextForCommand(context.Background(), invalid); err == nil {
+		t.Errorf("expected an error for invalid cache metadata")
+	}
+}
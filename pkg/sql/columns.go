@@ -0,0 +1,314 @@
+package sql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ColumnType is the type metadata of a result column, as reported by the driver.
+type ColumnType struct {
+	Name string `json:"name"`
+	// DatabaseType is the database type name, for example VARCHAR, DECIMAL or JSONB.
+	DatabaseType string `json:"databaseType"`
+	// ScanType is the Go type the driver scans the column into.
+	ScanType string `json:"scanType,omitempty"`
+	Nullable *bool  `json:"nullable,omitempty"`
+	Length   *int64 `json:"length,omitempty"`
+	// Precision and Scale are set for decimal columns.
+	Precision *int64 `json:"precision,omitempty"`
+	Scale     *int64 `json:"scale,omitempty"`
+}
+
+// GetColumnTypes returns the column metadata of rows.
+func GetColumnTypes(rows *sqlx.Rows) ([]*ColumnType, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get column types")
+	}
+
+	ret := make([]*ColumnType, len(columnTypes))
+	for i, ct := range columnTypes {
+		c := &ColumnType{
+			Name:         ct.Name(),
+			DatabaseType: ct.DatabaseTypeName(),
+		}
+		if scanType := ct.ScanType(); scanType != nil {
+			c.ScanType = scanType.String()
+		}
+		if nullable, ok := ct.Nullable(); ok {
+			c.Nullable = &nullable
+		}
+		if length, ok := ct.Length(); ok {
+			c.Length = &length
+		}
+		if precision, scale, ok := ct.DecimalSize(); ok {
+			c.Precision = &precision
+			c.Scale = &scale
+		}
+		ret[i] = c
+	}
+
+	return ret, nil
+}
+
+// baseType returns the upper-cased database type without size and modifiers,
+// for example DECIMAL for "decimal(10,2) unsigned".
+func (c *ColumnType) baseType() string {
+	t := strings.ToUpper(strings.TrimSpace(c.DatabaseType))
+	if i := strings.IndexAny(t, "( "); i >= 0 {
+		t = t[:i]
+	}
+	return t
+}
+
+// ColumnTypesReceiver can be implemented by the processor passed to RunQueryIntoGlaze
+// to receive the column metadata before the first row.
+type ColumnTypesReceiver interface {
+	SetColumnTypes(ctx context.Context, columns []*ColumnType) error
+}
+
+// DecimalMode selects how DECIMAL and NUMERIC values are returned when normalizing.
+type DecimalMode string
+
+const (
+	// DecimalAsString returns the exact decimal as a string.
+	DecimalAsString DecimalMode = "string"
+	// DecimalAsNumber returns a json.Number, exact and rendered as a number by JSON output.
+	DecimalAsNumber DecimalMode = "number"
+	// DecimalAsFloat returns a float64, possibly losing precision.
+	DecimalAsFloat DecimalMode = "float"
+)
+
+// BinaryMode selects how binary values (BLOB, BYTEA, BINARY, ...) are returned when normalizing.
+type BinaryMode string
+
+const (
+	BinaryAsText   BinaryMode = "text"
+	BinaryAsBase64 BinaryMode = "base64"
+)
+
+type queryResultSettings struct {
+	normalize        bool
+	decodeJSON       bool
+	decimalMode      DecimalMode
+	binaryMode       BinaryMode
+	location         *time.Location
+	naiveLocation    *time.Location
+	timeout          time.Duration
+	name             string
+	columnTypesFuncs []func(ctx context.Context, columns []*ColumnType) error
+	cache            *QueryCache
+	cacheTTL         time.Duration
+}
+
+func newQueryResultSettings(options ...QueryResultOption) *queryResultSettings {
+	ret := &queryResultSettings{
+		decodeJSON:  true,
+		decimalMode: DecimalAsString,
+		binaryMode:  BinaryAsText,
+	}
+	for _, opt := range options {
+		opt(ret)
+	}
+	return ret
+}
+
+// QueryResultOption configures how RunQueryIntoGlaze runs a query and turns its result rows
+// into glaze rows.
+type QueryResultOption func(*queryResultSettings)
+
+// WithNormalizedValues normalizes values based on the database type of their column:
+// JSON columns are decoded, decimals returned according to WithDecimalMode, UUIDs as
+// their canonical string, binary values according to WithBinaryMode and times
+// converted to the location set with WithTimeLocation.
+func WithNormalizedValues() QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.normalize = true
+	}
+}
+
+// WithJSONDecoding toggles decoding JSON columns when normalizing (on by default).
+func WithJSONDecoding(decode bool) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.decodeJSON = decode
+	}
+}
+
+func WithDecimalMode(mode DecimalMode) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.decimalMode = mode
+	}
+}
+
+func WithBinaryMode(mode BinaryMode) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.binaryMode = mode
+	}
+}
+
+// WithTimeLocation converts time values to loc when normalizing.
+func WithTimeLocation(loc *time.Location) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.location = loc
+	}
+}
+
+// WithNaiveTimeLocation parses the times given as text without a time zone, for example
+// by SQLite and DuckDB text columns, as times in loc when normalizing. Without it, they are
+// returned as text, since their zone is unknown.
+func WithNaiveTimeLocation(loc *time.Location) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.naiveLocation = loc
+	}
+}
+
+// WithColumnTypes calls f with the column metadata before the first row is processed.
+func WithColumnTypes(f func(ctx context.Context, columns []*ColumnType) error) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.columnTypesFuncs = append(s.columnTypesFuncs, f)
+	}
+}
+
+// timeLayouts are tried in order when parsing times returned as text,
+// for example by MySQL without parseTime=true.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+}
+
+// naiveTimeLayouts are the layouts of times given as text without a time zone, only
+// parsed with WithNaiveTimeLocation.
+var naiveTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+// normalizeValue converts v according to the database type of its column.
+func (s *queryResultSettings) normalizeValue(column *ColumnType, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+
+	switch column.baseType() {
+	case "JSON", "JSONB":
+		if !s.decodeJSON {
+			break
+		}
+		if text, ok := valueText(v); ok {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+				return decoded
+			}
+			return text
+		}
+	case "DECIMAL", "NUMERIC", "NEWDECIMAL":
+		if text, ok := decimalText(v); ok {
+			switch s.decimalMode {
+			case DecimalAsNumber:
+				return json.Number(text)
+			case DecimalAsFloat:
+				if f, err := strconv.ParseFloat(text, 64); err == nil {
+					return f
+				}
+			}
+			return text
+		}
+	case "UUID":
+		switch u := v.(type) {
+		case []byte:
+			if len(u) == 16 {
+				return formatUUID(u)
+			}
+		case [16]byte:
+			return formatUUID(u[:])
+		case fmt.Stringer:
+			return u.String()
+		}
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BYTEA", "BINARY", "VARBINARY", "BYTES":
+		if b, ok := v.([]byte); ok {
+			if s.binaryMode == BinaryAsBase64 {
+				return base64.StdEncoding.EncodeToString(b)
+			}
+			return string(b)
+		}
+	case "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ", "TIMESTAMP_TZ", "TIME":
+		if text, ok := valueText(v); ok {
+			if t, ok := s.parseTime(text); ok {
+				v = t
+			}
+		}
+	}
+
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		if s.location != nil {
+			return v.In(s.location)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// parseTime parses a time given as text, see WithNaiveTimeLocation.
+func (s *queryResultSettings) parseTime(text string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+	if s.naiveLocation != nil {
+		for _, layout := range naiveTimeLayouts {
+			if t, err := time.ParseInLocation(layout, text, s.naiveLocation); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func valueText(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	default:
+		return "", false
+	}
+}
+
+func decimalText(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case fmt.Stringer:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
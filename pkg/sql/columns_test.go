@@ -0,0 +1,152 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+)
+
+// rowCollector collects the rows and column types passed to it.
+type rowCollector struct {
+	rows    []types.Row
+	columns []*ColumnType
+}
+
+func (c *rowCollector) AddRow(ctx context.Context, row types.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Close(ctx context.Context) error {
+	return nil
+}
+
+func (c *rowCollector) SetColumnTypes(ctx context.Context, columns []*ColumnType) error {
+	c.columns = columns
+	return nil
+}
+
+func openTypedTestDB(t *testing.T) *sqlx.DB {
+	ctx := context.Background()
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	for _, stmt := range []string{
+		"CREATE TABLE items (doc JSON, price DECIMAL(10,2), data BLOB, id UUID, created DATETIME, name TEXT)",
+		`INSERT INTO items VALUES ('{"a":[1,2]}', 12.5, X'00FF',
+			X'0123456789ABCDEF0123456789ABCDEF', '2023-03-14 12:00:00+00:00', 'foo')`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("could not set up database: %v", err)
+		}
+	}
+	return db
+}
+
+func TestRunQueryIntoGlazeNormalizesValues(t *testing.T) {
+	db := openTypedTestDB(t)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+
+	c := &rowCollector{}
+	err = RunQueryIntoGlaze(context.Background(), db, "SELECT * FROM items", nil, c,
+		WithNormalizedValues(),
+		WithDecimalMode(DecimalAsNumber),
+		WithBinaryMode(BinaryAsBase64),
+		WithTimeLocation(tokyo),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(c.rows))
+	}
+	row := c.rows[0]
+
+	want := map[string]interface{}{
+		"doc":   map[string]interface{}{"a": []interface{}{float64(1), float64(2)}},
+		"price": json.Number("12.5"),
+		"data":  "AP8=",
+		"id":    "01234567-89ab-cdef-0123-456789abcdef",
+		"name":  "foo",
+	}
+	for k, v := range want {
+		got, _ := row.Get(k)
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("%s: got %#v, want %#v", k, got, v)
+		}
+	}
+
+	created, _ := row.Get("created")
+	ts, ok := created.(time.Time)
+	if !ok {
+		t.Fatalf("expected created to be a time, got %#v", created)
+	}
+	if ts.Location() != tokyo || ts.Hour() != 21 {
+		t.Errorf("expected 21:00 in Asia/Tokyo, got %s", ts)
+	}
+
+	if len(c.columns) != 6 {
+		t.Fatalf("expected 6 column types, got %d", len(c.columns))
+	}
+	if c.columns[0].Name != "doc" || c.columns[0].DatabaseType != "JSON" {
+		t.Errorf("unexpected column type %+v", c.columns[0])
+	}
+}
+
+func TestRunQueryIntoGlazeKeepsRawValuesByDefault(t *testing.T) {
+	db := openTypedTestDB(t)
+
+	c := &rowCollector{}
+	if err := RunQueryIntoGlaze(context.Background(), db, "SELECT doc, data FROM items", nil, c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	doc, _ := c.rows[0].Get("doc")
+	if doc != `{"a":[1,2]}` {
+		t.Errorf("expected raw JSON text, got %#v", doc)
+	}
+	data, _ := c.rows[0].Get("data")
+	if data != "\x00\xff" {
+		t.Errorf("expected raw binary text, got %#v", data)
+	}
+}
+
+func TestNormalizeValueKeepsNaiveTimes(t *testing.T) {
+	column := &ColumnType{Name: "created", DatabaseType: "TIMESTAMP"}
+	naive := "2023-03-14 12:00:00"
+
+	s := newQueryResultSettings(WithNormalizedValues(), WithTimeLocation(time.UTC))
+	if got := s.normalizeValue(column, naive); got != naive {
+		t.Errorf("expected the naive time to be kept as text, got %#v", got)
+	}
+	got := s.normalizeValue(column, "2023-03-14 12:00:00+02:00")
+	if ts, ok := got.(time.Time); !ok || ts.Hour() != 10 {
+		t.Errorf("expected a zoned time at 10:00 UTC, got %#v", got)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	s = newQueryResultSettings(WithNormalizedValues(), WithNaiveTimeLocation(berlin))
+	got = s.normalizeValue(column, naive)
+	ts, ok := got.(time.Time)
+	if !ok {
+		t.Fatalf("expected a time, got %#v", got)
+	}
+	if ts.Location() != berlin || ts.Hour() != 12 {
+		t.Errorf("expected 12:00 in Europe/Berlin, got %s", ts)
+	}
+}
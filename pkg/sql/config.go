@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,19 +19,34 @@ import (
 )
 
 type DatabaseConfig struct {
-	Host            string `glazed:"host"`
-	Database        string `glazed:"database"`
-	User            string `glazed:"user"`
-	Password        string `glazed:"password"` // #nosec G117 -- Password is part of the DB config model.
-	Port            int    `glazed:"port"`
-	Schema          string `glazed:"schema"`
-	Type            string `glazed:"db-type"`
-	DSN             string `glazed:"dsn"`
-	Driver          string `glazed:"driver"`
-	SSLDisable      bool   `glazed:"ssl-disable"`
-	DbtProfilesPath string `glazed:"dbt-profiles-path"`
-	DbtProfile      string `glazed:"dbt-profile"`
-	UseDbtProfiles  bool   `glazed:"use-dbt-profiles"`
+	Host              string            `glazed:"host"`
+	Database          string            `glazed:"database"`
+	User              string            `glazed:"user"`
+	Password          string            `glazed:"password"` // #nosec G117 -- Password is part of the DB config model.
+	Port              int               `glazed:"port"`
+	Schema            string            `glazed:"schema"`
+	Type              string            `glazed:"db-type"`
+	DSN               string            `glazed:"dsn"`
+	Driver            string            `glazed:"driver"`
+	SSLDisable        bool              `glazed:"ssl-disable"`
+	ReadOnly          bool              `glazed:"read-only"`
+	MaxOpenConns      int               `glazed:"max-open-conns"`
+	MaxIdleConns      int               `glazed:"max-idle-conns"`
+	ConnMaxLifetime   string            `glazed:"conn-max-lifetime"`
+	ConnMaxIdleTime   string            `glazed:"conn-max-idle-time"`
+	QueryTimeout      string            `glazed:"query-timeout"`
+	ConnectTimeout    string            `glazed:"connect-timeout"`
+	ConnectAttempts   int               `glazed:"connect-attempts"`
+	ConnectBackoff    string            `glazed:"connect-backoff"`
+	ConnectMaxBackoff string            `glazed:"connect-max-backoff"`
+	ConnectWait       string            `glazed:"connect-wait"`
+	DSNOptions        map[string]string `glazed:"dsn-options"`
+	Connection        string            `glazed:"connection"`
+	ConnectionsFile   string            `glazed:"connections-file"`
+	DbtProfilesPath   string            `glazed:"dbt-profiles-path"`
+	DbtProfile        string            `glazed:"dbt-profile"`
+	DbtTarget         string            `glazed:"dbt-target"`
+	UseDbtProfiles    bool              `glazed:"use-dbt-profiles"`
 }
 
 // LogVerbose just outputs information about the database config to the
@@ -40,6 +56,7 @@ func (c *DatabaseConfig) LogVerbose() {
 		log.Debug().
 			Str("dbt-profiles-path", c.DbtProfilesPath).
 			Str("dbt-profile", c.DbtProfile).
+			Str("dbt-target", c.DbtTarget).
 			Msg("Using dbt profiles")
 
 		// Get the actual source values from DBT profile
@@ -59,8 +76,9 @@ func (c *DatabaseConfig) LogVerbose() {
 			Msg("Using connection string from dbt profile")
 	} else if c.DSN != "" {
 		log.Debug().
-			Str("dsn", c.DSN).
+			Str("dsn", redactDSN(c.DSN)).
 			Str("driver", c.Driver).
+			Bool("read_only", c.ReadOnly).
 			Msg("Using DSN")
 	} else {
 		log.Debug().
@@ -71,6 +89,7 @@ func (c *DatabaseConfig) LogVerbose() {
 			Str("schema", c.Schema).
 			Str("type", c.Type).
 			Bool("ssl_disable", c.SSLDisable).
+			Bool("read_only", c.ReadOnly).
 			Msg("Using connection string")
 	}
 }
@@ -89,7 +108,7 @@ func (c *DatabaseConfig) ToString() string {
 			return fmt.Sprintf("dbt-profile: %s, %s", c.DbtProfile, sourceString)
 		}
 	} else if c.DSN != "" {
-		return fmt.Sprintf("dsn: %s, driver: %s", c.DSN, c.Driver)
+		return fmt.Sprintf("dsn: %s, driver: %s", redactDSN(c.DSN), c.Driver)
 	} else {
 		return fmt.Sprintf("%s@%s:%d/%s", c.User, c.Host, c.Port, c.Database)
 	}
@@ -103,25 +122,11 @@ func (c *DatabaseConfig) GetSource() (*Source, error) {
 			return nil, errors.Errorf("No dbt profile specified")
 		}
 
-		sources, err := ParseDbtProfiles(c.DbtProfilesPath)
+		s, err := FindDbtSource(c.DbtProfilesPath, c.DbtProfile, c.DbtTarget)
 		if err != nil {
 			return nil, err
 		}
-
-		for _, s := range sources {
-			log.Trace().
-				Str("Profile", c.DbtProfile).
-				Str("name", s.Name).
-				Msg("Checking source")
-			if s.Name == c.DbtProfile {
-				source = s
-				break
-			}
-		}
-
-		if source == nil {
-			return nil, errors.Errorf("Source %s not found", c.DbtProfile)
-		}
+		source = s
 	} else {
 		source = &Source{
 			Type:       c.Type,
@@ -135,6 +140,18 @@ func (c *DatabaseConfig) GetSource() (*Source, error) {
 		}
 	}
 
+	// options from flags override the ones from the dbt profile
+	if len(c.DSNOptions) > 0 {
+		options := map[string]string{}
+		for k, v := range source.Options {
+			options[k] = v
+		}
+		for k, v := range c.DSNOptions {
+			options[k] = v
+		}
+		source.Options = options
+	}
+
 	// Normalize driver/type names
 	switch strings.ToLower(source.Type) {
 	case "sqlite":
@@ -150,18 +167,41 @@ func (c *DatabaseConfig) GetSource() (*Source, error) {
 	return source, nil
 }
 
-// GetConnectionString returns the connection string for this database config
+// GetConnectionString returns the connection string for this database config,
+// with the secret references (env:, file:, cmd:) resolved.
 func (c *DatabaseConfig) GetConnectionString() (string, error) {
+	connectionString, _, err := c.resolveConnectionString(context.Background())
+	return connectionString, err
+}
+
+// resolveConnectionString returns the connection string and the driver, resolving the
+// secret references of the DSN or of the fields of the source.
+func (c *DatabaseConfig) resolveConnectionString(ctx context.Context) (string, string, error) {
 	if c.DSN != "" {
-		return c.DSN, nil
+		// sqlite3 DSNs may be file: URIs
+		kinds := allSecretKinds
+		if driver := strings.ToLower(c.Driver); driver == "sqlite" || driver == "sqlite3" {
+			kinds &^= secretFile
+		}
+		dsn, err := resolveSecret(ctx, c.DSN, kinds)
+		if err != nil {
+			return "", "", errors.Wrap(err, "could not resolve dsn")
+		}
+		return dsn, c.Driver, nil
 	}
 
 	s, err := c.GetSource()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-
-	return s.ToConnectionString(), nil
+	if err := s.ResolveSecrets(ctx); err != nil {
+		return "", "", err
+	}
+	connectionString, err := s.BuildConnectionString()
+	if err != nil {
+		return "", "", err
+	}
+	return connectionString, s.Type, nil
 }
 
 func normalizeDuckDBDSN(dsn string) (string, error) {
@@ -191,82 +231,252 @@ func normalizeDuckDBDSN(dsn string) (string, error) {
 	return path, nil
 }
 
-func (c *DatabaseConfig) Connect(ctx context.Context) (*sqlx.DB, error) {
-	// Normalize driver based on provided value or DSN
-	if c.DSN != "" {
-		// Infer driver from DSN scheme if not explicitly provided
-		if c.Driver == "" {
-			lower := strings.ToLower(c.DSN)
-			switch {
-			case strings.HasPrefix(lower, "postgres://") || strings.HasPrefix(lower, "postgresql://"):
-				c.Driver = "pgx"
-			case strings.HasPrefix(lower, "mysql://") || strings.HasPrefix(lower, "mariadb://"):
-				// For DSN with URL scheme, the mysql std driver expects a different format,
-				// but many connectors accept it; we still set driver appropriately.
-				c.Driver = "mysql"
-			case strings.HasPrefix(lower, "sqlite://") || strings.HasPrefix(lower, "sqlite3://"):
-				c.Driver = "sqlite3"
-			case strings.HasPrefix(lower, "duckdb://"):
-				c.Driver = "duckdb"
-			}
+// appendDSNParam adds key=value to the query part of a URL-style or path-style DSN,
+// unless key is already set.
+func appendDSNParam(dsn string, key string, value string) string {
+	if strings.Contains(dsn, "?"+key+"=") || strings.Contains(dsn, "&"+key+"=") {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + key + "=" + value
+}
+
+// appendPgxParam adds a runtime parameter to a URL-style or key/value pgx DSN,
+// unless it is already set.
+func appendPgxParam(dsn string, key string, value string) string {
+	lower := strings.ToLower(dsn)
+	if strings.HasPrefix(lower, "postgres://") || strings.HasPrefix(lower, "postgresql://") {
+		return appendDSNParam(dsn, key, value)
+	}
+	if strings.Contains(dsn, key+"=") {
+		return dsn
+	}
+	return strings.TrimSpace(dsn + " " + key + "=" + value)
+}
+
+// readOnlyConnectionString rewrites the connection string so that the driver
+// enforces read-only access:
+//   - sqlite3 opens the file with mode=ro
+//   - duckdb opens the database with access_mode=READ_ONLY
+//   - pgx sets the default_transaction_read_only session parameter
+//   - mysql sets transaction_read_only for the session, the equivalent of
+//     SET SESSION TRANSACTION READ ONLY, on every new connection
+//
+// See https://github.com/wesen/sqleton/issues/24
+func readOnlyConnectionString(driver string, dsn string) (string, error) {
+	switch driver {
+	case "sqlite3":
+		// mode=ro is only honoured for URI filenames
+		if !strings.HasPrefix(dsn, "file:") {
+			dsn = "file:" + dsn
 		}
-		// Canonicalize driver aliases
-		switch strings.ToLower(c.Driver) {
-		case "postgres", "postgresql", "pg":
-			c.Driver = "pgx"
-		case "sqlite":
-			c.Driver = "sqlite3"
-		case "mariadb":
-			c.Driver = "mysql"
-		case "duckdb", "duck":
-			c.Driver = "duckdb"
+		return appendDSNParam(dsn, "mode", "ro"), nil
+	case "duckdb":
+		return appendDSNParam(dsn, "access_mode", "READ_ONLY"), nil
+	case "pgx":
+		return appendPgxParam(dsn, "default_transaction_read_only", "on"), nil
+	case "mysql":
+		return appendMySQLParam(dsn, "transaction_read_only", "1")
+	default:
+		return "", errors.Errorf("read-only connections are not supported for driver %s", driver)
+	}
+}
+
+// PoolSettings are the connection pool limits applied to the *sqlx.DB returned by Connect.
+// Zero values keep the database/sql defaults.
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// GetPoolSettings parses the pool flags of the config. The pool settings apply
+// whether the connection comes from a DSN, the individual flags or a dbt profile.
+func (c *DatabaseConfig) GetPoolSettings() (*PoolSettings, error) {
+	if c.MaxOpenConns < 0 {
+		return nil, errors.Errorf("max-open-conns must not be negative, got %d", c.MaxOpenConns)
+	}
+	if c.MaxIdleConns < 0 {
+		return nil, errors.Errorf("max-idle-conns must not be negative, got %d", c.MaxIdleConns)
+	}
+	connMaxLifetime, err := parseDurationSetting("conn-max-lifetime", c.ConnMaxLifetime)
+	if err != nil {
+		return nil, err
+	}
+	connMaxIdleTime, err := parseDurationSetting("conn-max-idle-time", c.ConnMaxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PoolSettings{
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifetime: connMaxLifetime,
+		ConnMaxIdleTime: connMaxIdleTime,
+	}, nil
+}
+
+func parseDurationSetting(name string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s", name)
+	}
+	if d < 0 {
+		return 0, errors.Errorf("%s must not be negative, got %s", name, value)
+	}
+	return d, nil
+}
+
+// Apply sets the pool limits on db, leaving the defaults in place for zero values.
+func (p *PoolSettings) Apply(db *sqlx.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+// GetQueryTimeout parses the query-timeout flag. Zero means no timeout.
+func (c *DatabaseConfig) GetQueryTimeout() (time.Duration, error) {
+	return parseDurationSetting("query-timeout", c.QueryTimeout)
+}
+
+// statementTimeoutConnectionString adds the driver-native statement timeout to the
+// connection string, for the drivers that have one:
+//   - pgx sets the statement_timeout session parameter
+//   - mysql sets max_execution_time, which applies to SELECT statements
+//
+// Every driver also gets the context deadline applied by RunQueryIntoGlaze and the sub-query
+// template functions.
+func statementTimeoutConnectionString(driver string, dsn string, timeout time.Duration) (string, error) {
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
+	switch driver {
+	case "pgx":
+		return appendPgxParam(dsn, "statement_timeout", ms), nil
+	case "mysql":
+		return appendMySQLParam(dsn, "max_execution_time", ms)
+	default:
+		return dsn, nil
+	}
+}
+
+// normalizeDSN infers the driver from the scheme of the DSN if it isn't set, canonicalizes
+// the driver name and adapts the DSN to the driver.
+func normalizeDSN(dsn string, driver string) (string, string, error) {
+	// Infer driver from DSN scheme if not explicitly provided
+	if driver == "" {
+		lower := strings.ToLower(dsn)
+		switch {
+		case strings.HasPrefix(lower, "postgres://") || strings.HasPrefix(lower, "postgresql://"):
+			driver = "pgx"
+		case strings.HasPrefix(lower, "mysql://") || strings.HasPrefix(lower, "mariadb://"):
+			// For DSN with URL scheme, the mysql std driver expects a different format,
+			// but many connectors accept it; we still set driver appropriately.
+			driver = "mysql"
+		case strings.HasPrefix(lower, "sqlite://") || strings.HasPrefix(lower, "sqlite3://"):
+			driver = "sqlite3"
+		case strings.HasPrefix(lower, "duckdb://"):
+			driver = "duckdb"
 		}
+	}
+	// Canonicalize driver aliases
+	switch strings.ToLower(driver) {
+	case "postgres", "postgresql", "pg":
+		driver = "pgx"
+	case "sqlite":
+		driver = "sqlite3"
+	case "mariadb":
+		driver = "mysql"
+	case "duckdb", "duck":
+		driver = "duckdb"
+	}
 
-		if c.Driver == "duckdb" && strings.HasPrefix(strings.ToLower(c.DSN), "duckdb://") {
-			normalizedDSN, err := normalizeDuckDBDSN(c.DSN)
-			if err != nil {
-				return nil, err
-			}
-			c.DSN = normalizedDSN
+	if driver == "duckdb" && strings.HasPrefix(strings.ToLower(dsn), "duckdb://") {
+		normalizedDSN, err := normalizeDuckDBDSN(dsn)
+		if err != nil {
+			return "", "", err
 		}
+		dsn = normalizedDSN
+	}
 
-		// Enforce driver-level timeout for unreachable pgx endpoints
-		if c.Driver == "pgx" && !strings.Contains(c.DSN, "connect_timeout") {
-			if strings.HasPrefix(c.DSN, "postgres://") || strings.HasPrefix(c.DSN, "postgresql://") {
-				// Append as URL query parameter
-				if u, err := url.Parse(c.DSN); err == nil {
-					q := u.Query()
-					if q.Get("connect_timeout") == "" {
-						q.Set("connect_timeout", "5")
-						u.RawQuery = q.Encode()
-						c.DSN = u.String()
-					}
-				} else {
-					// Fallback: append as key/value
-					c.DSN = c.DSN + " connect_timeout=5"
+	// Enforce driver-level timeout for unreachable pgx endpoints
+	if driver == "pgx" && !strings.Contains(dsn, "connect_timeout") {
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			// Append as URL query parameter
+			if u, err := url.Parse(dsn); err == nil {
+				q := u.Query()
+				if q.Get("connect_timeout") == "" {
+					q.Set("connect_timeout", "5")
+					u.RawQuery = q.Encode()
+					dsn = u.String()
 				}
 			} else {
-				// Key/value style DSN
-				c.DSN = c.DSN + " connect_timeout=5"
+				// Fallback: append as key/value
+				dsn = dsn + " connect_timeout=5"
 			}
+		} else {
+			// Key/value style DSN
+			dsn = dsn + " connect_timeout=5"
 		}
 	}
-	c.LogVerbose()
 
-	var dbType string
-	connectionString, err := c.GetConnectionString()
+	return dsn, driver, nil
+}
+
+func (c *DatabaseConfig) Connect(ctx context.Context) (*sqlx.DB, error) {
+	poolSettings, err := c.GetPoolSettings()
+	if err != nil {
+		return nil, err
+	}
+	queryTimeout, err := c.GetQueryTimeout()
+	if err != nil {
+		return nil, err
+	}
+	retrySettings, err := c.GetConnectRetrySettings()
 	if err != nil {
 		return nil, err
 	}
 
+	c.LogVerbose()
+
+	// secret references are only resolved here, the config keeps the references
+	connectionString, dbType, err := c.resolveConnectionString(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if c.DSN != "" {
-		dbType = c.Driver
-	} else {
-		s, err := c.GetSource()
+		connectionString, dbType, err = normalizeDSN(connectionString, dbType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ReadOnly {
+		connectionString, err = readOnlyConnectionString(dbType, connectionString)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if queryTimeout > 0 {
+		connectionString, err = statementTimeoutConnectionString(dbType, connectionString, queryTimeout)
 		if err != nil {
 			return nil, err
 		}
-		dbType = s.Type
 	}
 
 	log.Debug().Msg("Opening database connection")
@@ -274,20 +484,19 @@ func (c *DatabaseConfig) Connect(ctx context.Context) (*sqlx.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	poolSettings.Apply(db)
+	setConnectionInfo(db, &connectionInfo{
+		identity:     dsnIdentity(dbType, connectionString),
+		queryTimeout: queryTimeout,
+	})
 	log.Debug().Msg("Database connection established")
-	// use context with timeout for ping
-	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
 
-	if err := db.PingContext(pingCtx); err != nil {
+	if err := retrySettings.ping(ctx, db); err != nil {
 		_ = db.Close()
-		return nil, errors.Wrap(err, "failed to ping database")
+		return nil, err
 	}
 
-	// TODO(2022-12-18, manuel): this is where we would add support for a ro connection
-	// https://github.com/wesen/sqleton/issues/24
-
-	return db, err
+	return db, nil
 }
 
 func NewConfigFromParsedLayers(parsedSections ...*values.SectionValues) (*DatabaseConfig, error) {
@@ -299,5 +508,10 @@ func NewConfigFromParsedLayers(parsedSections ...*values.SectionValues) (*Databa
 		}
 	}
 
+	// flags that were set explicitly override the named connection
+	if err := config.resolveConnection(explicitFields(parsedSections...)); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
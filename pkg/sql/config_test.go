@@ -1,6 +1,16 @@
 package sql
 
-import "testing"
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/cmds/schema"
+	"github.com/go-go-golems/glazed/pkg/cmds/sources"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+)
 
 func TestNormalizeDuckDBDSN(t *testing.T) {
 	tests := []struct {
@@ -42,3 +52,192 @@ func TestNormalizeDuckDBDSN(t *testing.T) {
 		})
 	}
 }
+
+func TestReadOnlyConnectionString(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		dsn    string
+		want   string
+	}{
+		{name: "sqlite path", driver: "sqlite3", dsn: "/tmp/app.db", want: "file:/tmp/app.db?mode=ro"},
+		{name: "sqlite uri with params", driver: "sqlite3", dsn: "file:app.db?cache=shared", want: "file:app.db?cache=shared&mode=ro"},
+		{name: "duckdb path", driver: "duckdb", dsn: "/tmp/app.duckdb", want: "/tmp/app.duckdb?access_mode=READ_ONLY"},
+		{name: "duckdb already read-only", driver: "duckdb", dsn: "/tmp/app.duckdb?access_mode=read_only", want: "/tmp/app.duckdb?access_mode=read_only"},
+		{
+			name:   "postgres key/value",
+			driver: "pgx",
+			dsn:    "host=localhost dbname=app",
+			want:   "host=localhost dbname=app default_transaction_read_only=on",
+		},
+		{
+			name:   "postgres url",
+			driver: "pgx",
+			dsn:    "postgres://localhost/app?sslmode=disable",
+			want:   "postgres://localhost/app?sslmode=disable&default_transaction_read_only=on",
+		},
+		{name: "mysql", driver: "mysql", dsn: "root:pw@tcp(localhost:3306)/app", want: "root:pw@tcp(localhost:3306)/app?transaction_read_only=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readOnlyConnectionString(tt.driver, tt.dsn)
+			if err != nil {
+				t.Fatalf("readOnlyConnectionString(%q, %q) returned error: %v", tt.driver, tt.dsn, err)
+			}
+			if got != tt.want {
+				t.Fatalf("readOnlyConnectionString(%q, %q) = %q, want %q", tt.driver, tt.dsn, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readOnlyConnectionString("oracle", "dsn"); err == nil {
+		t.Fatalf("expected error for unsupported driver")
+	}
+}
+
+func TestReadOnlyConnectRejectsWrites(t *testing.T) {
+	for _, dbType := range []string{"sqlite", "duckdb"} {
+		t.Run(dbType, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "test.db")
+
+			rw := &DatabaseConfig{Type: dbType, Database: path}
+			db, err := rw.Connect(ctx)
+			if err != nil {
+				t.Fatalf("could not open database: %v", err)
+			}
+			if _, err := db.ExecContext(ctx, "CREATE TABLE items (id INTEGER)"); err != nil {
+				t.Fatalf("could not create table: %v", err)
+			}
+			if _, err := db.ExecContext(ctx, "INSERT INTO items VALUES (1)"); err != nil {
+				t.Fatalf("could not insert row: %v", err)
+			}
+			_ = db.Close()
+
+			ro := &DatabaseConfig{Type: dbType, Database: path, ReadOnly: true}
+			db, err = ro.Connect(ctx)
+			if err != nil {
+				t.Fatalf("could not open read-only database: %v", err)
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			var count int
+			if err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM items"); err != nil {
+				t.Fatalf("could not read from read-only database: %v", err)
+			}
+			if count != 1 {
+				t.Fatalf("expected 1 row, got %d", count)
+			}
+
+			if _, err := db.ExecContext(ctx, "INSERT INTO items VALUES (2)"); err == nil {
+				t.Fatalf("expected insert into read-only database to fail")
+			}
+		})
+	}
+}
+
+func TestGetPoolSettings(t *testing.T) {
+	c := &DatabaseConfig{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: "30m",
+		ConnMaxIdleTime: "90s",
+	}
+	settings, err := c.GetPoolSettings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := PoolSettings{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 90 * time.Second,
+	}
+	if *settings != want {
+		t.Fatalf("got %+v, want %+v", *settings, want)
+	}
+
+	for _, c := range []*DatabaseConfig{
+		{MaxOpenConns: -1},
+		{MaxIdleConns: -1},
+		{ConnMaxLifetime: "forever"},
+		{ConnMaxIdleTime: "-5m"},
+	} {
+		if _, err := c.GetPoolSettings(); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestConnectAppliesPoolSettingsToDbtProfiles(t *testing.T) {
+	dir := t.TempDir()
+	profilesPath := filepath.Join(dir, "profiles.yml")
+	profiles := "local:\n  target: dev\n  outputs:\n    dev:\n      type: sqlite\n      path: " +
+		filepath.Join(dir, "test.db") + "\n"
+	if err := os.WriteFile(profilesPath, []byte(profiles), 0o600); err != nil {
+		t.Fatalf("could not write profiles: %v", err)
+	}
+
+	c := &DatabaseConfig{
+		UseDbtProfiles:  true,
+		DbtProfilesPath: profilesPath,
+		DbtProfile:      "local",
+		MaxOpenConns:    3,
+		ConnMaxLifetime: "1m",
+	}
+	db, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	if got := db.Stats().MaxOpenConnections; got != 3 {
+		t.Fatalf("expected max open connections 3, got %d", got)
+	}
+}
+
+func TestNewConfigFromParsedLayers(t *testing.T) {
+	connectionLayer, err := NewSqlConnectionParameterLayer()
+	if err != nil {
+		t.Fatalf("could not create sql-connection section: %v", err)
+	}
+	dbtLayer, err := NewDbtParameterLayer()
+	if err != nil {
+		t.Fatalf("could not create dbt section: %v", err)
+	}
+	schema_ := schema.NewSchema(schema.WithSections(connectionLayer, dbtLayer))
+
+	parsedValues := values.New()
+	err = sources.Execute(schema_, parsedValues,
+		sources.FromMap(map[string]map[string]interface{}{
+			SqlConnectionSlug: {
+				"db-type":       "mysql",
+				"query-timeout": "30s",
+				"dsn-options":   map[string]interface{}{"parseTime": "true"},
+			},
+			DbtSlug: {
+				"dbt-target": "prod",
+			},
+		}),
+		sources.FromDefaults(),
+	)
+	if err != nil {
+		t.Fatalf("could not parse values: %v", err)
+	}
+
+	config, err := NewConfigFromRawParsedLayers(parsedValues)
+	if err != nil {
+		t.Fatalf("could not create config: %v", err)
+	}
+	if config.Type != "mysql" || config.Port != 3306 || config.QueryTimeout != "30s" || config.DbtTarget != "prod" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if config.DSNOptions["parseTime"] != "true" {
+		t.Errorf("expected dsn-options to be decoded, got %v", config.DSNOptions)
+	}
+}
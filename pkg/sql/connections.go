@@ -0,0 +1,299 @@
+package sql
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+
+	yaml_editor "github.com/go-go-golems/clay/pkg/yaml-editor"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ConnectionsAppName is the application name used to locate the default connections file,
+// ~/.config/<app>/connections.yaml, when --connections-file is not set. Applications set it
+// to their own name so that --connection reads the file edited by their connections command.
+var ConnectionsAppName = "clay"
+
+// Connection is a named connection stored in the connections file. The keys are the
+// names of the corresponding sql-connection flags.
+type Connection struct {
+	Type       string            `yaml:"db-type,omitempty"`
+	Host       string            `yaml:"host,omitempty"`
+	Port       int               `yaml:"port,omitempty"`
+	User       string            `yaml:"user,omitempty"`
+	Password   string            `yaml:"password,omitempty"` // #nosec G117 -- Connections intentionally store credentials.
+	Database   string            `yaml:"database,omitempty"`
+	Schema     string            `yaml:"schema,omitempty"`
+	DSN        string            `yaml:"dsn,omitempty"`
+	Driver     string            `yaml:"driver,omitempty"`
+	SSLDisable bool              `yaml:"ssl-disable,omitempty"`
+	ReadOnly   bool              `yaml:"read-only,omitempty"`
+	DSNOptions map[string]string `yaml:"dsn-options,omitempty"`
+}
+
+// GetConnectionsPathForApp returns the default path of the connections file
+// for a given application name, ~/.config/<appName>/connections.yaml.
+func GetConnectionsPathForApp(appName string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "could not get user config or home directory")
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, appName, "connections.yaml"), nil
+}
+
+// ConnectionsEditor reads and edits a connections file, keeping the comments and
+// the order of the entries. A missing file is treated as an empty registry.
+type ConnectionsEditor struct {
+	editor *yaml_editor.YAMLEditor
+	path   string
+}
+
+func NewConnectionsEditor(path string) (*ConnectionsEditor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "could not read connections file %s", path)
+	}
+
+	editor, err := yaml_editor.NewYAMLEditor(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse connections file %s", path)
+	}
+
+	root, err := editor.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	switch root.Kind {
+	case 0, yaml.DocumentNode:
+		// empty file, start with an empty mapping
+		err = editor.SetNode(&yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		})
+		if err != nil {
+			return nil, err
+		}
+	case yaml.MappingNode:
+	default:
+		return nil, errors.Errorf("connections file %s must contain a mapping of connection names", path)
+	}
+
+	return &ConnectionsEditor{
+		editor: editor,
+		path:   path,
+	}, nil
+}
+
+// Path returns the path of the connections file.
+func (e *ConnectionsEditor) Path() string {
+	return e.path
+}
+
+// Save writes the connections file, creating its directory if necessary. The file is
+// only readable by the user, since connections usually contain passwords: it is written
+// to a temporary file created with mode 0600, which then replaces the connections file.
+func (e *ConnectionsEditor) Save() error {
+	dir := filepath.Dir(e.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "could not create directory for %s", e.path)
+	}
+
+	// CreateTemp creates the file with mode 0600
+	f, err := os.CreateTemp(dir, "."+filepath.Base(e.path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "could not create temporary file for %s", e.path)
+	}
+	tmpPath := f.Name()
+	defer func() {
+		_ = os.Remove(tmpPath)
+	}()
+
+	if err := e.editor.Encode(f); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "could not write %s", e.path)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "could not write %s", e.path)
+	}
+	if err := os.Rename(tmpPath, e.path); err != nil {
+		return errors.Wrapf(err, "could not replace %s", e.path)
+	}
+	return nil
+}
+
+// ListConnections returns the names of the connections, in file order.
+func (e *ConnectionsEditor) ListConnections() ([]string, error) {
+	root, err := e.editor.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for i := 0; i < len(root.Content); i += 2 {
+		ret = append(ret, root.Content[i].Value)
+	}
+	return ret, nil
+}
+
+func (e *ConnectionsEditor) HasConnection(name string) bool {
+	_, err := e.editor.GetNode(name)
+	return err == nil
+}
+
+func (e *ConnectionsEditor) GetConnection(name string) (*Connection, error) {
+	node, err := e.editor.GetNode(name)
+	if err != nil {
+		return nil, errors.Errorf("connection %s not found in %s", name, e.path)
+	}
+	ret := &Connection{}
+	if err := node.Decode(ret); err != nil {
+		return nil, errors.Wrapf(err, "could not decode connection %s in %s", name, e.path)
+	}
+	return ret, nil
+}
+
+// SetConnection adds the connection, or replaces the existing connection with the same name.
+func (e *ConnectionsEditor) SetConnection(name string, connection *Connection) error {
+	if name == "" {
+		return errors.New("connection name must not be empty")
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(connection); err != nil {
+		return errors.Wrapf(err, "could not encode connection %s", name)
+	}
+	return e.editor.SetNode(node, name)
+}
+
+func (e *ConnectionsEditor) RemoveConnection(name string) error {
+	if err := e.editor.DeleteNode(name); err != nil {
+		return errors.Errorf("connection %s not found in %s", name, e.path)
+	}
+	return nil
+}
+
+// LoadConnection reads the named connection from the connections file at path,
+// or from the default file of ConnectionsAppName if path is empty.
+func LoadConnection(path string, name string) (*Connection, error) {
+	if path == "" {
+		var err error
+		path, err = GetConnectionsPathForApp(ConnectionsAppName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	editor, err := NewConnectionsEditor(path)
+	if err != nil {
+		return nil, err
+	}
+	return editor.GetConnection(name)
+}
+
+// ToDatabaseConfig returns a config connecting to the connection.
+func (c *Connection) ToDatabaseConfig() *DatabaseConfig {
+	config := &DatabaseConfig{}
+	c.applyTo(config, map[string]bool{})
+	return config
+}
+
+// applyTo copies the connection into config, except for the fields named in explicit,
+// which were set on the command line (or through config files and environment) and win
+// over the connection. The dsn-options are merged, explicit options override the
+// options of the connection.
+func (c *Connection) applyTo(config *DatabaseConfig, explicit map[string]bool) {
+	set := func(name string) bool { return !explicit[name] }
+
+	if set("db-type") && c.Type != "" {
+		config.Type = c.Type
+	}
+	if set("host") {
+		config.Host = c.Host
+	}
+	if set("port") {
+		config.Port = c.Port
+	}
+	if set("user") {
+		config.User = c.User
+	}
+	if set("password") {
+		config.Password = c.Password
+	}
+	if set("database") {
+		config.Database = c.Database
+	}
+	if set("schema") {
+		config.Schema = c.Schema
+	}
+	if set("dsn") {
+		config.DSN = c.DSN
+	}
+	if set("driver") {
+		config.Driver = c.Driver
+	}
+	if set("ssl-disable") {
+		config.SSLDisable = c.SSLDisable
+	}
+	if set("read-only") {
+		config.ReadOnly = c.ReadOnly
+	}
+
+	if len(c.DSNOptions) > 0 {
+		options := map[string]string{}
+		for k, v := range c.DSNOptions {
+			options[k] = v
+		}
+		if explicit["dsn-options"] {
+			for k, v := range config.DSNOptions {
+				options[k] = v
+			}
+		}
+		config.DSNOptions = options
+	}
+}
+
+// explicitFields returns the names of the fields of the sections whose value
+// doesn't come from the defaults.
+func explicitFields(sections ...*values.SectionValues) map[string]bool {
+	ret := map[string]bool{}
+	for _, section := range sections {
+		section.Fields.ForEach(func(name string, value *fields.FieldValue) {
+			if isExplicitField(value) {
+				ret[name] = true
+			}
+		})
+	}
+	return ret
+}
+
+func isExplicitField(value *fields.FieldValue) bool {
+	for _, step := range value.Log {
+		switch step.Source {
+		case "", "none", fields.SourceDefaults:
+		default:
+			return true
+		}
+	}
+	// the steps don't name their source, compare with the default instead
+	if value.Definition == nil || value.Definition.Default == nil {
+		return len(value.Log) > 0
+	}
+	return !reflect.DeepEqual(value.Value, *value.Definition.Default)
+}
+
+// resolveConnection fills config from the connection named by --connection, if set.
+func (c *DatabaseConfig) resolveConnection(explicit map[string]bool) error {
+	if c.Connection == "" {
+		return nil
+	}
+	connection, err := LoadConnection(c.ConnectionsFile, c.Connection)
+	if err != nil {
+		return err
+	}
+	connection.applyTo(c, explicit)
+	return nil
+}
@@ -0,0 +1,211 @@
+package sql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-go-golems/glazed/pkg/cmds/schema"
+	"github.com/go-go-golems/glazed/pkg/cmds/sources"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+)
+
+func TestConnectionsEditor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app", "connections.yaml")
+
+	editor, err := NewConnectionsEditor(path)
+	if err != nil {
+		t.Fatalf("could not open missing connections file: %v", err)
+	}
+	names, err := editor.ListConnections()
+	if err != nil || len(names) != 0 {
+		t.Fatalf("expected no connections, got %v (%v)", names, err)
+	}
+
+	prod := &Connection{Type: "pgx", Host: "db.example.com", User: "app", Password: "s3cret", Database: "app",
+		DSNOptions: map[string]string{"application_name": "reports"}}
+	local := &Connection{Type: "sqlite", Database: "/tmp/local.db"}
+	if err := editor.SetConnection("prod", prod); err != nil {
+		t.Fatalf("could not set connection: %v", err)
+	}
+	if err := editor.SetConnection("local", local); err != nil {
+		t.Fatalf("could not set connection: %v", err)
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("could not save: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("could not stat connections file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected connections file to be private, got %v", info.Mode().Perm())
+	}
+
+	// comments added by hand are kept when editing
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, append([]byte("# my connections\n"), data...), 0o600); err != nil {
+		t.Fatalf("could not write connections file: %v", err)
+	}
+
+	editor, err = NewConnectionsEditor(path)
+	if err != nil {
+		t.Fatalf("could not reopen connections file: %v", err)
+	}
+	got, err := editor.GetConnection("prod")
+	if err != nil {
+		t.Fatalf("could not get connection: %v", err)
+	}
+	if !reflect.DeepEqual(got, prod) {
+		t.Errorf("got %+v, want %+v", got, prod)
+	}
+
+	if names, _ := editor.ListConnections(); !reflect.DeepEqual(names, []string{"prod", "local"}) {
+		t.Errorf("expected connections in file order, got %v", names)
+	}
+
+	if err := editor.RemoveConnection("local"); err != nil {
+		t.Fatalf("could not remove connection: %v", err)
+	}
+	if err := editor.RemoveConnection("local"); err == nil {
+		t.Errorf("expected error when removing a missing connection")
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("could not save: %v", err)
+	}
+
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), "# my connections") {
+		t.Errorf("expected comment to be kept, got:\n%s", data)
+	}
+	c, err := LoadConnection(path, "prod")
+	if err != nil {
+		t.Fatalf("could not load connection: %v", err)
+	}
+	if c.Host != "db.example.com" {
+		t.Errorf("unexpected connection %+v", c)
+	}
+	if _, err := LoadConnection(path, "local"); err == nil {
+		t.Errorf("expected error for removed connection")
+	}
+}
+
+func TestNewConfigFromParsedLayersResolvesConnection(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "connections.yaml")
+	dbPath := filepath.Join(dir, "test.db")
+	editor, err := NewConnectionsEditor(path)
+	if err != nil {
+		t.Fatalf("could not create editor: %v", err)
+	}
+	err = editor.SetConnection("local", &Connection{
+		Type:       "sqlite",
+		Database:   dbPath,
+		User:       "ignored",
+		DSNOptions: map[string]string{"_busy_timeout": "1000", "cache": "shared"},
+	})
+	if err != nil {
+		t.Fatalf("could not set connection: %v", err)
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("could not save: %v", err)
+	}
+
+	parse := func(flags map[string]interface{}) *DatabaseConfig {
+		connectionLayer, err := NewSqlConnectionParameterLayer()
+		if err != nil {
+			t.Fatalf("could not create sql-connection section: %v", err)
+		}
+		dbtLayer, err := NewDbtParameterLayer()
+		if err != nil {
+			t.Fatalf("could not create dbt section: %v", err)
+		}
+		parsedValues := values.New()
+		err = sources.Execute(schema.NewSchema(schema.WithSections(connectionLayer, dbtLayer)), parsedValues,
+			sources.FromMap(map[string]map[string]interface{}{SqlConnectionSlug: flags}),
+			sources.FromDefaults(),
+		)
+		if err != nil {
+			t.Fatalf("could not parse values: %v", err)
+		}
+		config, err := NewConfigFromRawParsedLayers(parsedValues)
+		if err != nil {
+			t.Fatalf("could not create config: %v", err)
+		}
+		return config
+	}
+
+	config := parse(map[string]interface{}{
+		"connection":       "local",
+		"connections-file": path,
+		"user":             "explicit",
+		"dsn-options":      map[string]interface{}{"cache": "private"},
+	})
+	if config.Type != "sqlite" || config.Database != dbPath || config.Port != 0 {
+		t.Errorf("expected connection to be resolved, got %+v", config)
+	}
+	if config.User != "explicit" {
+		t.Errorf("expected explicit user to override the connection, got %s", config.User)
+	}
+	wantOptions := map[string]string{"_busy_timeout": "1000", "cache": "private"}
+	if !reflect.DeepEqual(config.DSNOptions, wantOptions) {
+		t.Errorf("got dsn-options %v, want %v", config.DSNOptions, wantOptions)
+	}
+
+	db, err := config.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	_ = db.Close()
+
+	connectionLayer, _ := NewSqlConnectionParameterLayer()
+	dbtLayer, _ := NewDbtParameterLayer()
+	parsedValues := values.New()
+	err = sources.Execute(schema.NewSchema(schema.WithSections(connectionLayer, dbtLayer)), parsedValues,
+		sources.FromMap(map[string]map[string]interface{}{
+			SqlConnectionSlug: {"connection": "missing", "connections-file": path},
+		}),
+		sources.FromDefaults(),
+	)
+	if err != nil {
+		t.Fatalf("could not parse values: %v", err)
+	}
+	if _, err := NewConfigFromRawParsedLayers(parsedValues); err == nil {
+		t.Errorf("expected error for a missing connection")
+	}
+}
+
+func TestConnectionsEditorSaveReplacesPublicFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "connections.yaml")
+	if err := os.WriteFile(path, []byte("local:\n  db-type: sqlite\n"), 0o644); err != nil {
+		t.Fatalf("could not write connections file: %v", err)
+	}
+
+	editor, err := NewConnectionsEditor(path)
+	if err != nil {
+		t.Fatalf("could not open connections file: %v", err)
+	}
+	if err := editor.SetConnection("prod", &Connection{Type: "pgx", Password: "s3cret"}); err != nil {
+		t.Fatalf("could not set connection: %v", err)
+	}
+	if err := editor.Save(); err != nil {
+		t.Fatalf("could not save: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("could not stat connections file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected connections file to be private, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the connections file in %s, got %d entries", dir, len(entries))
+	}
+}
@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"weak"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// connectionInfo holds the settings of a connection pool that apply to the queries run on
+// it, set by DatabaseConfig.Connect.
+//
+// The functions running queries only get a *sqlx.DB, so the settings are kept in a
+// registry keyed by the *sql.DB of the pool, rather than changing their signatures.
+// Pools not opened by Connect have no settings, and WithQueryTimeout passes a timeout
+// explicitly. The entry of a pool is kept after Close, as long as the pool is reachable,
+// and dropped once the pool is garbage collected.
+type connectionInfo struct {
+	// identity identifies the database in the keys of the query cache, see connectionIdentity.
+	identity string
+	// queryTimeout is the query-timeout of the config, applied as a context deadline by
+	// RunQueryIntoGlaze and the sub-query template functions.
+	queryTimeout time.Duration
+}
+
+var (
+	connectionInfosMutex sync.Mutex
+	// connectionInfos is keyed by weak pointers, so that the entry of a pool is dropped
+	// when the pool is garbage collected, and a new pool at the same address doesn't get it.
+	connectionInfos = map[weak.Pointer[sql.DB]]*connectionInfo{}
+	// lastPoolID numbers the pools that weren't opened by Connect or use an in-memory database.
+	lastPoolID int64
+)
+
+func setConnectionInfo(db *sqlx.DB, info *connectionInfo) {
+	connectionInfosMutex.Lock()
+	defer connectionInfosMutex.Unlock()
+	setConnectionInfoLocked(db, info)
+}
+
+func setConnectionInfoLocked(db *sqlx.DB, info *connectionInfo) {
+	if info.identity == "" {
+		lastPoolID++
+		info.identity = fmt.Sprintf("%s:pool-%d", db.DriverName(), lastPoolID)
+	}
+
+	key := weak.Make(db.DB)
+	_, registered := connectionInfos[key]
+	connectionInfos[key] = info
+	if !registered {
+		runtime.AddCleanup(db.DB, func(key weak.Pointer[sql.DB]) {
+			connectionInfosMutex.Lock()
+			delete(connectionInfos, key)
+			connectionInfosMutex.Unlock()
+		}, key)
+	}
+}
+
+// getConnectionInfo returns the info of db, or nil if there is none.
+func getConnectionInfo(db *sqlx.DB) *connectionInfo {
+	if db == nil || db.DB == nil {
+		return nil
+	}
+	connectionInfosMutex.Lock()
+	defer connectionInfosMutex.Unlock()
+	return connectionInfos[weak.Make(db.DB)]
+}
+
+// connectionIdentity identifies the database of db in the keys of the query cache. The
+// pools opened by Connect on the same database share an identity, derived from the driver
+// and the connection string. Other pools, and in-memory databases, get an identity of
+// their own, which is never reused by another pool.
+func connectionIdentity(db *sqlx.DB) string {
+	connectionInfosMutex.Lock()
+	defer connectionInfosMutex.Unlock()
+
+	key := weak.Make(db.DB)
+	if info, ok := connectionInfos[key]; ok {
+		return info.identity
+	}
+	info := &connectionInfo{}
+	setConnectionInfoLocked(db, info)
+	return info.identity
+}
+
+// dsnIdentity returns the identity of the database at dsn, or "" for in-memory databases,
+// which are private to their pool. The DSN is hashed, since it can contain a password.
+func dsnIdentity(driver string, dsn string) string {
+	lower := strings.ToLower(dsn)
+	switch driver {
+	case "sqlite3":
+		if lower == "" || strings.Contains(lower, ":memory:") || strings.Contains(lower, "mode=memory") {
+			return ""
+		}
+	case "duckdb":
+		if lower == "" || strings.HasPrefix(lower, ":memory:") || strings.HasPrefix(lower, "?") {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s:%x", driver, sha256.Sum256([]byte(dsn)))
+}
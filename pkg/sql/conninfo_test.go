@@ -0,0 +1,73 @@
+package sql
+
+import (
+	"database/sql"
+	"runtime"
+	"testing"
+	"time"
+	"weak"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func hasConnectionInfo(key weak.Pointer[sql.DB]) bool {
+	connectionInfosMutex.Lock()
+	defer connectionInfosMutex.Unlock()
+	_, ok := connectionInfos[key]
+	return ok
+}
+
+func TestConnectionInfoIsDroppedWithThePool(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	setConnectionInfo(db, &connectionInfo{queryTimeout: time.Second})
+	if info := getConnectionInfo(db); info == nil || info.queryTimeout != time.Second {
+		t.Fatalf("expected the connection info to be set, got %+v", info)
+	}
+
+	key := weak.Make(db.DB)
+	if err := db.Close(); err != nil {
+		t.Fatalf("could not close database: %v", err)
+	}
+	// the entry lives as long as the pool, closing it doesn't drop the entry
+	if !hasConnectionInfo(key) {
+		t.Fatalf("expected the entry to be kept until the pool is collected")
+	}
+	db = nil
+
+	for i := 0; i < 50 && hasConnectionInfo(key); i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if hasConnectionInfo(key) {
+		t.Errorf("expected the entry to be dropped once the pool was collected")
+	}
+}
+
+func TestConnectionInfoIsNotSharedBetweenPools(t *testing.T) {
+	db1, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer func() {
+		_ = db1.Close()
+	}()
+	db2, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer func() {
+		_ = db2.Close()
+	}()
+
+	setConnectionInfo(db1, &connectionInfo{queryTimeout: time.Second})
+	if info := getConnectionInfo(db2); info != nil {
+		t.Errorf("expected no info for a pool that wasn't set up, got %+v", info)
+	}
+	// a pool wrapped again by sqlx.NewDb shares the info of the *sql.DB
+	if info := getConnectionInfo(sqlx.NewDb(db1.DB, "sqlite3")); info == nil || info.queryTimeout != time.Second {
+		t.Errorf("expected the info to follow the *sql.DB, got %+v", info)
+	}
+}
@@ -0,0 +1,221 @@
+package sql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/helpers/cast"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// likeEscapeChar is used to escape % and _ in LIKE patterns. It is not a backslash
+// because MySQL interprets backslashes in string literals.
+const likeEscapeChar = '!'
+
+// Dialect describes how a database quotes string literals and identifiers.
+type Dialect struct {
+	Name string
+	// IdentifierQuote is the character used to quote identifiers.
+	IdentifierQuote string
+	// BackslashEscapes is true if backslashes in string literals are escape characters.
+	BackslashEscapes bool
+	// DollarQuotes is true if strings can be dollar-quoted ($$...$$, $tag$...$tag$) and
+	// E'...' strings use backslash escapes.
+	DollarQuotes bool
+	// HashComments is true if # starts a comment that runs to the end of the line.
+	HashComments bool
+	// NestedComments is true if /* */ comments can be nested.
+	NestedComments bool
+	// Explain is the statement prefix returning the plan of a query.
+	Explain string
+	// ExplainJSON is true if Explain returns the plan as a JSON document.
+	ExplainJSON bool
+	// DateLayout and DateTimeLayout are the layouts of the dates of sqlDate and sqlDateTime
+	// in the local timezone, DateLayoutTZ and DateTimeLayoutTZ the layouts of the dates in
+	// other timezones. They default to ISO 8601 (YYYY-MM-DD, YYYY-MM-DDTHH:MM:SS, RFC3339).
+	DateLayout       string
+	DateLayoutTZ     string
+	DateTimeLayout   string
+	DateTimeLayoutTZ string
+}
+
+var (
+	DialectANSI  = &Dialect{Name: "ansi", IdentifierQuote: `"`, Explain: "EXPLAIN"}
+	DialectMySQL = &Dialect{
+		Name: "mysql", IdentifierQuote: "`", BackslashEscapes: true, HashComments: true,
+		Explain: "EXPLAIN FORMAT=JSON", ExplainJSON: true,
+		DateTimeLayout:   "2006-01-02 15:04:05",
+		DateLayoutTZ:     "2006-01-02 15:04:05-07:00",
+		DateTimeLayoutTZ: "2006-01-02 15:04:05-07:00",
+	}
+	DialectPostgres = &Dialect{
+		Name: "postgres", IdentifierQuote: `"`, DollarQuotes: true, NestedComments: true,
+		Explain: "EXPLAIN (FORMAT JSON)", ExplainJSON: true,
+	}
+	DialectSQLite = &Dialect{
+		Name: "sqlite", IdentifierQuote: `"`, Explain: "EXPLAIN QUERY PLAN",
+		DateLayoutTZ:     "2006-01-02",
+		DateTimeLayout:   "2006-01-02 15:04:05",
+		DateTimeLayoutTZ: "2006-01-02 15:04:05",
+	}
+	DialectDuckDB = &Dialect{
+		Name: "duckdb", IdentifierQuote: `"`, DollarQuotes: true, NestedComments: true,
+		Explain: "EXPLAIN",
+	}
+)
+
+// DialectForDriver returns the dialect of a database/sql driver name or db-type.
+// Unknown drivers get the ANSI dialect.
+func DialectForDriver(driver string) *Dialect {
+	switch strings.ToLower(driver) {
+	case "mysql", "mariadb":
+		return DialectMySQL
+	case "pgx", "postgres", "postgresql", "pg":
+		return DialectPostgres
+	case "sqlite", "sqlite3":
+		return DialectSQLite
+	case "duckdb", "duck":
+		return DialectDuckDB
+	default:
+		return DialectANSI
+	}
+}
+
+// DialectForDB returns the dialect of the driver of db, or the ANSI dialect if db is nil.
+func DialectForDB(db *sqlx.DB) *Dialect {
+	if db == nil {
+		return DialectANSI
+	}
+	return DialectForDriver(db.DriverName())
+}
+
+// EscapeString escapes value for use inside a single-quoted string literal.
+func (d *Dialect) EscapeString(value string) string {
+	if d.BackslashEscapes {
+		value = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+// QuoteString returns value as a single-quoted string literal.
+func (d *Dialect) QuoteString(value string) string {
+	return "'" + d.EscapeString(value) + "'"
+}
+
+// QuoteIdentifier quotes a (possibly schema-qualified) identifier. Each dot-separated
+// part is quoted separately, embedded quote characters are doubled.
+func (d *Dialect) QuoteIdentifier(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("empty identifier")
+	}
+	if strings.ContainsRune(name, 0) {
+		return "", errors.Errorf("identifier %q contains a NUL character", name)
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part == "" {
+			return "", errors.Errorf("identifier %q has an empty part", name)
+		}
+		parts[i] = d.IdentifierQuote +
+			strings.ReplaceAll(part, d.IdentifierQuote, d.IdentifierQuote+d.IdentifierQuote) +
+			d.IdentifierQuote
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// QuoteIdentifierList quotes every identifier of names and joins them with commas.
+func (d *Dialect) QuoteIdentifierList(names interface{}) (string, error) {
+	strList, err := cast.CastListToStringList(names)
+	if err != nil {
+		return "", errors.Errorf("could not cast %v to []string", names)
+	}
+	if len(strList) == 0 {
+		return "", errors.New("empty identifier list")
+	}
+	quoted := make([]string, len(strList))
+	for i, name := range strList {
+		quoted[i], err = d.QuoteIdentifier(name)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(quoted, ","), nil
+}
+
+// EscapeLike escapes the LIKE wildcards % and _ (and the escape character itself),
+// to be used with an ESCAPE '!' clause.
+func EscapeLike(value string) string {
+	escape := string(likeEscapeChar)
+	value = strings.ReplaceAll(value, escape, escape+escape)
+	value = strings.ReplaceAll(value, "%", escape+"%")
+	return strings.ReplaceAll(value, "_", escape+"_")
+}
+
+// likeEscapeClause is appended to LIKE patterns built with EscapeLike.
+var likeEscapeClause = fmt.Sprintf(" ESCAPE '%c'", likeEscapeChar)
+
+// LikeContains returns a LIKE pattern literal matching strings containing value,
+// followed by its ESCAPE clause.
+func (d *Dialect) LikeContains(value string) string {
+	return d.QuoteString("%"+EscapeLike(value)+"%") + likeEscapeClause
+}
+
+// Literal formats value as a SQL literal. Numbers are rendered as is,
+// everything else that isn't NULL, a bool or a time is quoted as a string.
+func (d *Dialect) Literal(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return d.QuoteString(string(v))
+	case time.Time:
+		return d.QuoteString(v.Format(time.RFC3339))
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%v", value)
+	case reflect.Bool:
+		if rv.Bool() {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return d.QuoteString(fmt.Sprint(value))
+	}
+}
+
+// FormatDate formats date (a time.Time or a string parsed by fields.ParseDate) with the
+// DateLayout of the dialect, or DateLayoutTZ if it isn't in the local timezone.
+func (d *Dialect) FormatDate(date interface{}) (string, error) {
+	return formatSqlDate(date, layoutOr(d.DateLayoutTZ, time.RFC3339), layoutOr(d.DateLayout, "2006-01-02"))
+}
+
+// FormatDateTime formats date with the DateTimeLayout of the dialect, or DateTimeLayoutTZ
+// if it isn't in the local timezone.
+func (d *Dialect) FormatDateTime(date interface{}) (string, error) {
+	return formatSqlDate(date,
+		layoutOr(d.DateTimeLayoutTZ, time.RFC3339), layoutOr(d.DateTimeLayout, "2006-01-02T15:04:05"))
+}
+
+func layoutOr(layout string, defaultLayout string) string {
+	if layout == "" {
+		return defaultLayout
+	}
+	return layout
+}
+
+// LiteralList formats every value as a literal and joins them with commas.
+func (d *Dialect) LiteralList(values []interface{}) string {
+	literals := make([]string, len(values))
+	for i, v := range values {
+		literals[i] = d.Literal(v)
+	}
+	return strings.Join(literals, ",")
+}
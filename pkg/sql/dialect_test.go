@@ -0,0 +1,239 @@
+package sql
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestDialectQuoting(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect *Dialect
+		value   string
+		str     string
+		ident   string
+	}{
+		{"postgres quote", DialectPostgres, "it's", `'it''s'`, `"it's"`},
+		{"postgres backslash", DialectPostgres, `a\b`, `'a\b'`, `"a\b"`},
+		{"postgres schema", DialectPostgres, `public.user"s`, `'public.user"s'`, `"public"."user""s"`},
+		{"mysql quote", DialectMySQL, "it's", `'it''s'`, "`it's`"},
+		{"mysql backslash", DialectMySQL, `\'`, `'\\'''`, "`\\'`"},
+		{"mysql backtick", DialectMySQL, "a`b", "'a`b'", "`a``b`"},
+		{"sqlite", DialectSQLite, `x"y`, `'x"y'`, `"x""y"`},
+		{"duckdb", DialectDuckDB, "db.t", `'db.t'`, `"db"."t"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.QuoteString(tt.value); got != tt.str {
+				t.Errorf("QuoteString(%q) = %s, want %s", tt.value, got, tt.str)
+			}
+			got, err := tt.dialect.QuoteIdentifier(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.ident {
+				t.Errorf("QuoteIdentifier(%q) = %s, want %s", tt.value, got, tt.ident)
+			}
+		})
+	}
+
+	for _, name := range []string{"", "a..b", ".a", "a\x00b"} {
+		if _, err := DialectPostgres.QuoteIdentifier(name); err == nil {
+			t.Errorf("expected error for identifier %q", name)
+		}
+	}
+}
+
+func TestLikeContains(t *testing.T) {
+	if got, want := DialectSQLite.LikeContains("50%_off!'"), `'%50!%!_off!!''%' ESCAPE '!'`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestSqlIdentList(t *testing.T) {
+	got, err := DialectMySQL.QuoteIdentifierList([]interface{}{"id", "t.name"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "`id`,`t`.`name`"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := DialectMySQL.QuoteIdentifierList([]string{}); err == nil {
+		t.Errorf("expected error for empty identifier list")
+	}
+}
+
+// unquoteMySQLString decodes a single MySQL string literal and reports whether
+// the literal spans the whole input, i.e. nothing after it was left unquoted.
+func unquoteMySQLString(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '\'' {
+		return "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 >= len(s) {
+				return "", false
+			}
+			i++
+			b.WriteByte(s[i])
+		case '\'':
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				b.WriteByte('\'')
+				continue
+			}
+			return b.String(), i == len(s)-1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", false
+}
+
+func FuzzMySQLQuoteString(f *testing.F) {
+	for _, seed := range []string{"", "it's", `\`, `\'`, `\\''`, "' OR 1=1 --"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		quoted := DialectMySQL.QuoteString(value)
+		got, ok := unquoteMySQLString(quoted)
+		if !ok || got != value {
+			t.Fatalf("QuoteString(%q) = %s does not round-trip (got %q)", value, quoted, got)
+		}
+	})
+}
+
+func openFuzzDB(f *testing.F) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		f.Fatalf("could not open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	f.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+// renderSingle renders query with the interpolating template functions and returns
+// the single value it selects.
+func renderSingle(t *testing.T, db *sqlx.DB, query string, data map[string]interface{}) interface{} {
+	ctx := context.Background()
+	rendered, _, err := RenderQuery(ctx, db, query, nil, data, WithInterpolatedValues())
+	if err != nil {
+		t.Fatalf("could not render %s: %v", query, err)
+	}
+	var ret interface{}
+	if err := db.GetContext(ctx, &ret, rendered); err != nil {
+		t.Fatalf("could not run %s: %v", rendered, err)
+	}
+	if b, ok := ret.([]byte); ok {
+		return string(b)
+	}
+	return ret
+}
+
+// sqliteText returns false for values that can't be stored as sqlite text as is.
+func sqliteText(value string) bool {
+	return utf8.ValidString(value) && !strings.ContainsRune(value, 0)
+}
+
+func FuzzSqlString(f *testing.F) {
+	for _, seed := range []string{"", "it's", `\'`, "''", "' OR 1=1 --", "a\nb"} {
+		f.Add(seed)
+	}
+	db := openFuzzDB(f)
+	f.Fuzz(func(t *testing.T, value string) {
+		if !sqliteText(value) {
+			t.Skip()
+		}
+		data := map[string]interface{}{
+			"v":  value,
+			"vs": []string{"x'", value},
+		}
+		if got := renderSingle(t, db, "SELECT {{ sqlString .v }}", data); got != value {
+			t.Fatalf("sqlString(%q) selected %q", value, got)
+		}
+		if got := renderSingle(t, db, "SELECT {{ sqlArg .v }}", data); got != value {
+			t.Fatalf("sqlArg(%q) selected %q", value, got)
+		}
+		if got := renderSingle(t, db, "SELECT {{ sqlString .v }} IN ({{ sqlStringIn .vs }})", data); got != int64(1) {
+			t.Fatalf("sqlStringIn(%q) did not match, got %v", value, got)
+		}
+	})
+}
+
+func FuzzSqlLike(f *testing.F) {
+	for _, seed := range []string{"", "%", "_", "!", "50%_off!'", `\%`} {
+		f.Add(seed)
+	}
+	db := openFuzzDB(f)
+	f.Fuzz(func(t *testing.T, value string) {
+		if !sqliteText(value) {
+			t.Skip()
+		}
+		data := map[string]interface{}{
+			"v":        value,
+			"haystack": "<" + value + ">",
+			"other":    strings.Repeat("x", len(value)),
+		}
+		if got := renderSingle(t, db, "SELECT {{ sqlString .haystack }} LIKE {{ sqlLike .v }}", data); got != int64(1) {
+			t.Fatalf("sqlLike(%q) did not match its haystack, got %v", value, got)
+		}
+		// wildcards in value must not match other characters
+		want := int64(0)
+		if strings.Contains(strings.ToLower(data["other"].(string)), strings.ToLower(value)) {
+			want = 1
+		}
+		if got := renderSingle(t, db, "SELECT {{ sqlString .other }} LIKE {{ sqlStringLike .v }}", data); got != want {
+			t.Fatalf("sqlStringLike(%q) against %q = %v, want %v", value, data["other"], got, want)
+		}
+	})
+}
+
+func FuzzSqlIdent(f *testing.F) {
+	for _, seed := range []string{"id", `a"b`, "select", "a b", "`"} {
+		f.Add(seed)
+	}
+	db := openFuzzDB(f)
+	f.Fuzz(func(t *testing.T, name string) {
+		if name == "" || strings.Contains(name, ".") || !sqliteText(name) {
+			t.Skip()
+		}
+		rendered, _, err := RenderQuery(context.Background(), db, "SELECT 1 AS {{ sqlIdent .name }}", nil,
+			map[string]interface{}{"name": name})
+		if err != nil {
+			t.Fatalf("could not render: %v", err)
+		}
+		rows, err := db.Queryx(rendered)
+		if err != nil {
+			t.Fatalf("could not run %s: %v", rendered, err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatalf("could not get columns: %v", err)
+		}
+		if len(cols) != 1 || cols[0] != name {
+			t.Fatalf("sqlIdent(%q) selected columns %q", name, cols)
+		}
+
+		mysql, err := DialectMySQL.QuoteIdentifier(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		inner := mysql[1 : len(mysql)-1]
+		if strings.ReplaceAll(inner, "``", "") != strings.ReplaceAll(name, "`", "") {
+			t.Fatalf("mysql identifier %s has unescaped backticks", mysql)
+		}
+	})
+}
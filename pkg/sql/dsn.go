@@ -0,0 +1,148 @@
+package sql
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+)
+
+// BuildConnectionString builds the DSN of the source for its driver, escaping the
+// connection fields and appending Options as driver parameters:
+//   - pgx: a key/value DSN, Options are added as keys (sslmode, sslrootcert, application_name, ...)
+//   - mysql: a go-sql-driver DSN, Options are added as parameters (parseTime, tls, charset, ...)
+//   - sqlite3 and duckdb: the database path, Options are added as query parameters
+//     (_journal_mode, _busy_timeout, ... for sqlite3, threads, access_mode, ... for duckdb)
+func (s *Source) BuildConnectionString() (string, error) {
+	switch s.Type {
+	case "pgx":
+		return s.postgresConnectionString(), nil
+	case "mysql":
+		return s.mysqlConnectionString()
+	case "sqlite", "sqlite3", "duckdb":
+		return s.fileConnectionString(), nil
+	default:
+		return "", errors.Errorf("unsupported database type %s", s.Type)
+	}
+}
+
+// sortedOptionKeys returns the keys of Options in a stable order.
+func (s *Source) sortedOptionKeys() []string {
+	keys := make([]string, 0, len(s.Options))
+	for k := range s.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quotePostgresValue quotes a value of a key/value DSN if necessary, escaping
+// backslashes and single quotes.
+func quotePostgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`+"\t\n\r") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func (s *Source) postgresConnectionString() string {
+	sslMode := "disable"
+	if !s.SSLDisable {
+		sslMode = "require"
+	}
+
+	params := [][2]string{}
+	add := func(key string, value string) {
+		if value != "" {
+			params = append(params, [2]string{key, value})
+		}
+	}
+	add("host", s.Hostname)
+	if s.Port != 0 {
+		add("port", strconv.Itoa(s.Port))
+	}
+	add("user", s.Username)
+	add("password", s.Password)
+	add("dbname", s.Database)
+	if _, ok := s.Options["sslmode"]; !ok {
+		add("sslmode", sslMode)
+	}
+	for _, k := range s.sortedOptionKeys() {
+		add(k, s.Options[k])
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = p[0] + "=" + quotePostgresValue(p[1])
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s *Source) mysqlConnectionString() (string, error) {
+	cfg := mysql.NewConfig()
+	if len(s.Options) > 0 {
+		// parse the options with the driver, so that typed options such as parseTime
+		// are validated and end up in their canonical form
+		values := url.Values{}
+		for k, v := range s.Options {
+			values.Set(k, v)
+		}
+		var err error
+		cfg, err = mysql.ParseDSN("/?" + values.Encode())
+		if err != nil {
+			return "", errors.Wrap(err, "invalid mysql options")
+		}
+	}
+
+	cfg.User = s.Username
+	cfg.Passwd = s.Password
+	cfg.Net = "tcp"
+	cfg.Addr = s.Hostname
+	if s.Port != 0 {
+		cfg.Addr = net.JoinHostPort(s.Hostname, strconv.Itoa(s.Port))
+	}
+	cfg.DBName = s.Database
+	return cfg.FormatDSN(), nil
+}
+
+// appendMySQLParam adds a parameter to a go-sql-driver DSN, unless it is already set.
+// The DSN is parsed by the driver, since passwords may contain ? and &.
+func appendMySQLParam(dsn string, key string, value string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", errors.Wrap(err, "could not parse mysql dsn")
+	}
+	if _, ok := cfg.Params[key]; ok {
+		return dsn, nil
+	}
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	cfg.Params[key] = value
+	return cfg.FormatDSN(), nil
+}
+
+func (s *Source) fileConnectionString() string {
+	if len(s.Options) == 0 {
+		return s.Database
+	}
+	values := url.Values{}
+	for k, v := range s.Options {
+		values.Set(k, v)
+	}
+	return appendDSNQuery(s.Database, values.Encode())
+}
+
+// appendDSNQuery appends an encoded query to a path-style DSN.
+func appendDSNQuery(dsn string, query string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + query
+	}
+	return dsn + "?" + query
+}
@@ -0,0 +1,148 @@
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+const trickyPassword = `p@ss w'o\rd/:?=&`
+
+func TestPostgresConnectionStringRoundTrip(t *testing.T) {
+	s := &Source{
+		Type:       "pgx",
+		Hostname:   "db.example.com",
+		Port:       6543,
+		Username:   "analyst",
+		Password:   trickyPassword,
+		Database:   "my warehouse",
+		SSLDisable: true,
+		Options: map[string]string{
+			"application_name": "clay's tool",
+			"search_path":      "public,analytics",
+		},
+	}
+	dsn, err := s.BuildConnectionString()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("could not parse %s: %v", dsn, err)
+	}
+	if cfg.Host != s.Hostname || cfg.Port != uint16(s.Port) || cfg.User != s.Username ||
+		cfg.Password != s.Password || cfg.Database != s.Database {
+		t.Errorf("round trip of %s lost fields: %+v", dsn, cfg)
+	}
+	if cfg.TLSConfig != nil {
+		t.Errorf("expected sslmode=disable")
+	}
+	for k, v := range s.Options {
+		if cfg.RuntimeParams[k] != v {
+			t.Errorf("expected runtime param %s=%q, got %q", k, v, cfg.RuntimeParams[k])
+		}
+	}
+}
+
+func TestMySQLConnectionStringRoundTrip(t *testing.T) {
+	s := &Source{
+		Type:     "mysql",
+		Hostname: "db.example.com",
+		Port:     3307,
+		Username: "root",
+		Password: trickyPassword,
+		Database: "shop",
+		Options: map[string]string{
+			"parseTime": "true",
+			"charset":   "utf8mb4",
+			"sql_mode":  "'ANSI_QUOTES'",
+		},
+	}
+	dsn, err := s.BuildConnectionString()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("could not parse %s: %v", dsn, err)
+	}
+	if cfg.User != s.Username || cfg.Passwd != s.Password || cfg.Addr != "db.example.com:3307" || cfg.DBName != s.Database {
+		t.Errorf("round trip of %s lost fields: %+v", dsn, cfg)
+	}
+	if !cfg.ParseTime {
+		t.Errorf("expected parseTime to be set")
+	}
+	if cfg.Params["sql_mode"] != "'ANSI_QUOTES'" {
+		t.Errorf("expected sql_mode param, got %v", cfg.Params)
+	}
+
+	s.Options = map[string]string{"parseTime": "maybe"}
+	if _, err := s.BuildConnectionString(); err == nil {
+		t.Errorf("expected error for invalid parseTime option")
+	}
+}
+
+func TestFileConnectionStringOptions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	tests := []struct {
+		dbType  string
+		options map[string]string
+		query   string
+		want    string
+	}{
+		{"sqlite3", map[string]string{"_busy_timeout": "4321"}, "PRAGMA busy_timeout", "4321"},
+		{"duckdb", map[string]string{"threads": "3"}, "SELECT current_setting('threads')", "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			s := &Source{Type: tt.dbType, Database: filepath.Join(dir, tt.dbType+" test.db"), Options: tt.options}
+			dsn, err := s.BuildConnectionString()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			db, err := sqlx.Open(tt.dbType, dsn)
+			if err != nil {
+				t.Fatalf("could not open %s: %v", dsn, err)
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			var got string
+			if err := db.GetContext(ctx, &got, tt.query); err != nil {
+				t.Fatalf("could not run %s: %v", tt.query, err)
+			}
+			if got != tt.want {
+				t.Errorf("%s: got %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSNOptionsOverrideProfileOptions(t *testing.T) {
+	path := writeTestDbtProfiles(t)
+	c := &DatabaseConfig{
+		UseDbtProfiles:  true,
+		DbtProfilesPath: path,
+		DbtProfile:      "shop",
+		DSNOptions:      map[string]string{"charset": "latin1", "tls": "skip-verify"},
+	}
+	s, err := c.GetSource()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"parseTime": "true", "charset": "latin1", "tls": "skip-verify"}
+	for k, v := range want {
+		if s.Options[k] != v {
+			t.Errorf("option %s: got %q, want %q", k, s.Options[k], v)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// QueryError is an error rendering or running a query template, or one of the queries run
+// by its template functions (sqlColumn, sqlSlice, sqlSingle, sqlMap). The error of a query
+// run by a template function is wrapped in the error of the template calling it, so that
+// Chain returns the nesting of the queries.
+type QueryError struct {
+	// Template is the name of the template: "query" for the query of a command, the name of
+	// the sub-query for a sub-query, or empty for a query passed inline.
+	Template string
+	// Function is the template function running the query, empty for the query of a command.
+	Function string
+	// Query is the query template.
+	Query string
+	// Line and Column are the 1-based position of the error in the template, 0 if unknown.
+	Line   int
+	Column int
+	// Action is the template action that failed, for example sqlColumn (subQuery "types").
+	Action string
+	// RenderedQuery is the rendered SQL, empty if the template failed to render.
+	RenderedQuery string
+	// Position is the 1-based position (in characters) of the error in RenderedQuery, as
+	// reported by the driver, 0 if unknown.
+	Position int
+	// Code is the error code of the driver (SQLSTATE on PostgreSQL, the error number on
+	// MySQL, the extended result code on SQLite, the error type on DuckDB).
+	Code string
+	Err  error
+}
+
+func (e *QueryError) label() string {
+	name := e.Template
+	if name != "" && e.Line > 0 {
+		name = fmt.Sprintf("%s:%d", name, e.Line)
+		if e.Column > 0 {
+			name = fmt.Sprintf("%s:%d", name, e.Column)
+		}
+	}
+	return strings.TrimSpace(e.Function + " " + name)
+}
+
+// Error returns the location of the error in each nested template and the error of the
+// driver, for example `query:1:32: sqlColumn types: no such table: types`.
+func (e *QueryError) Error() string {
+	label := e.label()
+	if label == "" {
+		return e.Err.Error()
+	}
+	return label + ": " + e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Chain returns e and the errors of the queries it calls, from the outermost template to
+// the query that failed.
+func (e *QueryError) Chain() []*QueryError {
+	ret := []*QueryError{e}
+	for {
+		var next *QueryError
+		if !errors.As(ret[len(ret)-1].Err, &next) {
+			return ret
+		}
+		ret = append(ret, next)
+	}
+}
+
+// Cause returns the error of the query that failed, without the QueryErrors wrapping it.
+func (e *QueryError) Cause() error {
+	chain := e.Chain()
+	return chain[len(chain)-1].Err
+}
+
+// Pretty formats the error over several lines for the command line: the error of the
+// query that failed, then each template of the chain with the failing line highlighted.
+func (e *QueryError) Pretty() string {
+	chain := e.Chain()
+	last := chain[len(chain)-1]
+
+	var b strings.Builder
+	b.WriteString(last.Err.Error())
+	b.WriteString("\n")
+	for _, qe := range chain {
+		if qe.Code != "" {
+			fmt.Fprintf(&b, "  code: %s\n", qe.Code)
+		}
+	}
+
+	for _, qe := range chain {
+		label := qe.label()
+		if label == "" {
+			label = "query"
+		}
+		b.WriteString("\nin " + label)
+		if qe.Action != "" {
+			b.WriteString(", at <" + qe.Action + ">")
+		}
+		b.WriteString("\n")
+
+		switch {
+		case qe.RenderedQuery != "" && qe.Position > 0:
+			line, column := lineAndColumn(qe.RenderedQuery, qe.Position)
+			b.WriteString(highlightLine(qe.RenderedQuery, line, column))
+		case qe.Query != "" && qe.Line > 0:
+			b.WriteString(highlightLine(qe.Query, qe.Line, qe.Column))
+		case qe.RenderedQuery != "":
+			b.WriteString(highlightLine(qe.RenderedQuery, 0, 0))
+		case qe.Query != "":
+			b.WriteString(highlightLine(qe.Query, 0, 0))
+		}
+	}
+
+	return b.String()
+}
+
+// FormatQueryError formats err with QueryError.Pretty if it is or wraps a QueryError, and
+// returns its message otherwise.
+func FormatQueryError(err error) string {
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		return qe.Pretty()
+	}
+	return err.Error()
+}
+
+// lineAndColumn returns the 1-based line and column of the 1-based character position in s.
+func lineAndColumn(s string, position int) (int, int) {
+	line, column := 1, 1
+	i := 1
+	for _, r := range s {
+		if i == position {
+			break
+		}
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+		i++
+	}
+	return line, column
+}
+
+// highlightLines is the number of lines shown before and after the highlighted line.
+const highlightLines = 2
+
+// highlightLine returns the lines of text around line, numbered, with a caret under column.
+// If line is 0, the first lines of text are returned.
+func highlightLine(text string, line int, column int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	start, end := 0, len(lines)
+	if line > 0 {
+		start = line - 1 - highlightLines
+		end = line + highlightLines
+	} else if end > 2*highlightLines+1 {
+		end = 2*highlightLines + 1
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	width := len(strconv.Itoa(end))
+	var b strings.Builder
+	for i := start; i < end; i++ {
+		fmt.Fprintf(&b, "  %*d | %s\n", width, i+1, lines[i])
+		if i == line-1 && column > 0 {
+			// keep the tabs so that the caret lines up
+			prefix := []rune(lines[i])
+			if column-1 < len(prefix) {
+				prefix = prefix[:column-1]
+			}
+			indent := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, string(prefix))
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", indent)
+		}
+	}
+	if line == 0 && end < len(lines) {
+		fmt.Fprintf(&b, "  %*s | ...\n", width, "")
+	}
+	return b.String()
+}
+
+var (
+	templateExecErrorRegexp  = regexp.MustCompile(`^template: [^:]*:(\d+):(\d+): executing "[^"]*" at <(.*?)>: `)
+	templateParseErrorRegexp = regexp.MustCompile(`^template: [^:]*:(\d+): `)
+)
+
+// newTemplateError returns the error of parsing or executing the query template of name.
+// The error of a template function running a query is kept as the wrapped error.
+func newTemplateError(name string, query string, err error) *QueryError {
+	ret := &QueryError{Template: name, Query: query, Err: err}
+
+	var execErr template.ExecError
+	if errors.As(err, &execErr) {
+		msg := execErr.Err.Error()
+		if m := templateExecErrorRegexp.FindStringSubmatch(msg); m != nil {
+			ret.Line, _ = strconv.Atoi(m[1])
+			ret.Column, _ = strconv.Atoi(m[2])
+			// text/template reports the offset of the action in its line
+			ret.Column++
+			ret.Action = m[3]
+			ret.Err = errors.New(strings.TrimPrefix(msg[len(m[0]):], "error calling "))
+		}
+		// errors returned by the template functions are wrapped by text/template
+		if cause := errors.Unwrap(execErr.Err); cause != nil {
+			ret.Err = cause
+		}
+		return ret
+	}
+
+	msg := err.Error()
+	if m := templateParseErrorRegexp.FindStringSubmatch(msg); m != nil {
+		ret.Line, _ = strconv.Atoi(m[1])
+		ret.Err = errors.New(msg[len(m[0]):])
+	}
+	return ret
+}
+
+// newDriverError returns the error of running renderedQuery, with the error code and the
+// position of the error reported by the driver.
+func newDriverError(name string, function string, query string, renderedQuery string, err error) *QueryError {
+	code, position := driverErrorDetails(renderedQuery, err)
+	return &QueryError{
+		Template:      name,
+		Function:      function,
+		Query:         query,
+		RenderedQuery: renderedQuery,
+		Position:      position,
+		Code:          code,
+		Err:           err,
+	}
+}
+
+var (
+	// SQLite (near "FORM": syntax error) and DuckDB (syntax error at or near "FORM")
+	nearQuotedRegexp = regexp.MustCompile(`near "([^"]+)"`)
+	// MySQL (... right syntax to use near 'FORM t' at line 1)
+	nearMySQLRegexp = regexp.MustCompile(`(?s)near '(.+)' at line \d+`)
+)
+
+func driverErrorDetails(renderedQuery string, err error) (string, int) {
+	var pgErr *pgconn.PgError
+	var mysqlErr *mysql.MySQLError
+	var sqliteErr sqlite3.Error
+	var duckdbErr *duckdb.Error
+
+	code := ""
+	switch {
+	case errors.As(err, &pgErr):
+		return pgErr.Code, int(pgErr.Position)
+	case errors.As(err, &mysqlErr):
+		code = strconv.Itoa(int(mysqlErr.Number))
+	case errors.As(err, &sqliteErr):
+		code = strconv.Itoa(int(sqliteErr.ExtendedCode))
+	case errors.As(err, &duckdbErr):
+		if t, _, ok := strings.Cut(duckdbErr.Msg, ":"); ok && strings.HasSuffix(t, " Error") {
+			code = t
+		}
+	default:
+		return "", 0
+	}
+
+	// the other drivers only quote the text the error is near
+	for _, re := range []*regexp.Regexp{nearMySQLRegexp, nearQuotedRegexp} {
+		if m := re.FindStringSubmatch(err.Error()); m != nil {
+			if i := strings.Index(renderedQuery, m[1]); i >= 0 {
+				return code, utf8.RuneCountInString(renderedQuery[:i]) + 1
+			}
+		}
+	}
+	return code, 0
+}
@@ -0,0 +1,136 @@
+package sql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestQueryErrorChain(t *testing.T) {
+	ctx := context.Background()
+	db := openCacheTestDB(t)
+
+	subQueries := map[string]string{
+		"names": "SELECT name\nFROM items\nWHERE id IN ({{ sqlIn (sqlColumn (subQuery \"ids\")) }})",
+		"ids":   "SELECT id\nFROM items WHERE\nORDER BY id",
+	}
+	query := "SELECT *\nFROM items WHERE name IN ({{ sqlIn (sqlColumn (subQuery \"names\")) }})"
+
+	_, _, err := RunQuery(ctx, subQueries, query, nil, db)
+	var qe *QueryError
+	if !errors.As(err, &qe) {
+		t.Fatalf("expected a QueryError, got %v", err)
+	}
+
+	chain := qe.Chain()
+	if len(chain) != 3 {
+		t.Fatalf("expected 3 nested errors, got %d: %v", len(chain), err)
+	}
+	if chain[0].Template != "query" || chain[0].Line != 2 || chain[0].Column != 37 {
+		t.Errorf("unexpected outer error %+v", chain[0])
+	}
+	if chain[0].Action != `sqlColumn (subQuery "names")` {
+		t.Errorf("unexpected action %q", chain[0].Action)
+	}
+	if chain[1].Template != "names" || chain[1].Function != "sqlColumn" || chain[1].Line != 3 || chain[1].Column != 24 {
+		t.Errorf("unexpected nested error %+v", chain[1])
+	}
+
+	inner := chain[2]
+	if inner.Template != "ids" || inner.Function != "sqlColumn" || inner.RenderedQuery != subQueries["ids"] {
+		t.Errorf("unexpected inner error %+v", inner)
+	}
+	if inner.Code != "1" {
+		t.Errorf("got code %q, want 1", inner.Code)
+	}
+	if want := strings.Index(subQueries["ids"], "ORDER") + 1; inner.Position != want {
+		t.Errorf("got position %d, want %d", inner.Position, want)
+	}
+
+	want := `query:2:37: sqlColumn names:3:24: sqlColumn ids: near "ORDER": syntax error`
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+
+	pretty := FormatQueryError(errors.Wrap(err, "Could not generate query"))
+	for _, s := range []string{
+		"near \"ORDER\": syntax error\n  code: 1\n",
+		"\nin query:2:37, at <sqlColumn (subQuery \"names\")>\n",
+		"\nin sqlColumn ids\n  1 | SELECT id\n  2 | FROM items WHERE\n  3 | ORDER BY id\n    | ^\n",
+		"  2 | FROM items WHERE name IN ({{ sqlIn (sqlColumn (subQuery \"names\")) }})\n" +
+			"    |                                     ^\n",
+	} {
+		if !strings.Contains(pretty, s) {
+			t.Errorf("expected %q in:\n%s", s, pretty)
+		}
+	}
+}
+
+func TestQueryErrorTemplate(t *testing.T) {
+	ctx := context.Background()
+	db := openCacheTestDB(t)
+
+	_, _, err := RunQuery(ctx, nil, "SELECT 1\n{{ if .x }}", nil, db)
+	var qe *QueryError
+	if !errors.As(err, &qe) {
+		t.Fatalf("expected a QueryError, got %v", err)
+	}
+	if qe.Line != 2 || qe.Column != 0 || qe.RenderedQuery != "" {
+		t.Errorf("unexpected parse error %+v", qe)
+	}
+
+	_, _, err = RunQuery(ctx, nil, `SELECT {{ sqlColumn "SELECT missing FROM items" }}`, nil, db)
+	if !errors.As(err, &qe) {
+		t.Fatalf("expected a QueryError, got %v", err)
+	}
+	if want := "query:1:11: sqlColumn: no such column: missing"; err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+
+	c := &rowCollector{}
+	query := "SELECT name\nFROM items\nWHERE"
+	err = RunQueryIntoGlaze(ctx, db, query, nil, c, WithQueryName("item-names"))
+	if !errors.As(err, &qe) {
+		t.Fatalf("expected a QueryError, got %v", err)
+	}
+	if qe.RenderedQuery == "" || qe.Code != "1" || qe.Template != "item-names" {
+		t.Errorf("unexpected query error %+v", qe)
+	}
+	if msg := err.Error(); !strings.Contains(msg, query) || !strings.Contains(msg, "item-names: ") {
+		t.Errorf("expected the query and its name in the error, got %q", msg)
+	}
+}
+
+func TestQueryErrorDuckDB(t *testing.T) {
+	db, err := sqlx.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	query := "SELECT 1\nWHERE ORDER BY 1"
+	err = RunQueryIntoGlaze(context.Background(), db, query, nil, &rowCollector{})
+	var qe *QueryError
+	if !errors.As(err, &qe) {
+		t.Fatalf("expected a QueryError, got %v", err)
+	}
+	if qe.Code != "Parser Error" || qe.Position != strings.Index(query, "ORDER")+1 {
+		t.Errorf("unexpected query error %+v", qe)
+	}
+}
+
+func TestHighlightLine(t *testing.T) {
+	text := "a\nb\nc\n\td e\nf\ng\nh"
+	want := "  2 | b\n  3 | c\n  4 | \td e\n    | \t  ^\n  5 | f\n  6 | g\n"
+	if got := highlightLine(text, 4, 4); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if line, column := lineAndColumn(text, 10); line != 4 || column != 4 {
+		t.Errorf("got %d:%d, want 4:4", line, column)
+	}
+}
@@ -0,0 +1,71 @@
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ExplainQuery prefixes query with the EXPLAIN statement of the dialect:
+// EXPLAIN (FORMAT JSON) on Postgres, EXPLAIN FORMAT=JSON on MySQL,
+// EXPLAIN QUERY PLAN on SQLite and EXPLAIN otherwise.
+func (d *Dialect) ExplainQuery(query string) string {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \t\n\r")
+	return d.Explain + " " + query
+}
+
+// ExplainQueryIntoGlaze renders the query template like RunQuery, without running it,
+// and outputs the plan the database would use for it. Each row returned by the EXPLAIN
+// statement of the dialect is passed to gp; JSON plans (Postgres, MySQL) are decoded.
+func ExplainQueryIntoGlaze(
+	ctx context.Context,
+	db *sqlx.DB,
+	query string,
+	subQueries map[string]string,
+	data map[string]interface{},
+	gp middlewares.Processor,
+	options ...QueryResultOption,
+) error {
+	renderedQuery, args, err := renderBoundQuery(ctx, subQueries, query, data, db)
+	if err != nil {
+		return errors.Wrap(err, "Could not render query")
+	}
+
+	dialect := DialectForDB(db)
+	if dialect.ExplainJSON {
+		gp = &jsonPlanProcessor{Processor: gp}
+	}
+
+	return RunQueryIntoGlaze(ctx, db, dialect.ExplainQuery(renderedQuery), args, gp, options...)
+}
+
+// jsonPlanProcessor decodes the JSON plans returned as text before passing the rows on.
+type jsonPlanProcessor struct {
+	middlewares.Processor
+}
+
+func (p *jsonPlanProcessor) AddRow(ctx context.Context, row types.Row) error {
+	for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+		text, ok := pair.Value.(string)
+		if !ok {
+			continue
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+			pair.Value = decoded
+		}
+	}
+	return p.Processor.AddRow(ctx, row)
+}
+
+func (p *jsonPlanProcessor) SetColumnTypes(ctx context.Context, columns []*ColumnType) error {
+	if receiver, ok := p.Processor.(ColumnTypesReceiver); ok {
+		return receiver.SetColumnTypes(ctx, columns)
+	}
+	return nil
+}
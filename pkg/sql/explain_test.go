@@ -0,0 +1,94 @@
+package sql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestExplainQuery(t *testing.T) {
+	tests := []struct {
+		dialect *Dialect
+		want    string
+	}{
+		{dialect: DialectPostgres, want: "EXPLAIN (FORMAT JSON) SELECT 1"},
+		{dialect: DialectMySQL, want: "EXPLAIN FORMAT=JSON SELECT 1"},
+		{dialect: DialectSQLite, want: "EXPLAIN QUERY PLAN SELECT 1"},
+		{dialect: DialectDuckDB, want: "EXPLAIN SELECT 1"},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.ExplainQuery("\n  SELECT 1;\n"); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.dialect.Name, got, tt.want)
+		}
+	}
+}
+
+func TestExplainQueryIntoGlaze(t *testing.T) {
+	ctx := context.Background()
+	query := "SELECT * FROM items WHERE id = {{ sqlArg .id }} AND name = {{ sqlString .name }}"
+	data := map[string]interface{}{"id": 1, "name": "foo"}
+
+	t.Run("sqlite", func(t *testing.T) {
+		db := openCacheTestDB(t)
+		c := &rowCollector{}
+		if err := ExplainQueryIntoGlaze(ctx, db, query, nil, data, c); err != nil {
+			t.Fatalf("could not explain query: %v", err)
+		}
+		if len(c.rows) == 0 {
+			t.Fatalf("expected plan rows")
+		}
+		detail, _ := c.rows[0].Get("detail")
+		if !strings.Contains(detail.(string), "items") {
+			t.Errorf("expected the plan to scan items, got %v", detail)
+		}
+	})
+
+	t.Run("duckdb", func(t *testing.T) {
+		db, err := sqlx.Open("duckdb", "")
+		if err != nil {
+			t.Fatalf("could not open database: %v", err)
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+		if _, err := db.Exec("CREATE TABLE items AS SELECT * FROM range(10) t(id), (VALUES ('foo')) v(name)"); err != nil {
+			t.Fatalf("could not set up database: %v", err)
+		}
+
+		c := &rowCollector{}
+		if err := ExplainQueryIntoGlaze(ctx, db, query, nil, data, c); err != nil {
+			t.Fatalf("could not explain query: %v", err)
+		}
+		if len(c.rows) == 0 {
+			t.Fatalf("expected plan rows")
+		}
+		plan, _ := c.rows[0].Get("explain_value")
+		if !strings.Contains(strings.ToUpper(plan.(string)), "SCAN") {
+			t.Errorf("expected a scan in the plan, got %v", plan)
+		}
+	})
+}
+
+func TestJSONPlanProcessor(t *testing.T) {
+	c := &rowCollector{}
+	p := &jsonPlanProcessor{Processor: c}
+	row := types.NewRow(
+		types.MRP("QUERY PLAN", `[{"Plan": {"Node Type": "Seq Scan"}}]`),
+		types.MRP("other", "not json"),
+	)
+	if err := p.AddRow(context.Background(), row); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plan, _ := c.rows[0].Get("QUERY PLAN")
+	nodes, ok := plan.([]interface{})
+	if !ok || len(nodes) != 1 {
+		t.Fatalf("expected the plan to be decoded, got %#v", plan)
+	}
+	if other, _ := c.rows[0].Get("other"); other != "not json" {
+		t.Errorf("expected text values to be kept, got %v", other)
+	}
+}
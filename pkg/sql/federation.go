@@ -0,0 +1,264 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Federation joins the results of queries on several databases: the queries run on their
+// source database, their results are loaded into tables of an in-memory DuckDB database,
+// and a final query runs on that database.
+//
+// The sources are connected when a query first runs on them, and closed by Close.
+type Federation struct {
+	sources map[string]*DatabaseConfig
+	staging *sqlx.DB
+
+	mutex       sync.Mutex
+	connections map[string]*sqlx.DB
+	// stagedTables are the tables of sqlStage, keyed by source and rendered query.
+	stagedTables map[string]string
+}
+
+// NewFederation creates a Federation over the named sources, with an empty in-memory
+// DuckDB database to stage the results into.
+func NewFederation(sources map[string]*DatabaseConfig) (*Federation, error) {
+	staging, err := sqlx.Open("duckdb", "")
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not open staging database")
+	}
+	// the in-memory database only lives as long as its connection
+	staging.SetMaxOpenConns(1)
+	staging.SetConnMaxLifetime(0)
+	staging.SetConnMaxIdleTime(0)
+
+	return &Federation{
+		sources:      sources,
+		staging:      staging,
+		connections:  map[string]*sqlx.DB{},
+		stagedTables: map[string]string{},
+	}, nil
+}
+
+// DB returns the staging DuckDB database.
+func (f *Federation) DB() *sqlx.DB {
+	return f.staging
+}
+
+// Close closes the connections to the sources and the staging database.
+func (f *Federation) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ret error
+	for name, db := range f.connections {
+		if err := db.Close(); err != nil && ret == nil {
+			ret = errors.Wrapf(err, "Could not close source %s", name)
+		}
+	}
+	f.connections = map[string]*sqlx.DB{}
+	if err := f.staging.Close(); err != nil && ret == nil {
+		ret = errors.Wrap(err, "Could not close staging database")
+	}
+	return ret
+}
+
+func (f *Federation) connect(ctx context.Context, source string) (*sqlx.DB, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if db, ok := f.connections[source]; ok {
+		return db, nil
+	}
+	config, ok := f.sources[source]
+	if !ok {
+		return nil, errors.Errorf("Unknown source %s", source)
+	}
+	db, err := config.Connect(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not connect to source %s", source)
+	}
+	f.connections[source] = db
+	return db, nil
+}
+
+// Stage runs the query template on source, rendered for its dialect, and loads the result
+// into table, replacing it. The column types are inferred from the values (see TableSink);
+// decimals are loaded as text.
+func (f *Federation) Stage(
+	ctx context.Context,
+	table string,
+	source string,
+	query string,
+	subQueries map[string]string,
+	data map[string]interface{},
+) error {
+	db, err := f.connect(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	renderedQuery, args, err := renderBoundQuery(ctx, subQueries, query, data, db)
+	if err != nil {
+		return errors.Wrapf(err, "Could not render query for source %s", source)
+	}
+	return f.stageRendered(ctx, table, source, db, renderedQuery, args)
+}
+
+func (f *Federation) stageRendered(
+	ctx context.Context,
+	table string,
+	source string,
+	db *sqlx.DB,
+	query string,
+	args []interface{},
+) error {
+	quotedTable, err := DialectDuckDB.QuoteIdentifier(table)
+	if err != nil {
+		return errors.Wrap(err, "Invalid staging table name")
+	}
+	if _, err := f.staging.ExecContext(ctx, "DROP TABLE IF EXISTS "+quotedTable); err != nil {
+		return errors.Wrapf(err, "Could not drop staging table %s", table)
+	}
+
+	sink, err := NewTableSink(f.staging, table, WithCreateTable(0))
+	if err != nil {
+		return err
+	}
+
+	settings := newQueryResultSettings(WithNormalizedValues(), WithQueryName(source)).withConnectionTimeout(db)
+	queryCtx, cancel := settings.withTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+
+	rows, err := queryRows(queryCtx, db, query, args)
+	if err != nil {
+		return errors.Wrapf(settings.wrapTimeout(queryCtx, err, start),
+			"Could not execute query on source %s: %s", source, query)
+	}
+	if err := processQueryResults(queryCtx, rows, sink, settings); err != nil {
+		return errors.Wrapf(settings.wrapTimeout(queryCtx, err, start),
+			"Could not stage result of source %s", source)
+	}
+	return sink.Close(ctx)
+}
+
+// sqlStage stages the result of a query on source and returns the quoted name of its
+// table. The query template is rendered with the data of the federated query; staging the
+// same rendered query twice reuses the table.
+func (f *Federation) sqlStage(
+	ctx context.Context,
+	subQueries map[string]string,
+	data map[string]interface{},
+	source string,
+	query string,
+) (string, error) {
+	db, err := f.connect(ctx, source)
+	if err != nil {
+		return "", err
+	}
+	renderedQuery, args, err := renderBoundQuery(ctx, subQueries, query, data, db)
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not render query for source %s", source)
+	}
+
+	key := fmt.Sprintf("%s\x00%s\x00%v", source, renderedQuery, args)
+	f.mutex.Lock()
+	table, ok := f.stagedTables[key]
+	if !ok {
+		table = fmt.Sprintf("stage_%d", len(f.stagedTables)+1)
+		f.stagedTables[key] = table
+	}
+	f.mutex.Unlock()
+
+	if !ok {
+		if err := f.stageRendered(ctx, table, source, db, renderedQuery, args); err != nil {
+			f.mutex.Lock()
+			delete(f.stagedTables, key)
+			f.mutex.Unlock()
+			return "", err
+		}
+	}
+	return DialectDuckDB.QuoteIdentifier(table)
+}
+
+// RunQueryIntoGlaze runs the query template on the staging database, on the tables loaded
+// by Stage. The template can also stage results itself with sqlStage:
+//
+//	SELECT o.id, c.name
+//	FROM {{ sqlStage "shop" "SELECT id, customer_id FROM orders" }} o
+//	JOIN {{ sqlStage "crm" "SELECT id, name FROM customers" }} c ON c.id = o.customer_id
+//
+// The queries of sqlStage are rendered with the same data and sub-queries, for the
+// dialect of their source.
+func (f *Federation) RunQueryIntoGlaze(
+	ctx context.Context,
+	query string,
+	subQueries map[string]string,
+	data map[string]interface{},
+	gp middlewares.Processor,
+	options ...QueryResultOption,
+) error {
+	parent := templateFuncRegistryFromContext(ctx)
+	registry := NewTemplateFuncRegistry()
+	registry.RegisterFactory(func(env *TemplateEnv) template.FuncMap {
+		ret := parent.Funcs(env)
+		// the source queries are rendered with the registry of the caller
+		ret["sqlStage"] = func(source string, query string) (string, error) {
+			return f.sqlStage(ctx, subQueries, env.Data, source, query)
+		}
+		return ret
+	})
+
+	renderedQuery, args, err := renderBoundQuery(
+		ContextWithTemplateFuncRegistry(ctx, registry), subQueries, query, data, f.staging)
+	if err != nil {
+		return errors.Wrap(err, "Could not render federated query")
+	}
+	return RunQueryIntoGlaze(ctx, f.staging, renderedQuery, args, gp, options...)
+}
+
+// StagedQuery is a query of RunFederatedQueryIntoGlaze, whose result is loaded into Table.
+type StagedQuery struct {
+	// Source is the name of the database the query runs on.
+	Source string
+	Table  string
+	// Query is a query template, rendered for the dialect of the source.
+	Query      string
+	SubQueries map[string]string
+}
+
+// RunFederatedQueryIntoGlaze loads the results of the staged queries into an in-memory
+// DuckDB database, and runs the query template on it. The staged queries and the query
+// are rendered with data.
+func RunFederatedQueryIntoGlaze(
+	ctx context.Context,
+	sources map[string]*DatabaseConfig,
+	stages []*StagedQuery,
+	query string,
+	data map[string]interface{},
+	gp middlewares.Processor,
+	options ...QueryResultOption,
+) error {
+	f, err := NewFederation(sources)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	for _, stage := range stages {
+		if err := f.Stage(ctx, stage.Table, stage.Source, stage.Query, stage.SubQueries, data); err != nil {
+			return err
+		}
+	}
+	return f.RunQueryIntoGlaze(ctx, query, nil, data, gp, options...)
+}
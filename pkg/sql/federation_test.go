@@ -0,0 +1,107 @@
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newFederationTestSources(t *testing.T) map[string]*DatabaseConfig {
+	ctx := context.Background()
+	sources := map[string]*DatabaseConfig{
+		"shop": {Type: "sqlite", Database: filepath.Join(t.TempDir(), "shop.db")},
+		"crm":  {Type: "duckdb", Database: filepath.Join(t.TempDir(), "crm.duckdb")},
+	}
+	setup := map[string][]string{
+		"shop": {
+			"CREATE TABLE orders (id INTEGER, customer_id INTEGER, total REAL, status TEXT)",
+			"INSERT INTO orders VALUES (1, 10, 5.5, 'paid'), (2, 20, 7, 'paid'), (3, 10, 1, 'open')",
+		},
+		"crm": {
+			"CREATE TABLE customers (id INTEGER, name VARCHAR)",
+			"INSERT INTO customers VALUES (10, 'ada'), (20, 'bob')",
+		},
+	}
+	for name, stmts := range setup {
+		db, err := sources[name].Connect(ctx)
+		if err != nil {
+			t.Fatalf("could not open %s: %v", name, err)
+		}
+		for _, stmt := range stmts {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				t.Fatalf("could not set up %s: %v", name, err)
+			}
+		}
+		_ = db.Close()
+	}
+	return sources
+}
+
+func federationTestTotals(t *testing.T, c *rowCollector) map[string]float64 {
+	ret := map[string]float64{}
+	for _, row := range c.rows {
+		name, _ := row.Get("name")
+		total, _ := row.Get("total")
+		ret[name.(string)] = total.(float64)
+	}
+	return ret
+}
+
+func TestRunFederatedQueryIntoGlaze(t *testing.T) {
+	ctx := context.Background()
+	sources := newFederationTestSources(t)
+
+	c := &rowCollector{}
+	err := RunFederatedQueryIntoGlaze(ctx, sources,
+		[]*StagedQuery{
+			{Source: "shop", Table: "orders", Query: `SELECT * FROM orders WHERE status = {{ sqlString .status }}`},
+			{Source: "crm", Table: "customers", Query: `SELECT * FROM customers`},
+			{Source: "shop", Table: "none", Query: `SELECT id, total FROM orders WHERE 0`},
+		},
+		`SELECT c.name, SUM(o.total) AS total
+FROM orders o JOIN customers c ON c.id = o.customer_id
+WHERE o.id NOT IN (SELECT id FROM none)
+GROUP BY c.name ORDER BY c.name`,
+		map[string]interface{}{"status": "paid"}, c)
+	if err != nil {
+		t.Fatalf("could not run federated query: %v", err)
+	}
+	if got, want := federationTestTotals(t, c), map[string]float64{"ada": 5.5, "bob": 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFederationSqlStage(t *testing.T) {
+	ctx := context.Background()
+	f, err := NewFederation(newFederationTestSources(t))
+	if err != nil {
+		t.Fatalf("could not create federation: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	c := &rowCollector{}
+	err = f.RunQueryIntoGlaze(ctx, `
+SELECT c.name, SUM(o.total) AS total
+FROM {{ sqlStage "shop" "SELECT customer_id, total FROM orders WHERE status IN ({{ sqlStringIn .statuses }})" }} o
+JOIN {{ sqlStage "crm" "SELECT id, name FROM customers" }} c ON c.id = o.customer_id
+WHERE c.id IN (SELECT id FROM {{ sqlStage "crm" "SELECT id, name FROM customers" }})
+GROUP BY c.name`, nil, map[string]interface{}{"statuses": []string{"paid", "open"}}, c)
+	if err != nil {
+		t.Fatalf("could not run federated query: %v", err)
+	}
+	if got, want := federationTestTotals(t, c), map[string]float64{"ada": 6.5, "bob": 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// the same query is staged once
+	if len(f.stagedTables) != 2 {
+		t.Errorf("expected 2 staged tables, got %v", f.stagedTables)
+	}
+
+	err = f.RunQueryIntoGlaze(ctx, `SELECT * FROM {{ sqlStage "missing" "SELECT 1" }}`, nil, nil, &rowCollector{})
+	if err == nil {
+		t.Errorf("expected an error for an unknown source")
+	}
+}
@@ -0,0 +1,124 @@
+package sql
+
+import (
+	"context"
+	"sync"
+	"text/template"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TemplateEnv is what the query template is created for, passed to the function
+// factories of a TemplateFuncRegistry.
+type TemplateEnv struct {
+	Context context.Context
+	DB      *sqlx.DB
+	Dialect *Dialect
+	Data    map[string]interface{}
+	// Args collects the bound values, nil if the values are interpolated into the query.
+	Args *QueryArgs
+}
+
+// Value returns v as a placeholder bound into Args, or as a literal of Dialect if the
+// values are interpolated.
+func (e *TemplateEnv) Value(v interface{}) string {
+	if e.Args != nil {
+		return e.Args.Bind(v)
+	}
+	return e.Dialect.Literal(v)
+}
+
+// TemplateFuncFactory creates template functions for a template, for functions that
+// depend on the connection, the data or the context (e.g. the tenant of a request).
+type TemplateFuncFactory func(env *TemplateEnv) template.FuncMap
+
+// TemplateFuncRegistry holds template functions added to the query templates, on top of
+// the built-in ones (sqlString, sqlIn, sqlColumn, ...), which they can override.
+// Functions registered for a dialect override the other ones for that dialect.
+type TemplateFuncRegistry struct {
+	mutex        sync.RWMutex
+	funcs        template.FuncMap
+	factories    []TemplateFuncFactory
+	dialectFuncs map[string]template.FuncMap
+}
+
+func NewTemplateFuncRegistry() *TemplateFuncRegistry {
+	return &TemplateFuncRegistry{
+		funcs:        template.FuncMap{},
+		dialectFuncs: map[string]template.FuncMap{},
+	}
+}
+
+// DefaultTemplateFuncRegistry is used by the templates created with a context without
+// registry (see ContextWithTemplateFuncRegistry).
+var DefaultTemplateFuncRegistry = NewTemplateFuncRegistry()
+
+// Register adds the functions to all templates.
+func (r *TemplateFuncRegistry) Register(funcs template.FuncMap) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for name, f := range funcs {
+		r.funcs[name] = f
+	}
+}
+
+// RegisterForDialect adds the functions to the templates of the dialect with the
+// given name (see Dialect.Name), e.g. "postgres".
+func (r *TemplateFuncRegistry) RegisterForDialect(dialect string, funcs template.FuncMap) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	m, ok := r.dialectFuncs[dialect]
+	if !ok {
+		m = template.FuncMap{}
+		r.dialectFuncs[dialect] = m
+	}
+	for name, f := range funcs {
+		m[name] = f
+	}
+}
+
+// RegisterFactory adds the functions created by factory to all templates.
+// The factories are called in the order they were registered.
+func (r *TemplateFuncRegistry) RegisterFactory(factory TemplateFuncFactory) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.factories = append(r.factories, factory)
+}
+
+// Funcs returns the functions for a template created for env: the registered functions,
+// then the functions of the factories, then the functions of the dialect of env.
+func (r *TemplateFuncRegistry) Funcs(env *TemplateEnv) template.FuncMap {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ret := template.FuncMap{}
+	for name, f := range r.funcs {
+		ret[name] = f
+	}
+	for _, factory := range r.factories {
+		for name, f := range factory(env) {
+			ret[name] = f
+		}
+	}
+	if env.Dialect != nil {
+		for name, f := range r.dialectFuncs[env.Dialect.Name] {
+			ret[name] = f
+		}
+	}
+	return ret
+}
+
+type templateFuncRegistryContextKey struct{}
+
+// ContextWithTemplateFuncRegistry makes the templates created with ctx use registry
+// instead of DefaultTemplateFuncRegistry.
+func ContextWithTemplateFuncRegistry(ctx context.Context, registry *TemplateFuncRegistry) context.Context {
+	return context.WithValue(ctx, templateFuncRegistryContextKey{}, registry)
+}
+
+func templateFuncRegistryFromContext(ctx context.Context) *TemplateFuncRegistry {
+	if r, ok := ctx.Value(templateFuncRegistryContextKey{}).(*TemplateFuncRegistry); ok && r != nil {
+		return r
+	}
+	return DefaultTemplateFuncRegistry
+}
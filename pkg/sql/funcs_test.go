@@ -0,0 +1,115 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestTemplateFuncRegistry(t *testing.T) {
+	registry := NewTemplateFuncRegistry()
+	registry.Register(template.FuncMap{
+		"featureEnabled": func(name string) bool { return name == "new-pricing" },
+	})
+	registry.RegisterFactory(func(env *TemplateEnv) template.FuncMap {
+		return template.FuncMap{
+			"tenantScope": func(column string) (string, error) {
+				ident, err := env.Dialect.QuoteIdentifier(column)
+				if err != nil {
+					return "", err
+				}
+				return ident + " = " + env.Value(env.Context.Value(tenantKey{})), nil
+			},
+		}
+	})
+	registry.RegisterForDialect("postgres", template.FuncMap{
+		"sqlString": func(s string) string { return "E" + DialectPostgres.QuoteString(s) },
+	})
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	ctx = ContextWithTemplateFuncRegistry(ctx, registry)
+	query := `SELECT * FROM t WHERE {{ tenantScope "tenant_id" }}{{ if featureEnabled "new-pricing" }} AND v2{{ end }} AND name = {{ sqlString "x" }}`
+
+	sqlite := sqlx.NewDb(nil, "sqlite3")
+	postgres := sqlx.NewDb(nil, "pgx")
+
+	rendered, args, err := RenderQuery(ctx, sqlite, query, nil, nil)
+	if err != nil {
+		t.Fatalf("could not render query: %v", err)
+	}
+	if want := `SELECT * FROM t WHERE "tenant_id" = ? AND v2 AND name = ?`; rendered != want {
+		t.Errorf("got %q, want %q", rendered, want)
+	}
+	if want := []interface{}{"acme", "x"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("got args %v, want %v", args, want)
+	}
+
+	rendered, _, err = RenderQuery(ctx, postgres, query, nil, nil, WithInterpolatedValues())
+	if err != nil {
+		t.Fatalf("could not render query: %v", err)
+	}
+	if want := `SELECT * FROM t WHERE "tenant_id" = 'acme' AND v2 AND name = E'x'`; rendered != want {
+		t.Errorf("got %q, want %q", rendered, want)
+	}
+
+	// the functions of the registry are only available with the context
+	if _, _, err := RenderQuery(context.Background(), sqlite, query, nil, nil); err == nil {
+		t.Errorf("expected the functions of the registry not to be defined")
+	}
+}
+
+type tenantKey struct{}
+
+func TestSqlDateForDialect(t *testing.T) {
+	ctx := context.Background()
+	local := time.Date(2024, 3, 1, 14, 30, 0, 0, time.Local)
+	cet := time.Date(2024, 3, 1, 14, 30, 0, 0, time.FixedZone("CET", 3600))
+	data := map[string]interface{}{"local": local, "cet": cet}
+	query := `{{ sqlDate .local }} {{ sqlDateTime .local }} {{ sqlDate .cet }} {{ sqlDateTime .cet }}`
+
+	tests := []struct {
+		driver    string
+		want      string
+		wantBound string
+	}{
+		{
+			driver:    "sqlite3",
+			want:      `'2024-03-01' '2024-03-01 14:30:00' '2024-03-01' '2024-03-01 14:30:00'`,
+			wantBound: "2024-03-01 14:30:00",
+		},
+		{
+			driver:    "pgx",
+			want:      `'2024-03-01' '2024-03-01T14:30:00' '2024-03-01T14:30:00+01:00' '2024-03-01T14:30:00+01:00'`,
+			wantBound: "2024-03-01T14:30:00+01:00",
+		},
+		{
+			driver:    "mysql",
+			want:      `'2024-03-01' '2024-03-01 14:30:00' '2024-03-01 14:30:00+01:00' '2024-03-01 14:30:00+01:00'`,
+			wantBound: "2024-03-01 14:30:00+01:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			db := sqlx.NewDb(nil, tt.driver)
+			rendered, _, err := RenderQuery(ctx, db, query, nil, data, WithInterpolatedValues())
+			if err != nil {
+				t.Fatalf("could not render query: %v", err)
+			}
+			if rendered != tt.want {
+				t.Errorf("got %q, want %q", rendered, tt.want)
+			}
+
+			_, args, err := RenderQuery(ctx, db, `{{ sqlDateTime .cet }}`, nil, data)
+			if err != nil {
+				t.Fatalf("could not render query: %v", err)
+			}
+			if want := []interface{}{tt.wantBound}; !reflect.DeepEqual(args, want) {
+				t.Errorf("got args %v, want %v", args, want)
+			}
+		})
+	}
+}
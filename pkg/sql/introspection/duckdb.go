@@ -0,0 +1,158 @@
+package introspection
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// duckdbCatalog uses the duckdb_* metadata functions of the current database.
+type duckdbCatalog struct{}
+
+const duckdbSchema = `COALESCE(NULLIF(CAST(? AS VARCHAR), ''), current_schema())`
+
+func (c *duckdbCatalog) tables(ctx context.Context, db *sqlx.DB, schema string) ([]*Table, error) {
+	ret := []*Table{}
+	err := queryEach(ctx, db, `
+SELECT schema_name, table_name, 'table' AS type
+FROM duckdb_tables()
+WHERE database_name = current_database() AND schema_name = `+duckdbSchema+`
+UNION ALL
+SELECT schema_name, view_name, 'view'
+FROM duckdb_views()
+WHERE NOT internal AND database_name = current_database() AND schema_name = `+duckdbSchema+`
+ORDER BY 2`, []interface{}{schema, schema}, func(rows *sqlx.Rows) error {
+		t := &Table{}
+		if err := rows.Scan(&t.Schema, &t.Name, &t.Type); err != nil {
+			return err
+		}
+		ret = append(ret, t)
+		return nil
+	})
+	return ret, err
+}
+
+func (c *duckdbCatalog) columns(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Column, error) {
+	ret := []*Column{}
+	err := queryEach(ctx, db, `
+SELECT c.schema_name, c.table_name, c.column_name, c.column_index, c.data_type, c.is_nullable, c.column_default,
+  EXISTS (
+    SELECT 1 FROM duckdb_constraints() k
+    WHERE k.constraint_type = 'PRIMARY KEY'
+      AND k.database_name = c.database_name AND k.schema_name = c.schema_name AND k.table_name = c.table_name
+      AND list_contains(k.constraint_column_names, c.column_name)
+  )
+FROM duckdb_columns() c
+WHERE c.database_name = current_database() AND c.schema_name = `+duckdbSchema+`
+  AND (CAST(? AS VARCHAR) = '' OR c.table_name = ?)
+ORDER BY c.table_name, c.column_index`, []interface{}{schema, table, table}, func(rows *sqlx.Rows) error {
+		col := &Column{}
+		var default_ sql.NullString
+		if err := rows.Scan(&col.Schema, &col.Table, &col.Name, &col.Position, &col.DataType,
+			&col.Nullable, &default_, &col.PrimaryKey); err != nil {
+			return err
+		}
+		if default_.Valid {
+			col.Default = &default_.String
+		}
+		ret = append(ret, col)
+		return nil
+	})
+	return ret, err
+}
+
+// indexes returns the primary key and unique constraints, which DuckDB doesn't list as
+// indexes, and the indexes created with CREATE INDEX.
+func (c *duckdbCatalog) indexes(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Index, error) {
+	ret := []*Index{}
+	err := queryEach(ctx, db, `
+SELECT schema_name, table_name, constraint_name, constraint_column_names, constraint_type = 'PRIMARY KEY'
+FROM duckdb_constraints()
+WHERE constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+  AND database_name = current_database() AND schema_name = `+duckdbSchema+`
+  AND (CAST(? AS VARCHAR) = '' OR table_name = ?)`, []interface{}{schema, table, table}, func(rows *sqlx.Rows) error {
+		index := &Index{Unique: true}
+		var columns interface{}
+		if err := rows.Scan(&index.Schema, &index.Table, &index.Name, &columns, &index.Primary); err != nil {
+			return err
+		}
+		index.Columns = toStringList(columns)
+		ret = append(ret, index)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = queryEach(ctx, db, `
+SELECT schema_name, table_name, index_name, expressions, is_unique, is_primary
+FROM duckdb_indexes()
+WHERE database_name = current_database() AND schema_name = `+duckdbSchema+`
+  AND (CAST(? AS VARCHAR) = '' OR table_name = ?)`, []interface{}{schema, table, table}, func(rows *sqlx.Rows) error {
+		index := &Index{}
+		var expressions interface{}
+		if err := rows.Scan(&index.Schema, &index.Table, &index.Name, &expressions, &index.Unique, &index.Primary); err != nil {
+			return err
+		}
+		index.Columns = parseDuckDBExpressions(expressions)
+		ret = append(ret, index)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Table != ret[j].Table {
+			return ret[i].Table < ret[j].Table
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
+
+// parseDuckDBExpressions returns the expressions of an index, returned as a list or as
+// its string representation ([a, b]) depending on the DuckDB version.
+func parseDuckDBExpressions(v interface{}) []string {
+	s, ok := v.(string)
+	if !ok {
+		return toStringList(v)
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if s == "" {
+		return []string{}
+	}
+	ret := strings.Split(s, ", ")
+	for i, expression := range ret {
+		ret[i] = strings.Trim(expression, `'"`)
+	}
+	return ret
+}
+
+// foreignKeys returns the foreign keys of the tables. DuckDB doesn't support referential
+// actions, so they are all NO ACTION.
+func (c *duckdbCatalog) foreignKeys(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*ForeignKey, error) {
+	ret := []*ForeignKey{}
+	err := queryEach(ctx, db, `
+SELECT schema_name, table_name, constraint_name, constraint_column_names, referenced_table, referenced_column_names
+FROM duckdb_constraints()
+WHERE constraint_type = 'FOREIGN KEY'
+  AND database_name = current_database() AND schema_name = `+duckdbSchema+`
+  AND (CAST(? AS VARCHAR) = '' OR table_name = ?)
+ORDER BY table_name, constraint_name`, []interface{}{schema, table, table}, func(rows *sqlx.Rows) error {
+		fk := &ForeignKey{OnUpdate: "NO ACTION", OnDelete: "NO ACTION"}
+		var columns, referencedColumns interface{}
+		if err := rows.Scan(&fk.Schema, &fk.Table, &fk.Name, &columns, &fk.ReferencedTable, &referencedColumns); err != nil {
+			return err
+		}
+		fk.ReferencedSchema = fk.Schema
+		fk.Columns = toStringList(columns)
+		fk.ReferencedColumns = toStringList(referencedColumns)
+		ret = append(ret, fk)
+		return nil
+	})
+	return ret, err
+}
@@ -0,0 +1,333 @@
+// Package introspection lists the tables, columns, indexes and foreign keys of a database,
+// with the catalog queries of each supported driver (pgx, mysql, sqlite3 and duckdb),
+// as normalized structs or glaze rows.
+package introspection
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type Table struct {
+	Schema string
+	Name   string
+	// Type is "table" or "view".
+	Type string
+}
+
+type Column struct {
+	Schema string
+	Table  string
+	Name   string
+	// Position is the position of the column in the table, starting at 1.
+	Position   int
+	DataType   string
+	Nullable   bool
+	Default    *string
+	PrimaryKey bool
+}
+
+type Index struct {
+	Schema  string
+	Table   string
+	Name    string
+	Columns []string
+	Unique  bool
+	// Primary is true for the index of the primary key.
+	Primary bool
+}
+
+type ForeignKey struct {
+	Schema string
+	Table  string
+	// Name is empty on SQLite, where foreign keys have no name.
+	Name              string
+	Columns           []string
+	ReferencedSchema  string
+	ReferencedTable   string
+	ReferencedColumns []string
+	// OnUpdate and OnDelete are the referential actions: NO ACTION, RESTRICT, CASCADE,
+	// SET NULL or SET DEFAULT.
+	OnUpdate string
+	OnDelete string
+}
+
+func (t *Table) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("schema", t.Schema),
+		types.MRP("table", t.Name),
+		types.MRP("type", t.Type),
+	)
+}
+
+func (c *Column) ToRow() types.Row {
+	var default_ interface{}
+	if c.Default != nil {
+		default_ = *c.Default
+	}
+	return types.NewRow(
+		types.MRP("schema", c.Schema),
+		types.MRP("table", c.Table),
+		types.MRP("column", c.Name),
+		types.MRP("position", c.Position),
+		types.MRP("data_type", c.DataType),
+		types.MRP("nullable", c.Nullable),
+		types.MRP("default", default_),
+		types.MRP("primary_key", c.PrimaryKey),
+	)
+}
+
+func (i *Index) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("schema", i.Schema),
+		types.MRP("table", i.Table),
+		types.MRP("index", i.Name),
+		types.MRP("columns", i.Columns),
+		types.MRP("unique", i.Unique),
+		types.MRP("primary", i.Primary),
+	)
+}
+
+func (f *ForeignKey) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("schema", f.Schema),
+		types.MRP("table", f.Table),
+		types.MRP("name", f.Name),
+		types.MRP("columns", f.Columns),
+		types.MRP("referenced_schema", f.ReferencedSchema),
+		types.MRP("referenced_table", f.ReferencedTable),
+		types.MRP("referenced_columns", f.ReferencedColumns),
+		types.MRP("on_update", f.OnUpdate),
+		types.MRP("on_delete", f.OnDelete),
+	)
+}
+
+// catalog runs the catalog queries of a database. An empty schema is the current schema,
+// an empty table all the tables of the schema.
+type catalog interface {
+	tables(ctx context.Context, db *sqlx.DB, schema string) ([]*Table, error)
+	columns(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Column, error)
+	indexes(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Index, error)
+	foreignKeys(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*ForeignKey, error)
+}
+
+// Introspector lists the tables, columns, indexes and foreign keys of a schema.
+type Introspector struct {
+	db      *sqlx.DB
+	ownsDB  bool
+	schema  string
+	catalog catalog
+}
+
+type Option func(*Introspector)
+
+// WithSchema sets the schema to inspect (the database on MySQL, the attached database on
+// SQLite). It defaults to the current schema.
+func WithSchema(schema string) Option {
+	return func(i *Introspector) {
+		i.schema = schema
+	}
+}
+
+// New creates an Introspector for db, which must use the pgx, mysql, sqlite3 or duckdb driver.
+func New(db *sqlx.DB, options ...Option) (*Introspector, error) {
+	ret := &Introspector{db: db}
+	switch sql.DialectForDB(db) {
+	case sql.DialectPostgres:
+		ret.catalog = &postgresCatalog{}
+	case sql.DialectMySQL:
+		ret.catalog = &mysqlCatalog{}
+	case sql.DialectSQLite:
+		ret.catalog = &sqliteCatalog{}
+	case sql.DialectDuckDB:
+		ret.catalog = &duckdbCatalog{}
+	default:
+		return nil, errors.Errorf("introspection is not supported for driver %s", db.DriverName())
+	}
+
+	for _, o := range options {
+		o(ret)
+	}
+	return ret, nil
+}
+
+// Connect connects to the database of config and creates an Introspector for it.
+// The connection is closed by Close.
+func Connect(ctx context.Context, config *sql.DatabaseConfig, options ...Option) (*Introspector, error) {
+	db, err := config.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.Schema != "" {
+		options = append([]Option{WithSchema(config.Schema)}, options...)
+	}
+
+	ret, err := New(db, options...)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	ret.ownsDB = true
+	return ret, nil
+}
+
+// Close closes the connection opened by Connect. It doesn't close the connection passed to New.
+func (i *Introspector) Close() error {
+	if i.ownsDB {
+		return i.db.Close()
+	}
+	return nil
+}
+
+// Tables returns the tables and views of the schema, ordered by name.
+func (i *Introspector) Tables(ctx context.Context) ([]*Table, error) {
+	ret, err := i.catalog.tables(ctx, i.db, i.schema)
+	return ret, errors.Wrap(err, "Could not list tables")
+}
+
+// Columns returns the columns of table, or of all the tables if table is empty, ordered by
+// table and position.
+func (i *Introspector) Columns(ctx context.Context, table string) ([]*Column, error) {
+	ret, err := i.catalog.columns(ctx, i.db, i.schema, table)
+	return ret, errors.Wrap(err, "Could not list columns")
+}
+
+// Indexes returns the indexes of table, or of all the tables if table is empty, including
+// the indexes of the primary keys and unique constraints.
+func (i *Introspector) Indexes(ctx context.Context, table string) ([]*Index, error) {
+	ret, err := i.catalog.indexes(ctx, i.db, i.schema, table)
+	return ret, errors.Wrap(err, "Could not list indexes")
+}
+
+// ForeignKeys returns the foreign keys of table, or of all the tables if table is empty.
+func (i *Introspector) ForeignKeys(ctx context.Context, table string) ([]*ForeignKey, error) {
+	ret, err := i.catalog.foreignKeys(ctx, i.db, i.schema, table)
+	return ret, errors.Wrap(err, "Could not list foreign keys")
+}
+
+type rower interface {
+	ToRow() types.Row
+}
+
+func addRows[T rower](ctx context.Context, gp middlewares.Processor, items []T, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := gp.AddRow(ctx, item.ToRow()); err != nil {
+			return errors.Wrapf(err, "Could not process input object")
+		}
+	}
+	return nil
+}
+
+func (i *Introspector) TablesIntoGlaze(ctx context.Context, gp middlewares.Processor) error {
+	tables, err := i.Tables(ctx)
+	return addRows(ctx, gp, tables, err)
+}
+
+func (i *Introspector) ColumnsIntoGlaze(ctx context.Context, table string, gp middlewares.Processor) error {
+	columns, err := i.Columns(ctx, table)
+	return addRows(ctx, gp, columns, err)
+}
+
+func (i *Introspector) IndexesIntoGlaze(ctx context.Context, table string, gp middlewares.Processor) error {
+	indexes, err := i.Indexes(ctx, table)
+	return addRows(ctx, gp, indexes, err)
+}
+
+func (i *Introspector) ForeignKeysIntoGlaze(ctx context.Context, table string, gp middlewares.Processor) error {
+	foreignKeys, err := i.ForeignKeys(ctx, table)
+	return addRows(ctx, gp, foreignKeys, err)
+}
+
+// queryEach runs query and calls scan for each row. The rows are closed before it
+// returns, so that scan can't hold the connection while running other queries.
+func queryEach(
+	ctx context.Context,
+	db *sqlx.DB,
+	query string,
+	args []interface{},
+	scan func(rows *sqlx.Rows) error,
+) error {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// tableType normalizes the table types of the catalogs (BASE TABLE, VIEW, ...).
+func tableType(t string) string {
+	if strings.Contains(strings.ToUpper(t), "VIEW") {
+		return "view"
+	}
+	return "table"
+}
+
+// toStringList converts the list values returned by the drivers to strings.
+func toStringList(v interface{}) []string {
+	switch v := v.(type) {
+	case []interface{}:
+		ret := make([]string, len(v))
+		for i, elt := range v {
+			ret[i] = fmt.Sprint(elt)
+		}
+		return ret
+	case []string:
+		return v
+	default:
+		return nil
+	}
+}
+
+// appendIndexColumn adds column to the last index of indexes if it is the same index,
+// for catalogs returning a row per column of an index.
+func appendIndexColumn(indexes []*Index, index *Index, column string) []*Index {
+	if n := len(indexes); n > 0 {
+		last := indexes[n-1]
+		if last.Schema == index.Schema && last.Table == index.Table && last.Name == index.Name {
+			last.Columns = append(last.Columns, column)
+			return indexes
+		}
+	}
+	index.Columns = []string{column}
+	return append(indexes, index)
+}
+
+// appendForeignKeyColumn adds the column pair to the last foreign key of foreignKeys if
+// it is the same foreign key, for catalogs returning a row per column of a foreign key.
+func appendForeignKeyColumn(
+	foreignKeys []*ForeignKey,
+	foreignKey *ForeignKey,
+	column string,
+	referencedColumn string,
+) []*ForeignKey {
+	if n := len(foreignKeys); n > 0 {
+		last := foreignKeys[n-1]
+		if last.Schema == foreignKey.Schema && last.Table == foreignKey.Table && last.Name == foreignKey.Name {
+			last.Columns = append(last.Columns, column)
+			last.ReferencedColumns = append(last.ReferencedColumns, referencedColumn)
+			return foreignKeys
+		}
+	}
+	foreignKey.Columns = []string{column}
+	foreignKey.ReferencedColumns = []string{referencedColumn}
+	return append(foreignKeys, foreignKey)
+}
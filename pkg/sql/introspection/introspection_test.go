@@ -0,0 +1,186 @@
+package introspection
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+)
+
+// rowCollector collects the rows passed to it.
+type rowCollector struct {
+	rows []types.Row
+}
+
+func (c *rowCollector) AddRow(ctx context.Context, row types.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Close(ctx context.Context) error {
+	return nil
+}
+
+var testSchema = []string{
+	"CREATE TABLE authors (id INTEGER PRIMARY KEY, name VARCHAR NOT NULL, email VARCHAR UNIQUE)",
+	`CREATE TABLE posts (
+  id INTEGER,
+  author_id INTEGER NOT NULL REFERENCES authors (id),
+  title VARCHAR DEFAULT 'untitled',
+  PRIMARY KEY (id)
+)`,
+	"CREATE INDEX posts_author ON posts (author_id, id)",
+	"CREATE VIEW post_titles AS SELECT title FROM posts",
+}
+
+func openTestDB(t *testing.T, driver string) *sqlx.DB {
+	dsn := ""
+	if driver == "sqlite3" {
+		dsn = ":memory:"
+	}
+	db, err := sqlx.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	for _, stmt := range testSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("could not set up database: %v", err)
+		}
+	}
+	return db
+}
+
+func TestIntrospector(t *testing.T) {
+	ctx := context.Background()
+
+	for _, driver := range []string{"sqlite3", "duckdb"} {
+		t.Run(driver, func(t *testing.T) {
+			i, err := New(openTestDB(t, driver))
+			if err != nil {
+				t.Fatalf("could not create introspector: %v", err)
+			}
+
+			tables, err := i.Tables(ctx)
+			if err != nil {
+				t.Fatalf("could not list tables: %v", err)
+			}
+			want := []*Table{
+				{Schema: "main", Name: "authors", Type: "table"},
+				{Schema: "main", Name: "post_titles", Type: "view"},
+				{Schema: "main", Name: "posts", Type: "table"},
+			}
+			if !reflect.DeepEqual(tables, want) {
+				t.Errorf("got tables %v, want %v", tables, want)
+			}
+
+			columns, err := i.Columns(ctx, "posts")
+			if err != nil {
+				t.Fatalf("could not list columns: %v", err)
+			}
+			if len(columns) != 3 {
+				t.Fatalf("expected 3 columns, got %v", columns)
+			}
+			if c := columns[0]; c.Name != "id" || c.Position != 1 || !c.PrimaryKey || c.DataType != "INTEGER" {
+				t.Errorf("unexpected id column %+v", c)
+			}
+			if c := columns[1]; c.Name != "author_id" || c.Nullable || c.PrimaryKey {
+				t.Errorf("unexpected author_id column %+v", c)
+			}
+			if c := columns[2]; c.Name != "title" || !c.Nullable || c.Default == nil || *c.Default != "'untitled'" {
+				t.Errorf("unexpected title column %+v", c)
+			}
+
+			allColumns, err := i.Columns(ctx, "")
+			if err != nil {
+				t.Fatalf("could not list columns: %v", err)
+			}
+			// authors, post_titles and posts
+			if len(allColumns) != 7 {
+				t.Errorf("expected 7 columns, got %d", len(allColumns))
+			}
+
+			indexes, err := i.Indexes(ctx, "posts")
+			if err != nil {
+				t.Fatalf("could not list indexes: %v", err)
+			}
+			var authorIndex *Index
+			for _, index := range indexes {
+				if index.Name == "posts_author" {
+					authorIndex = index
+				}
+			}
+			if authorIndex == nil {
+				t.Fatalf("expected the posts_author index, got %v", indexes)
+			}
+			if !reflect.DeepEqual(authorIndex.Columns, []string{"author_id", "id"}) || authorIndex.Unique {
+				t.Errorf("unexpected index %+v", authorIndex)
+			}
+
+			indexes, err = i.Indexes(ctx, "authors")
+			if err != nil {
+				t.Fatalf("could not list indexes: %v", err)
+			}
+			var emailIndex *Index
+			for _, index := range indexes {
+				if reflect.DeepEqual(index.Columns, []string{"email"}) {
+					emailIndex = index
+				}
+			}
+			if emailIndex == nil || !emailIndex.Unique || emailIndex.Primary {
+				t.Errorf("expected a unique index on email, got %v", indexes)
+			}
+
+			foreignKeys, err := i.ForeignKeys(ctx, "")
+			if err != nil {
+				t.Fatalf("could not list foreign keys: %v", err)
+			}
+			if len(foreignKeys) != 1 {
+				t.Fatalf("expected 1 foreign key, got %v", foreignKeys)
+			}
+			fk := foreignKeys[0]
+			if fk.Table != "posts" || fk.ReferencedTable != "authors" ||
+				!reflect.DeepEqual(fk.Columns, []string{"author_id"}) ||
+				!reflect.DeepEqual(fk.ReferencedColumns, []string{"id"}) ||
+				fk.OnDelete != "NO ACTION" {
+				t.Errorf("unexpected foreign key %+v", fk)
+			}
+		})
+	}
+}
+
+func TestIntrospectorIntoGlaze(t *testing.T) {
+	ctx := context.Background()
+	i, err := New(openTestDB(t, "sqlite3"))
+	if err != nil {
+		t.Fatalf("could not create introspector: %v", err)
+	}
+
+	c := &rowCollector{}
+	if err := i.ColumnsIntoGlaze(ctx, "authors", c); err != nil {
+		t.Fatalf("could not list columns: %v", err)
+	}
+	if len(c.rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(c.rows))
+	}
+	if name, _ := c.rows[1].Get("column"); name != "name" {
+		t.Errorf("got column %v, want name", name)
+	}
+	if nullable, _ := c.rows[1].Get("nullable"); nullable != false {
+		t.Errorf("expected name not to be nullable")
+	}
+	if default_, _ := c.rows[1].Get("default"); default_ != nil {
+		t.Errorf("expected no default, got %v", default_)
+	}
+}
+
+func TestNewUnsupportedDriver(t *testing.T) {
+	if _, err := New(sqlx.NewDb(nil, "sqlserver")); err == nil {
+		t.Errorf("expected an error for an unsupported driver")
+	}
+}
@@ -0,0 +1,100 @@
+package introspection
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// mysqlCatalog queries information_schema. The schema is the database.
+type mysqlCatalog struct{}
+
+const mysqlSchema = `COALESCE(NULLIF(?, ''), DATABASE())`
+
+func (c *mysqlCatalog) tables(ctx context.Context, db *sqlx.DB, schema string) ([]*Table, error) {
+	ret := []*Table{}
+	err := queryEach(ctx, db, `
+SELECT table_schema, table_name, table_type
+FROM information_schema.tables
+WHERE table_schema = `+mysqlSchema+`
+ORDER BY table_name`, []interface{}{schema}, func(rows *sqlx.Rows) error {
+		t := &Table{}
+		if err := rows.Scan(&t.Schema, &t.Name, &t.Type); err != nil {
+			return err
+		}
+		t.Type = tableType(t.Type)
+		ret = append(ret, t)
+		return nil
+	})
+	return ret, err
+}
+
+func (c *mysqlCatalog) columns(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Column, error) {
+	ret := []*Column{}
+	err := queryEach(ctx, db, `
+SELECT table_schema, table_name, column_name, ordinal_position, column_type,
+  is_nullable = 'YES', column_default, column_key = 'PRI'
+FROM information_schema.columns
+WHERE table_schema = `+mysqlSchema+` AND (? = '' OR table_name = ?)
+ORDER BY table_name, ordinal_position`, []interface{}{schema, table, table}, func(rows *sqlx.Rows) error {
+		col := &Column{}
+		var default_ sql.NullString
+		if err := rows.Scan(&col.Schema, &col.Table, &col.Name, &col.Position, &col.DataType,
+			&col.Nullable, &default_, &col.PrimaryKey); err != nil {
+			return err
+		}
+		if default_.Valid {
+			col.Default = &default_.String
+		}
+		ret = append(ret, col)
+		return nil
+	})
+	return ret, err
+}
+
+func (c *mysqlCatalog) indexes(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Index, error) {
+	ret := []*Index{}
+	err := queryEach(ctx, db, `
+SELECT table_schema, table_name, index_name, non_unique = 0, index_name = 'PRIMARY', column_name
+FROM information_schema.statistics
+WHERE table_schema = `+mysqlSchema+` AND (? = '' OR table_name = ?)
+ORDER BY table_name, index_name, seq_in_index`, []interface{}{schema, table, table}, func(rows *sqlx.Rows) error {
+		index := &Index{}
+		// column_name is NULL for the expressions of functional indexes
+		var column sql.NullString
+		if err := rows.Scan(&index.Schema, &index.Table, &index.Name, &index.Unique, &index.Primary, &column); err != nil {
+			return err
+		}
+		if column.Valid {
+			ret = appendIndexColumn(ret, index, column.String)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func (c *mysqlCatalog) foreignKeys(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*ForeignKey, error) {
+	ret := []*ForeignKey{}
+	err := queryEach(ctx, db, `
+SELECT k.table_schema, k.table_name, k.constraint_name, k.column_name,
+  k.referenced_table_schema, k.referenced_table_name, k.referenced_column_name, r.update_rule, r.delete_rule
+FROM information_schema.key_column_usage k
+JOIN information_schema.referential_constraints r
+  ON r.constraint_schema = k.constraint_schema AND r.constraint_name = k.constraint_name
+  AND r.table_name = k.table_name
+WHERE k.table_schema = `+mysqlSchema+` AND (? = '' OR k.table_name = ?)
+  AND k.referenced_table_name IS NOT NULL
+ORDER BY k.table_name, k.constraint_name, k.ordinal_position`, []interface{}{schema, table, table},
+		func(rows *sqlx.Rows) error {
+			fk := &ForeignKey{}
+			var column, referencedColumn string
+			if err := rows.Scan(&fk.Schema, &fk.Table, &fk.Name, &column,
+				&fk.ReferencedSchema, &fk.ReferencedTable, &referencedColumn, &fk.OnUpdate, &fk.OnDelete); err != nil {
+				return err
+			}
+			ret = appendForeignKeyColumn(ret, fk, column, referencedColumn)
+			return nil
+		})
+	return ret, err
+}
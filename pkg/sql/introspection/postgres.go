@@ -0,0 +1,126 @@
+package introspection
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// postgresCatalog queries pg_catalog, which unlike information_schema has the full column
+// types and the indexes.
+type postgresCatalog struct{}
+
+const postgresSchema = `COALESCE(NULLIF($1::text, ''), current_schema())`
+
+func (c *postgresCatalog) tables(ctx context.Context, db *sqlx.DB, schema string) ([]*Table, error) {
+	ret := []*Table{}
+	err := queryEach(ctx, db, `
+SELECT n.nspname, c.relname, CASE WHEN c.relkind IN ('v', 'm') THEN 'view' ELSE 'table' END
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'f', 'v', 'm') AND NOT c.relispartition
+  AND n.nspname = `+postgresSchema+`
+ORDER BY c.relname`, []interface{}{schema}, func(rows *sqlx.Rows) error {
+		t := &Table{}
+		if err := rows.Scan(&t.Schema, &t.Name, &t.Type); err != nil {
+			return err
+		}
+		ret = append(ret, t)
+		return nil
+	})
+	return ret, err
+}
+
+func (c *postgresCatalog) columns(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Column, error) {
+	ret := []*Column{}
+	err := queryEach(ctx, db, `
+SELECT n.nspname, c.relname, a.attname, a.attnum, format_type(a.atttypid, a.atttypmod),
+  NOT a.attnotnull, pg_get_expr(d.adbin, d.adrelid), COALESCE(a.attnum = ANY(pk.conkey), false)
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+LEFT JOIN pg_constraint pk ON pk.conrelid = c.oid AND pk.contype = 'p'
+WHERE a.attnum > 0 AND NOT a.attisdropped
+  AND c.relkind IN ('r', 'p', 'f', 'v', 'm')
+  AND n.nspname = `+postgresSchema+`
+  AND ($2::text = '' OR c.relname = $2::text)
+ORDER BY c.relname, a.attnum`, []interface{}{schema, table}, func(rows *sqlx.Rows) error {
+		col := &Column{}
+		var default_ sql.NullString
+		if err := rows.Scan(&col.Schema, &col.Table, &col.Name, &col.Position, &col.DataType,
+			&col.Nullable, &default_, &col.PrimaryKey); err != nil {
+			return err
+		}
+		if default_.Valid {
+			col.Default = &default_.String
+		}
+		ret = append(ret, col)
+		return nil
+	})
+	return ret, err
+}
+
+func (c *postgresCatalog) indexes(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Index, error) {
+	ret := []*Index{}
+	err := queryEach(ctx, db, `
+SELECT n.nspname, t.relname, i.relname, ix.indisunique, ix.indisprimary, a.attname
+FROM pg_index ix
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord)
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE n.nspname = `+postgresSchema+`
+  AND ($2::text = '' OR t.relname = $2::text)
+ORDER BY t.relname, i.relname, k.ord`, []interface{}{schema, table}, func(rows *sqlx.Rows) error {
+		index := &Index{}
+		var column string
+		if err := rows.Scan(&index.Schema, &index.Table, &index.Name, &index.Unique, &index.Primary, &column); err != nil {
+			return err
+		}
+		ret = appendIndexColumn(ret, index, column)
+		return nil
+	})
+	return ret, err
+}
+
+var postgresActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+func (c *postgresCatalog) foreignKeys(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*ForeignKey, error) {
+	ret := []*ForeignKey{}
+	err := queryEach(ctx, db, `
+SELECT n.nspname, cl.relname, c.conname, a.attname, fn.nspname, fcl.relname, fa.attname,
+  c.confupdtype::text, c.confdeltype::text
+FROM pg_constraint c
+JOIN pg_class cl ON cl.oid = c.conrelid
+JOIN pg_namespace n ON n.oid = cl.relnamespace
+JOIN pg_class fcl ON fcl.oid = c.confrelid
+JOIN pg_namespace fn ON fn.oid = fcl.relnamespace
+CROSS JOIN LATERAL unnest(c.conkey, c.confkey) WITH ORDINALITY AS k(attnum, fattnum, ord)
+JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+JOIN pg_attribute fa ON fa.attrelid = c.confrelid AND fa.attnum = k.fattnum
+WHERE c.contype = 'f'
+  AND n.nspname = `+postgresSchema+`
+  AND ($2::text = '' OR cl.relname = $2::text)
+ORDER BY cl.relname, c.conname, k.ord`, []interface{}{schema, table}, func(rows *sqlx.Rows) error {
+		fk := &ForeignKey{}
+		var column, referencedColumn, onUpdate, onDelete string
+		if err := rows.Scan(&fk.Schema, &fk.Table, &fk.Name, &column,
+			&fk.ReferencedSchema, &fk.ReferencedTable, &referencedColumn, &onUpdate, &onDelete); err != nil {
+			return err
+		}
+		fk.OnUpdate = postgresActions[onUpdate]
+		fk.OnDelete = postgresActions[onDelete]
+		ret = appendForeignKeyColumn(ret, fk, column, referencedColumn)
+		return nil
+	})
+	return ret, err
+}
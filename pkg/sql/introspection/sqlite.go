@@ -0,0 +1,221 @@
+package introspection
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	clay_sql "github.com/go-go-golems/clay/pkg/sql"
+	"github.com/jmoiron/sqlx"
+)
+
+// sqliteCatalog uses sqlite_master and the table-valued pragma functions. The schema is
+// the attached database, main by default.
+type sqliteCatalog struct{}
+
+func sqliteSchema(schema string) string {
+	if schema == "" {
+		return "main"
+	}
+	return schema
+}
+
+func (c *sqliteCatalog) tables(ctx context.Context, db *sqlx.DB, schema string) ([]*Table, error) {
+	schema = sqliteSchema(schema)
+	quotedSchema, err := clay_sql.DialectSQLite.QuoteIdentifier(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*Table{}
+	err = queryEach(ctx, db, `
+SELECT name, type
+FROM `+quotedSchema+`.sqlite_master
+WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite!_%' ESCAPE '!'
+ORDER BY name`, nil, func(rows *sqlx.Rows) error {
+		t := &Table{Schema: schema}
+		if err := rows.Scan(&t.Name, &t.Type); err != nil {
+			return err
+		}
+		t.Type = tableType(t.Type)
+		ret = append(ret, t)
+		return nil
+	})
+	return ret, err
+}
+
+// tableNames returns table, or the names of all the tables and views if table is empty.
+func (c *sqliteCatalog) tableNames(ctx context.Context, db *sqlx.DB, schema string, table string) ([]string, error) {
+	if table != "" {
+		return []string{table}, nil
+	}
+	tables, err := c.tables(ctx, db, schema)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, len(tables))
+	for i, t := range tables {
+		ret[i] = t.Name
+	}
+	return ret, nil
+}
+
+func (c *sqliteCatalog) columns(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Column, error) {
+	schema = sqliteSchema(schema)
+	tables, err := c.tableNames(ctx, db, schema, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*Column{}
+	for _, table := range tables {
+		err := queryEach(ctx, db, `
+SELECT cid, name, type, "notnull", dflt_value, pk
+FROM pragma_table_info(?, ?)
+ORDER BY cid`, []interface{}{table, schema}, func(rows *sqlx.Rows) error {
+			col := &Column{Schema: schema, Table: table}
+			var notNull bool
+			var default_ sql.NullString
+			var pk int
+			if err := rows.Scan(&col.Position, &col.Name, &col.DataType, &notNull, &default_, &pk); err != nil {
+				return err
+			}
+			col.Position++
+			col.Nullable = !notNull
+			col.PrimaryKey = pk > 0
+			if default_.Valid {
+				col.Default = &default_.String
+			}
+			ret = append(ret, col)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// indexes returns the indexes of the tables. An INTEGER PRIMARY KEY column is the rowid
+// of its table and has no index.
+func (c *sqliteCatalog) indexes(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*Index, error) {
+	schema = sqliteSchema(schema)
+	tables, err := c.tableNames(ctx, db, schema, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*Index{}
+	for _, table := range tables {
+		indexes := []*Index{}
+		err := queryEach(ctx, db, `
+SELECT name, "unique", origin
+FROM pragma_index_list(?, ?)
+ORDER BY name`, []interface{}{table, schema}, func(rows *sqlx.Rows) error {
+			index := &Index{Schema: schema, Table: table}
+			var origin string
+			if err := rows.Scan(&index.Name, &index.Unique, &origin); err != nil {
+				return err
+			}
+			index.Primary = origin == "pk"
+			indexes = append(indexes, index)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, index := range indexes {
+			index.Columns = []string{}
+			err := queryEach(ctx, db, `
+SELECT name
+FROM pragma_index_info(?, ?)
+ORDER BY seqno`, []interface{}{index.Name, schema}, func(rows *sqlx.Rows) error {
+				// name is NULL for expressions
+				var column sql.NullString
+				if err := rows.Scan(&column); err != nil {
+					return err
+				}
+				if column.Valid {
+					index.Columns = append(index.Columns, column.String)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, indexes...)
+	}
+	return ret, nil
+}
+
+func (c *sqliteCatalog) foreignKeys(ctx context.Context, db *sqlx.DB, schema string, table string) ([]*ForeignKey, error) {
+	schema = sqliteSchema(schema)
+	tables, err := c.tableNames(ctx, db, schema, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*ForeignKey{}
+	for _, table := range tables {
+		foreignKeys := []*ForeignKey{}
+		lastID := -1
+		err := queryEach(ctx, db, `
+SELECT id, "table", "from", "to", on_update, on_delete
+FROM pragma_foreign_key_list(?, ?)
+ORDER BY id, seq`, []interface{}{table, schema}, func(rows *sqlx.Rows) error {
+			fk := &ForeignKey{Schema: schema, Table: table, ReferencedSchema: schema}
+			var id int
+			var column string
+			// to is NULL when referencing the primary key implicitly
+			var referencedColumn sql.NullString
+			if err := rows.Scan(&id, &fk.ReferencedTable, &column, &referencedColumn, &fk.OnUpdate, &fk.OnDelete); err != nil {
+				return err
+			}
+			if id != lastID {
+				foreignKeys = append(foreignKeys, fk)
+				lastID = id
+			}
+			last := foreignKeys[len(foreignKeys)-1]
+			last.Columns = append(last.Columns, column)
+			last.ReferencedColumns = append(last.ReferencedColumns, referencedColumn.String)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fk := range foreignKeys {
+			if err := c.resolveReferencedColumns(ctx, db, fk); err != nil {
+				return nil, err
+			}
+			fk.OnUpdate = strings.ToUpper(fk.OnUpdate)
+			fk.OnDelete = strings.ToUpper(fk.OnDelete)
+		}
+		ret = append(ret, foreignKeys...)
+	}
+	return ret, nil
+}
+
+// resolveReferencedColumns replaces the implicit references to the primary key of the
+// referenced table with its columns.
+func (c *sqliteCatalog) resolveReferencedColumns(ctx context.Context, db *sqlx.DB, fk *ForeignKey) error {
+	for _, column := range fk.ReferencedColumns {
+		if column != "" {
+			return nil
+		}
+	}
+
+	columns, err := c.columns(ctx, db, fk.ReferencedSchema, fk.ReferencedTable)
+	if err != nil {
+		return err
+	}
+	fk.ReferencedColumns = []string{}
+	for _, column := range columns {
+		if column.PrimaryKey {
+			fk.ReferencedColumns = append(fk.ReferencedColumns, column.Name)
+		}
+	}
+	return nil
+}
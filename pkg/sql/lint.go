@@ -0,0 +1,492 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"text/template/parse"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+	"github.com/go-go-golems/glazed/pkg/cmds/schema"
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// queryFunctions are the template functions running a query against the database.
+var queryFunctions = map[string]bool{
+	"sqlColumn": true,
+	"sqlSlice":  true,
+	"sqlSingle": true,
+	"sqlMap":    true,
+}
+
+// TemplateAnalysis lists what a query template and the sub-queries it uses reference.
+type TemplateAnalysis struct {
+	// Parameters are the top-level data fields used, as .name, $.name or index . "name".
+	Parameters []string
+	// SubQueries are the names passed to subQuery.
+	SubQueries []string
+	// QueryFunctions are the functions called that run a query (sqlColumn, sqlSlice, ...).
+	QueryFunctions []string
+	// UsesData is true if the whole data (.) is passed around, in which case the
+	// parameters used can't all be known.
+	UsesData bool
+
+	parameters     map[string]bool
+	subQueries     map[string]bool
+	queryFunctions map[string]bool
+	// locals are the keys passed along with the query to the query functions.
+	locals map[string]bool
+	ctx    context.Context
+}
+
+// AnalyzeTemplate parses a query template and lists the parameters, sub-queries and
+// query functions it references. The sub-queries it uses (from subQueries) and the
+// queries passed as string literals to the query functions are analyzed as well,
+// since they are rendered with the same data. The template is parsed with the functions
+// of the TemplateFuncRegistry of ctx.
+func AnalyzeTemplate(ctx context.Context, query string, subQueries map[string]string) (*TemplateAnalysis, error) {
+	a := &TemplateAnalysis{
+		ctx:            ctx,
+		parameters:     map[string]bool{},
+		subQueries:     map[string]bool{},
+		queryFunctions: map[string]bool{},
+		locals:         map[string]bool{},
+	}
+
+	if err := a.analyze("query", query); err != nil {
+		return nil, err
+	}
+
+	// follow the sub-queries used, which can use sub-queries themselves
+	analyzed := map[string]bool{}
+	for {
+		var next string
+		for name := range a.subQueries {
+			if _, ok := subQueries[name]; ok && !analyzed[name] {
+				next = name
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		analyzed[next] = true
+		if err := a.analyze(next, subQueries[next]); err != nil {
+			return nil, errors.Wrapf(err, "Could not parse subquery %s", next)
+		}
+	}
+
+	a.Parameters = sortedKeys(a.parameters)
+	a.SubQueries = sortedKeys(a.subQueries)
+	a.QueryFunctions = sortedKeys(a.queryFunctions)
+	return a, nil
+}
+
+func (a *TemplateAnalysis) analyze(name string, query string) error {
+	t, err := createTemplate(a.ctx, nil, nil, nil, nil).Parse(query)
+	if err != nil {
+		return err
+	}
+	for _, t_ := range t.Templates() {
+		if t_.Tree == nil {
+			continue
+		}
+		if err := a.walk(t_.Root, true); err != nil {
+			return errors.Wrapf(err, "template %s", name)
+		}
+	}
+	return nil
+}
+
+// walk records the references of node. isRoot is true if dot is the data of the template,
+// and not the value of a range or with.
+func (a *TemplateAnalysis) walk(node parse.Node, isRoot bool) error {
+	switch n := node.(type) {
+	case nil:
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := a.walk(child, isRoot); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return a.walk(n.Pipe, isRoot)
+	case *parse.IfNode:
+		return a.walkBranch(&n.BranchNode, isRoot, isRoot)
+	case *parse.RangeNode:
+		return a.walkBranch(&n.BranchNode, isRoot, false)
+	case *parse.WithNode:
+		return a.walkBranch(&n.BranchNode, isRoot, false)
+	case *parse.TemplateNode:
+		if n.Pipe == nil {
+			return nil
+		}
+		return a.walk(n.Pipe, isRoot)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := a.walk(cmd, isRoot); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		return a.walkCommand(n, isRoot)
+	case *parse.FieldNode:
+		if isRoot {
+			a.parameters[n.Ident[0]] = true
+		}
+	case *parse.ChainNode:
+		if _, ok := n.Node.(*parse.DotNode); ok && isRoot && len(n.Field) > 0 {
+			a.parameters[n.Field[0]] = true
+			return nil
+		}
+		return a.walk(n.Node, isRoot)
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			if len(n.Ident) > 1 {
+				a.parameters[n.Ident[1]] = true
+			} else {
+				a.UsesData = true
+			}
+		}
+	case *parse.DotNode:
+		if isRoot {
+			a.UsesData = true
+		}
+	}
+	return nil
+}
+
+func (a *TemplateAnalysis) walkBranch(n *parse.BranchNode, isRoot bool, isBodyRoot bool) error {
+	if err := a.walk(n.Pipe, isRoot); err != nil {
+		return err
+	}
+	if err := a.walk(n.List, isBodyRoot); err != nil {
+		return err
+	}
+	return a.walk(n.ElseList, isRoot)
+}
+
+func (a *TemplateAnalysis) walkCommand(n *parse.CommandNode, isRoot bool) error {
+	args := n.Args
+	if len(args) > 0 {
+		if ident, ok := args[0].(*parse.IdentifierNode); ok {
+			switch {
+			case ident.Ident == "subQuery" && len(args) > 1:
+				if s, ok := args[1].(*parse.StringNode); ok {
+					a.subQueries[s.Text] = true
+					return nil
+				}
+			case ident.Ident == "index" && len(args) > 2 && isRoot:
+				// index . "name" is used for names that aren't identifiers, e.g. with dashes
+				_, isDot := args[1].(*parse.DotNode)
+				_, isData := args[1].(*parse.VariableNode)
+				if s, ok := args[2].(*parse.StringNode); ok && (isDot || isData && args[1].String() == "$") {
+					a.parameters[s.Text] = true
+					args = args[3:]
+				}
+			case queryFunctions[ident.Ident]:
+				a.queryFunctions[ident.Ident] = true
+				if len(args) > 1 {
+					if s, ok := args[1].(*parse.StringNode); ok {
+						if err := a.analyze(ident.Ident, s.Text); err != nil {
+							return err
+						}
+					}
+				}
+				// the other arguments are key, value pairs added to the data of the query
+				for i := 2; i < len(args); i += 2 {
+					if s, ok := args[i].(*parse.StringNode); ok {
+						a.locals[s.Text] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, arg := range args {
+		if err := a.walk(arg, isRoot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	// LintSeverityInfo is used by the lint command for the commands it can't check.
+	LintSeverityInfo = "info"
+)
+
+// LintIssue is a problem found by LintQueryTemplate.
+type LintIssue struct {
+	Severity string
+	// Kind is one of parse-error, undefined-parameter, missing-subquery, unused-flag,
+	// unused-subquery, render-error and invalid-sql.
+	Kind    string
+	Name    string
+	Message string
+}
+
+// ToRow returns the issue as a glazed row.
+func (i LintIssue) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("severity", i.Severity),
+		types.MRP("kind", i.Kind),
+		types.MRP("name", i.Name),
+		types.MRP("message", i.Message),
+	)
+}
+
+type lintSettings struct {
+	dialect *Dialect
+	db      *sqlx.DB
+	data    map[string]interface{}
+}
+
+type LintOption func(*lintSettings)
+
+// WithLintDialect sets the dialect used to render and check the query without a connection.
+func WithLintDialect(dialect *Dialect) LintOption {
+	return func(s *lintSettings) {
+		s.dialect = dialect
+	}
+}
+
+// WithLintDB checks the rendered query by asking db for its plan (see ExplainQueryIntoGlaze),
+// instead of only checking its quotes and comments. Note that the query functions
+// (sqlColumn, ...) of the template are run.
+func WithLintDB(db *sqlx.DB) LintOption {
+	return func(s *lintSettings) {
+		s.db = db
+		s.dialect = DialectForDB(db)
+	}
+}
+
+// WithLintData sets the data the query is rendered with. By default, it is rendered
+// with the defaults of the flags, or sample values for the flags without default.
+func WithLintData(data map[string]interface{}) LintOption {
+	return func(s *lintSettings) {
+		s.data = data
+	}
+}
+
+// LintQueryTemplate checks the query template of the command described by description:
+//   - parameters used by the templates that aren't fields of the command (errors)
+//   - sub-queries used that aren't in subQueries (errors)
+//   - flags and arguments of the default section that aren't used, and unused sub-queries (warnings)
+//   - whether the query renders to a single statement of valid SQL for the dialect
+func LintQueryTemplate(
+	ctx context.Context,
+	description *cmds.CommandDescription,
+	query string,
+	subQueries map[string]string,
+	options ...LintOption,
+) []LintIssue {
+	settings := &lintSettings{dialect: DialectANSI}
+	for _, o := range options {
+		o(settings)
+	}
+
+	analysis, err := AnalyzeTemplate(ctx, query, subQueries)
+	if err != nil {
+		return []LintIssue{{Severity: LintSeverityError, Kind: "parse-error", Message: err.Error()}}
+	}
+
+	ret := []LintIssue{}
+
+	declared := map[string]bool{}
+	var defaultFields []*fields.Definition
+	if description.Schema != nil {
+		description.Schema.ForEach(func(slug string, section schema.Section) {
+			section.GetDefinitions().ForEach(func(definition *fields.Definition) {
+				declared[definition.Name] = true
+				if slug == schema.DefaultSlug {
+					defaultFields = append(defaultFields, definition)
+				}
+			})
+		})
+	}
+
+	for _, name := range analysis.Parameters {
+		if !declared[name] && !analysis.locals[name] {
+			ret = append(ret, LintIssue{
+				Severity: LintSeverityError,
+				Kind:     "undefined-parameter",
+				Name:     name,
+				Message:  fmt.Sprintf("parameter %s is not a flag or argument of the command", name),
+			})
+		}
+	}
+
+	for _, name := range analysis.SubQueries {
+		if _, ok := subQueries[name]; !ok {
+			ret = append(ret, LintIssue{
+				Severity: LintSeverityError,
+				Kind:     "missing-subquery",
+				Name:     name,
+				Message:  fmt.Sprintf("subquery %s is not defined", name),
+			})
+		}
+	}
+
+	if !analysis.UsesData {
+		for _, definition := range defaultFields {
+			if !analysis.parameters[definition.Name] {
+				ret = append(ret, LintIssue{
+					Severity: LintSeverityWarning,
+					Kind:     "unused-flag",
+					Name:     definition.Name,
+					Message:  fmt.Sprintf("%s is not used by the query", definition.Name),
+				})
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(stringSet(subQueries)) {
+		if !analysis.subQueries[name] {
+			ret = append(ret, LintIssue{
+				Severity: LintSeverityWarning,
+				Kind:     "unused-subquery",
+				Name:     name,
+				Message:  fmt.Sprintf("subquery %s is not used by the query", name),
+			})
+		}
+	}
+
+	data := settings.data
+	if data == nil {
+		data = map[string]interface{}{}
+		for _, definition := range defaultFields {
+			data[definition.Name] = lintSampleValue(definition)
+		}
+	}
+
+	return append(ret, settings.lintSQL(ctx, analysis, query, subQueries, data)...)
+}
+
+func stringSet(m map[string]string) map[string]bool {
+	ret := map[string]bool{}
+	for k := range m {
+		ret[k] = true
+	}
+	return ret
+}
+
+// lintSQL renders the query and checks that it is a single valid statement.
+func (s *lintSettings) lintSQL(
+	ctx context.Context,
+	analysis *TemplateAnalysis,
+	query string,
+	subQueries map[string]string,
+	data map[string]interface{},
+) []LintIssue {
+	if s.db != nil {
+		err := ExplainQueryIntoGlaze(ctx, s.db, query, subQueries, data, &discardProcessor{})
+		if err != nil {
+			return []LintIssue{{Severity: LintSeverityError, Kind: "invalid-sql", Message: err.Error()}}
+		}
+		return nil
+	}
+
+	if len(analysis.QueryFunctions) > 0 {
+		// the query functions need a connection to render the query
+		return nil
+	}
+
+	t, err := createDialectTemplate(ctx, subQueries, data, nil, nil, s.dialect).Parse(query)
+	if err != nil {
+		return []LintIssue{{Severity: LintSeverityError, Kind: "parse-error", Message: err.Error()}}
+	}
+	rendered, err := templating.RenderTemplate(t, data)
+	if err != nil {
+		return []LintIssue{{
+			Severity: LintSeverityWarning,
+			Kind:     "render-error",
+			Message:  fmt.Sprintf("could not render the query with sample values: %s", err),
+		}}
+	}
+
+	statements, err := s.dialect.SplitStatements(rendered)
+	switch {
+	case err != nil:
+		return []LintIssue{{Severity: LintSeverityError, Kind: "invalid-sql", Message: err.Error()}}
+	case len(statements) == 0:
+		return []LintIssue{{Severity: LintSeverityError, Kind: "invalid-sql", Message: "the query is empty"}}
+	case len(statements) > 1:
+		return []LintIssue{{
+			Severity: LintSeverityError,
+			Kind:     "invalid-sql",
+			Message:  fmt.Sprintf("the query has %d statements", len(statements)),
+		}}
+	}
+	return nil
+}
+
+// lintSampleValue returns the default of definition, or a value of its type.
+func lintSampleValue(definition *fields.Definition) interface{} {
+	if definition.Default != nil && *definition.Default != nil {
+		return *definition.Default
+	}
+
+	switch definition.Type {
+	case fields.TypeInteger:
+		return 1
+	case fields.TypeFloat:
+		return 1.5
+	case fields.TypeBool:
+		return true
+	case fields.TypeDate:
+		return time.Now()
+	case fields.TypeIntegerList:
+		return []interface{}{1}
+	case fields.TypeFloatList:
+		return []interface{}{1.5}
+	case fields.TypeChoice:
+		if len(definition.Choices) > 0 {
+			return definition.Choices[0]
+		}
+	case fields.TypeChoiceList:
+		if len(definition.Choices) > 0 {
+			return []interface{}{definition.Choices[0]}
+		}
+	}
+	if definition.Type.IsList() {
+		return []interface{}{"value"}
+	}
+	if definition.Type.IsObject() || definition.Type.IsKeyValue() {
+		return map[string]interface{}{}
+	}
+	return "value"
+}
+
+// discardProcessor drops the rows passed to it.
+type discardProcessor struct{}
+
+func (p *discardProcessor) AddRow(ctx context.Context, row types.Row) error {
+	return nil
+}
+
+func (p *discardProcessor) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,169 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+)
+
+func TestAnalyzeTemplate(t *testing.T) {
+	query := `
+SELECT * FROM posts
+WHERE status IN ({{ sqlStringIn .status }})
+{{ if .from }}AND date >= {{ sqlDate $.from }}{{ end }}
+{{ range .types }}AND type != {{ sqlString . }}{{ end }}
+{{ with .author }}AND author = {{ sqlString .name }} AND id = {{ $.author_id }}{{ end }}
+AND type IN ({{ sqlIn (sqlColumn (subQuery "types") "kind" "post") }})
+AND category = {{ sqlSingle "SELECT id FROM categories WHERE name = {{ sqlString .category }}" }}
+AND x = {{ index . "dashed-name" }}`
+
+	subQueries := map[string]string{
+		"types":  `SELECT type FROM types WHERE kind = {{ sqlString .kind }} {{ subQuery "nested" }}`,
+		"nested": `AND enabled = {{ .enabled }}`,
+	}
+
+	a, err := AnalyzeTemplate(context.Background(), query, subQueries)
+	if err != nil {
+		t.Fatalf("could not analyze template: %v", err)
+	}
+
+	wantParameters := []string{"author", "author_id", "category", "dashed-name", "enabled", "from", "kind", "status", "types"}
+	if !reflect.DeepEqual(a.Parameters, wantParameters) {
+		t.Errorf("got parameters %v, want %v", a.Parameters, wantParameters)
+	}
+	if want := []string{"nested", "types"}; !reflect.DeepEqual(a.SubQueries, want) {
+		t.Errorf("got subqueries %v, want %v", a.SubQueries, want)
+	}
+	if want := []string{"sqlColumn", "sqlSingle"}; !reflect.DeepEqual(a.QueryFunctions, want) {
+		t.Errorf("got query functions %v, want %v", a.QueryFunctions, want)
+	}
+	if !a.locals["kind"] {
+		t.Errorf("expected kind to be passed to the query")
+	}
+	if a.UsesData {
+		t.Errorf("expected the data not to be passed around")
+	}
+
+	a, err = AnalyzeTemplate(context.Background(), `SELECT {{ template "columns" . }} FROM t`, nil)
+	if err != nil {
+		t.Fatalf("could not analyze template: %v", err)
+	}
+	if !a.UsesData {
+		t.Errorf("expected passing . to be detected")
+	}
+
+	if _, err := AnalyzeTemplate(context.Background(), `SELECT {{ .x `, nil); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
+
+func newLintTestDescription() *cmds.CommandDescription {
+	return cmds.NewCommandDescription("posts",
+		cmds.WithFlags(
+			fields.New("status", fields.TypeStringList, fields.WithDefault([]string{"publish"})),
+			fields.New("limit", fields.TypeInteger),
+			fields.New("verbose", fields.TypeBool),
+		),
+	)
+}
+
+func lintIssueKinds(issues []LintIssue) map[string]string {
+	ret := map[string]string{}
+	for _, issue := range issues {
+		ret[issue.Kind+":"+issue.Name] = issue.Severity
+	}
+	return ret
+}
+
+func TestLintQueryTemplate(t *testing.T) {
+	ctx := context.Background()
+	description := newLintTestDescription()
+
+	issues := LintQueryTemplate(ctx, description, `
+SELECT * FROM posts
+WHERE status IN ({{ sqlStringIn .status }}) AND author = {{ sqlString .author }}
+{{ subQuery "missing" }}
+LIMIT {{ .limit }}`, map[string]string{"unused": "SELECT 1"})
+
+	want := map[string]string{
+		"undefined-parameter:author": LintSeverityError,
+		"missing-subquery:missing":   LintSeverityError,
+		"unused-flag:verbose":        LintSeverityWarning,
+		"unused-subquery:unused":     LintSeverityWarning,
+		// subQuery "missing" fails to render
+		"render-error:": LintSeverityWarning,
+	}
+	if got := lintIssueKinds(issues); !reflect.DeepEqual(got, want) {
+		t.Errorf("got issues %v, want %v", issues, want)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		dialect *Dialect
+		want    map[string]string
+	}{
+		{
+			name:  "valid",
+			query: `SELECT * FROM posts WHERE status IN ({{ sqlStringIn .status }}) {{ if .verbose }}LIMIT {{ .limit }}{{ end }};`,
+			want:  map[string]string{},
+		},
+		{
+			name:  "unterminated string",
+			query: `SELECT 'a FROM posts WHERE status IN ({{ sqlStringIn .status }}) LIMIT {{ .limit }} -- {{ .verbose }}`,
+			want:  map[string]string{"invalid-sql:": LintSeverityError},
+		},
+		{
+			name:  "several statements",
+			query: `DELETE FROM posts; SELECT {{ sqlStringIn .status }}, {{ .limit }}, {{ .verbose }}`,
+			want:  map[string]string{"invalid-sql:": LintSeverityError},
+		},
+		{
+			name:    "backslash escapes",
+			query:   `SELECT 'a\' FROM posts WHERE status IN ({{ sqlStringIn .status }}) LIMIT {{ .limit }} -- {{ .verbose }}`,
+			dialect: DialectMySQL,
+			want:    map[string]string{"invalid-sql:": LintSeverityError},
+		},
+		{
+			name:  "parse error",
+			query: `SELECT {{ .limit `,
+			want:  map[string]string{"parse-error:": LintSeverityError},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options []LintOption
+			if tt.dialect != nil {
+				options = append(options, WithLintDialect(tt.dialect))
+			}
+			issues := LintQueryTemplate(ctx, description, tt.query, nil, options...)
+			if got := lintIssueKinds(issues); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got issues %v, want %v", issues, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintQueryTemplateWithDB(t *testing.T) {
+	ctx := context.Background()
+	db := openCacheTestDB(t)
+	description := cmds.NewCommandDescription("items",
+		cmds.WithFlags(fields.New("name", fields.TypeString)),
+	)
+
+	issues := LintQueryTemplate(ctx, description,
+		`SELECT * FROM items WHERE name = {{ sqlString .name }} AND id IN ({{ sqlIn (sqlColumn "SELECT id FROM items") }})`,
+		nil, WithLintDB(db))
+	if len(issues) != 0 {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+
+	issues = LintQueryTemplate(ctx, description,
+		`SELECT * FROM missing WHERE name = {{ sqlString .name }}`, nil, WithLintDB(db))
+	if got := lintIssueKinds(issues); !reflect.DeepEqual(got, map[string]string{"invalid-sql:": LintSeverityError}) {
+		t.Errorf("expected the missing table to be reported, got %v", issues)
+	}
+}
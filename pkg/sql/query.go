@@ -2,6 +2,8 @@ package sql
 
 import (
 	"context"
+	"time"
+
 	"github.com/go-go-golems/glazed/pkg/helpers/templating"
 	"github.com/go-go-golems/glazed/pkg/middlewares"
 	"github.com/go-go-golems/glazed/pkg/types"
@@ -14,20 +16,34 @@ func RunQueryIntoGlaze(
 	db *sqlx.DB,
 	query string,
 	parameters []interface{},
-	gp middlewares.Processor) error {
+	gp middlewares.Processor,
+	options ...QueryResultOption,
+) error {
+	settings := newQueryResultSettings(options...).withConnectionTimeout(db).withContextCache(dbContext)
+	ctx, cancel := settings.withTimeout(dbContext)
+	defer cancel()
+	start := time.Now()
 
-	// use a prepared statement so that when using mysql, we get native types back
-	stmt, err := db.PreparexContext(dbContext, query)
-	if err != nil {
-		return errors.Wrapf(err, "Could not prepare query: %s", query)
-	}
+	key := settings.cacheKey(db, query, parameters)
+	err := runCachedIntoGlaze(ctx, settings, key, gp, func(gp middlewares.Processor) error {
+		// use a prepared statement so that when using mysql, we get native types back
+		stmt, err := db.PreparexContext(ctx, query)
+		if err != nil {
+			return errors.Wrapf(newDriverError(settings.name, "", "", query, err), "Could not prepare query: %s", query)
+		}
+		defer func() {
+			_ = stmt.Close()
+		}()
 
-	rows, err := stmt.QueryxContext(dbContext, parameters...)
-	if err != nil {
-		return errors.Wrapf(err, "Could not execute query: %s", query)
-	}
+		rows, err := stmt.QueryxContext(ctx, parameters...)
+		if err != nil {
+			return errors.Wrapf(newDriverError(settings.name, "", "", query, err), "Could not execute query: %s", query)
+		}
+
+		return processQueryResults(ctx, rows, gp, settings)
+	})
 
-	return processQueryResults(dbContext, rows, gp)
+	return settings.wrapTimeout(ctx, err, start)
 }
 
 func RunNamedQueryIntoGlaze(
@@ -35,29 +51,75 @@ func RunNamedQueryIntoGlaze(
 	db *sqlx.DB,
 	query string,
 	parameters map[string]interface{},
-	gp middlewares.Processor) error {
+	gp middlewares.Processor,
+	options ...QueryResultOption,
+) error {
+	settings := newQueryResultSettings(options...).withConnectionTimeout(db).withContextCache(dbContext)
+	ctx, cancel := settings.withTimeout(dbContext)
+	defer cancel()
+	start := time.Now()
 
-	// use a statement so that when using mysql, we get native types back
-	stmt, err := db.PrepareNamedContext(dbContext, query)
-	if err != nil {
-		return errors.Wrapf(err, "Could not prepare query: %s", query)
-	}
+	key := settings.cacheKey(db, query, []interface{}{parameters})
+	err := runCachedIntoGlaze(ctx, settings, key, gp, func(gp middlewares.Processor) error {
+		// use a statement so that when using mysql, we get native types back
+		stmt, err := db.PrepareNamedContext(ctx, query)
+		if err != nil {
+			return errors.Wrapf(newDriverError(settings.name, "", "", query, err), "Could not prepare query: %s", query)
+		}
+		defer func() {
+			_ = stmt.Close()
+		}()
 
-	rows, err := stmt.QueryxContext(dbContext, parameters)
-	if err != nil {
-		return errors.Wrapf(err, "Could not execute query: %s", query)
-	}
+		rows, err := stmt.QueryxContext(ctx, parameters)
+		if err != nil {
+			return errors.Wrapf(newDriverError(settings.name, "", "", query, err), "Could not execute query: %s", query)
+		}
+
+		return processQueryResults(ctx, rows, gp, settings)
+	})
 
-	return processQueryResults(dbContext, rows, gp)
+	return settings.wrapTimeout(ctx, err, start)
 }
 
-func processQueryResults(ctx context.Context, rows *sqlx.Rows, gp middlewares.Processor) error {
+func processQueryResults(
+	ctx context.Context,
+	rows *sqlx.Rows,
+	gp middlewares.Processor,
+	settings *queryResultSettings,
+) error {
+	defer func(rows *sqlx.Rows) {
+		_ = rows.Close()
+	}(rows)
+
 	// we need a way to order the columns
 	cols, err := rows.Columns()
 	if err != nil {
 		return errors.Wrapf(err, "Could not get columns")
 	}
 
+	var columnTypes map[string]*ColumnType
+	receiver, isReceiver := gp.(ColumnTypesReceiver)
+	if settings.normalize || isReceiver || len(settings.columnTypesFuncs) > 0 {
+		types_, err := GetColumnTypes(rows)
+		if err != nil {
+			return err
+		}
+		columnTypes = map[string]*ColumnType{}
+		for _, ct := range types_ {
+			columnTypes[ct.Name] = ct
+		}
+		for _, f := range settings.columnTypesFuncs {
+			if err := f(ctx, types_); err != nil {
+				return err
+			}
+		}
+		if isReceiver {
+			if err := receiver.SetColumnTypes(ctx, types_); err != nil {
+				return err
+			}
+		}
+	}
+
 	for rows.Next() {
 		m := map[string]interface{}{}
 		row := types.NewRow()
@@ -68,6 +130,10 @@ func processQueryResults(ctx context.Context, rows *sqlx.Rows, gp middlewares.Pr
 
 		for _, col := range cols {
 			if v, ok := m[col]; ok {
+				if ct, ok := columnTypes[col]; ok && settings.normalize {
+					row.Set(col, settings.normalizeValue(ct, v))
+					continue
+				}
 				switch v := v.(type) {
 				case []byte:
 					row.Set(col, string(v))
@@ -83,6 +149,10 @@ func processQueryResults(ctx context.Context, rows *sqlx.Rows, gp middlewares.Pr
 		}
 	}
 
+	if err := rows.Err(); err != nil {
+		return errors.Wrapf(err, "Could not read rows")
+	}
+
 	return nil
 }
 
@@ -93,32 +163,145 @@ func RunQuery(
 	ps2 map[string]interface{},
 	db *sqlx.DB,
 ) (string, *sqlx.Rows, error) {
+	query_, args, err := renderBoundQuery(ctx, subQueries, query, ps2, db)
+	if err != nil {
+		return query_, nil, err
+	}
+
+	rows, err := queryRows(ctx, db, query_, args)
+	return query_, rows, err
+}
+
+// renderBoundQuery renders the query template, binding the values of the value functions.
+// Errors are returned as QueryError.
+func renderBoundQuery(
+	ctx context.Context,
+	subQueries map[string]string,
+	query string,
+	ps2 map[string]interface{},
+	db *sqlx.DB,
+) (string, []interface{}, error) {
+	return renderBoundTemplate(ctx, "query", subQueries, query, ps2, db)
+}
+
+// renderBoundTemplate renders the query template of name, see renderBoundQuery.
+func renderBoundTemplate(
+	ctx context.Context,
+	name string,
+	subQueries map[string]string,
+	query string,
+	ps2 map[string]interface{},
+	db *sqlx.DB,
+) (string, []interface{}, error) {
 	if db == nil {
 		return "", nil, errors.New("No database connection")
 	}
 
-	t2 := CreateTemplate(ctx, subQueries, ps2, db)
+	args := NewQueryArgs(BindTypeForDB(db))
+	t2 := CreateBoundTemplate(ctx, subQueries, ps2, db, args)
 	t, err := t2.Parse(query)
 	if err != nil {
-		return "", nil, err
+		return "", nil, newTemplateError(name, query, err)
 	}
 
 	query_, err := templating.RenderTemplate(t, ps2)
 	if err != nil {
-		return query_, nil, err
+		return query_, nil, newTemplateError(name, query, err)
 	}
+	return query_, args.Args(), nil
+}
 
-	stmt, err := db.PreparexContext(ctx, query_)
+func queryRows(ctx context.Context, db *sqlx.DB, query string, args []interface{}) (*sqlx.Rows, error) {
+	stmt, err := db.PreparexContext(ctx, query)
 	if err != nil {
-		return query_, nil, err
+		return nil, err
+	}
+
+	return stmt.QueryxContext(ctx, args...)
+}
+
+// runSubQuery runs a sub-query of a template and returns the result of scan and the
+// rendered query. If ctx carries a query cache (see ContextWithQueryCache), the result is
+// cached, keyed by kind, rendered query and arguments. Errors are returned as QueryError.
+func runSubQuery(
+	ctx context.Context,
+	kind string,
+	subQueries map[string]string,
+	query string,
+	data map[string]interface{},
+	db *sqlx.DB,
+	scan func(renderedQuery string, rows *sqlx.Rows) (interface{}, error),
+) (interface{}, string, error) {
+	name := subQueryName(subQueries, query)
+	renderedQuery, args, err := renderBoundTemplate(ctx, name, subQueries, query, data, db)
+	if err != nil {
+		var qe *QueryError
+		if errors.As(err, &qe) {
+			qe.Function = kind
+		}
+		return nil, renderedQuery, err
+	}
+
+	cache, ttl := queryCacheFromContext(ctx)
+	key := QueryCacheKey{Connection: connectionIdentity(db), Kind: kind, Query: renderedQuery, Args: args}
+	if cache != nil {
+		if v, ok := cache.get(key); ok {
+			return v, renderedQuery, nil
+		}
 	}
 
-	rows, err := stmt.QueryxContext(ctx)
+	ret, err := scanSubQuery(ctx, name, db, renderedQuery, args, scan)
 	if err != nil {
-		return query_, nil, err
+		return nil, renderedQuery, newDriverError(name, kind, query, renderedQuery, err)
 	}
 
-	return query_, rows, err
+	if cache != nil {
+		cache.set(key, ret, ttl)
+	}
+	return ret, renderedQuery, nil
+}
+
+// scanSubQuery runs the rendered sub-query within the query timeout of db.
+func scanSubQuery(
+	ctx context.Context,
+	name string,
+	db *sqlx.DB,
+	renderedQuery string,
+	args []interface{},
+	scan func(renderedQuery string, rows *sqlx.Rows) (interface{}, error),
+) (interface{}, error) {
+	settings := (&queryResultSettings{name: name}).withConnectionTimeout(db)
+	ctx, cancel := settings.withTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+
+	rows, err := queryRows(ctx, db, renderedQuery, args)
+	if err != nil {
+		return nil, settings.wrapTimeout(ctx, err, start)
+	}
+	defer func(rows *sqlx.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	ret, err := scan(renderedQuery, rows)
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return nil, settings.wrapTimeout(ctx, err, start)
+	}
+	return ret, nil
+}
+
+// subQueryName returns the name of the sub-query with the text query, passed to a query
+// function through subQuery, or "" for a query passed inline.
+func subQueryName(subQueries map[string]string, query string) string {
+	for name, subQuery := range subQueries {
+		if subQuery == query {
+			return name
+		}
+	}
+	return ""
 }
 
 func mergeQueryData(data map[string]interface{}, args []interface{}) (map[string]interface{}, error) {
@@ -141,31 +324,88 @@ func mergeQueryData(data map[string]interface{}, args []interface{}) (map[string
 	return ps2, nil
 }
 
-// TODO(manuel, 2023-11-19) Actually return bound arguments,
-// probably by adding a funcmap function that one can pipe variable into (but how do we get the name?
-// Maybe this is possible in jinja? Other option is to replace the parameters we push into the template and wrap them)
-
 // TODO(manuel, 2023-11-19) Document this section of clay
 
+type renderQuerySettings struct {
+	bindType     int
+	hasBindType  bool
+	interpolated bool
+}
+
+type RenderQueryOption func(*renderQuerySettings)
+
+// WithBindType overrides the placeholder style (sqlx.QUESTION, sqlx.DOLLAR, sqlx.NAMED, sqlx.AT)
+// derived from the driver of the database.
+func WithBindType(bindType int) RenderQueryOption {
+	return func(s *renderQuerySettings) {
+		s.bindType = bindType
+		s.hasBindType = true
+	}
+}
+
+// WithInterpolatedValues makes the value functions splice their values into the query text,
+// for example to print a query. RenderQuery then returns no arguments.
+func WithInterpolatedValues() RenderQueryOption {
+	return func(s *renderQuerySettings) {
+		s.interpolated = true
+	}
+}
+
+// RenderQuery renders the query template. The values passed through the value functions
+// (sqlString, sqlIn, sqlDate, sqlArg, ...) are replaced by placeholders in the style of the
+// database driver and returned as args, to be passed along with the query, for example to
+// RunQueryIntoGlaze.
 func RenderQuery(
 	ctx context.Context,
 	db *sqlx.DB,
 	query string,
 	subQueries map[string]string,
 	data map[string]interface{},
-) (string, error) {
-	t2 := CreateTemplate(ctx, subQueries, data, db)
+	options ...RenderQueryOption,
+) (string, []interface{}, error) {
+	settings := &renderQuerySettings{}
+	for _, opt := range options {
+		opt(settings)
+	}
+
+	var args *QueryArgs
+	if !settings.interpolated {
+		bindType := BindTypeForDB(db)
+		if settings.hasBindType {
+			bindType = settings.bindType
+		}
+		args = NewQueryArgs(bindType)
+	}
+
+	t2 := createTemplate(ctx, subQueries, data, db, args)
 
 	t, err := t2.Parse(query)
 	if err != nil {
-		return "", errors.Wrap(err, "Could not parse query template")
+		return "", nil, errors.Wrap(newTemplateError("query", query, err), "Could not parse query template")
 	}
 
 	ret, err := templating.RenderTemplate(t, data)
 	if err != nil {
-		return "", errors.Wrap(err, "Could not render query template")
+		return "", nil, errors.Wrap(newTemplateError("query", query, err), "Could not render query template")
 	}
 
 	ret = CleanQuery(ret)
-	return ret, nil
+	if args == nil {
+		return ret, nil, nil
+	}
+	return ret, args.Args(), nil
+}
+
+// RenderInterpolatedQuery renders the query template with the values of the value functions
+// spliced into the query text, as RenderQuery did before it returned bound arguments. It
+// is kept for existing callers; prefer RenderQuery, whose values can't alter the SQL.
+func RenderInterpolatedQuery(
+	ctx context.Context,
+	db *sqlx.DB,
+	query string,
+	subQueries map[string]string,
+	data map[string]interface{},
+) (string, error) {
+	ret, _, err := RenderQuery(ctx, db, query, subQueries, data, WithInterpolatedValues())
+	return ret, err
 }
@@ -0,0 +1,189 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// ConnectRetrySettings control how Connect waits for a database that isn't up yet, for
+// example in docker-compose or CI setups.
+type ConnectRetrySettings struct {
+	// Attempts is the maximum number of connection attempts. 0 retries until MaxWait.
+	Attempts int
+	// InitialBackoff is the wait after the first failed attempt, doubled after each
+	// attempt up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxWait bounds the total time spent connecting, 0 for no limit.
+	MaxWait time.Duration
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+}
+
+const (
+	defaultConnectBackoff    = 500 * time.Millisecond
+	defaultConnectMaxBackoff = 10 * time.Second
+	defaultConnectTimeout    = 5 * time.Second
+)
+
+// GetConnectRetrySettings parses the connect flags of the config. Without
+// connect-attempts and connect-wait, Connect makes a single attempt.
+func (c *DatabaseConfig) GetConnectRetrySettings() (*ConnectRetrySettings, error) {
+	if c.ConnectAttempts < 0 {
+		return nil, errors.Errorf("connect-attempts must not be negative, got %d", c.ConnectAttempts)
+	}
+	ret := &ConnectRetrySettings{
+		Attempts:       c.ConnectAttempts,
+		InitialBackoff: defaultConnectBackoff,
+		MaxBackoff:     defaultConnectMaxBackoff,
+		Timeout:        defaultConnectTimeout,
+	}
+
+	for _, s := range []struct {
+		name  string
+		value string
+		d     *time.Duration
+	}{
+		{"connect-backoff", c.ConnectBackoff, &ret.InitialBackoff},
+		{"connect-max-backoff", c.ConnectMaxBackoff, &ret.MaxBackoff},
+		{"connect-wait", c.ConnectWait, &ret.MaxWait},
+		{"connect-timeout", c.ConnectTimeout, &ret.Timeout},
+	} {
+		d, err := parseDurationSetting(s.name, s.value)
+		if err != nil {
+			return nil, err
+		}
+		if d > 0 {
+			*s.d = d
+		}
+	}
+
+	if ret.Attempts == 0 && ret.MaxWait == 0 {
+		ret.Attempts = 1
+	}
+	if ret.MaxBackoff < ret.InitialBackoff {
+		ret.MaxBackoff = ret.InitialBackoff
+	}
+	return ret, nil
+}
+
+// ConnectError is returned by Connect when the database can't be reached.
+type ConnectError struct {
+	Attempts int
+	Elapsed  time.Duration
+	// Retryable is false if the last attempt failed with an error that retrying doesn't
+	// fix, for example a wrong password or an unknown database.
+	Retryable bool
+	Err       error
+}
+
+func (e *ConnectError) Error() string {
+	if e.Attempts == 1 {
+		return "failed to ping database: " + e.Err.Error()
+	}
+	return fmt.Sprintf("failed to ping database after %d attempts (%s): %s",
+		e.Attempts, e.Elapsed.Round(time.Millisecond), e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// ping pings db until it answers, retrying transient errors with exponential backoff.
+func (r *ConnectRetrySettings) ping(ctx context.Context, db *sqlx.DB) error {
+	start := time.Now()
+	backoff := r.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, r.Timeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				log.Info().Int("attempt", attempt).Dur("elapsed", time.Since(start)).Msg("Connected to database")
+			}
+			return nil
+		}
+
+		retryable := ctx.Err() == nil && IsTransientConnectError(err)
+		elapsed := time.Since(start)
+		last := !retryable ||
+			(r.Attempts > 0 && attempt >= r.Attempts) ||
+			(r.MaxWait > 0 && elapsed+backoff > r.MaxWait)
+		if last {
+			if attempt > 1 || !retryable {
+				log.Warn().Err(err).Int("attempt", attempt).Bool("retryable", retryable).
+					Msg("Could not connect to database, giving up")
+			}
+			return &ConnectError{Attempts: attempt, Elapsed: elapsed, Retryable: retryable, Err: err}
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Dur("backoff", backoff).
+			Msg("Could not connect to database, retrying")
+		select {
+		case <-ctx.Done():
+			return &ConnectError{Attempts: attempt, Elapsed: time.Since(start), Err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > r.MaxBackoff {
+			backoff = r.MaxBackoff
+		}
+	}
+}
+
+// IsTransientConnectError returns true if err is a connection error that can go away by
+// retrying: the server is unreachable, starting up, shutting down or has too many
+// connections. Authentication errors and unknown databases are not transient.
+func IsTransientConnectError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P03", // cannot_connect_now, the server is starting up
+			"57P01", // admin_shutdown
+			"53300": // too_many_connections
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08") // connection exceptions
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, // too many connections
+			1053, // server shutdown in progress
+			1203: // user has too many connections
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &opErr), errors.As(err, &netErr):
+		return true
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, mysql.ErrInvalidConn):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package sql
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+// fakePostgres is a server speaking just enough of the PostgreSQL protocol to accept
+// connections and answer pings, or to reject every login with an authentication error.
+type fakePostgres struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	closed   bool
+}
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+// startFakePostgres starts listening on addr after delay.
+func startFakePostgres(t *testing.T, addr string, delay time.Duration, rejectLogin bool) {
+	s := &fakePostgres{}
+	t.Cleanup(func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.closed = true
+		if s.listener != nil {
+			_ = s.listener.Close()
+		}
+	})
+
+	go func() {
+		time.Sleep(delay)
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			return
+		}
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			s.mutex.Unlock()
+			t.Errorf("could not listen on %s: %v", addr, err)
+			return
+		}
+		s.listener = l
+		s.mutex.Unlock()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakePostgres(conn, rejectLogin)
+		}
+	}()
+}
+
+func writeMessage(w io.Writer, kind byte, body []byte) {
+	msg := make([]byte, 5, 5+len(body))
+	msg[0] = kind
+	binary.BigEndian.PutUint32(msg[1:], uint32(4+len(body)))
+	_, _ = w.Write(append(msg, body...))
+}
+
+func serveFakePostgres(conn net.Conn, rejectLogin bool) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header)-8)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		// decline SSL and GSS encryption requests, the startup message follows
+		if code := binary.BigEndian.Uint32(header[4:]); code == 80877103 || code == 80877104 {
+			_, _ = conn.Write([]byte{'N'})
+			continue
+		}
+		break
+	}
+
+	if rejectLogin {
+		writeMessage(conn, 'E', []byte("SFATAL\x00VFATAL\x00C28P01\x00Mpassword authentication failed for user \"app\"\x00\x00"))
+		return
+	}
+	writeMessage(conn, 'R', []byte{0, 0, 0, 0})
+	writeMessage(conn, 'Z', []byte{'I'})
+
+	for {
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		switch header[0] {
+		case 'Q':
+			writeMessage(conn, 'I', nil)
+			writeMessage(conn, 'Z', []byte{'I'})
+		case 'X':
+			return
+		}
+	}
+}
+
+func newRetryTestConfig(addr string) *DatabaseConfig {
+	return &DatabaseConfig{
+		DSN:               fmt.Sprintf("postgres://app:secret@%s/app?sslmode=disable", addr),
+		ConnectBackoff:    "20ms",
+		ConnectMaxBackoff: "50ms",
+		ConnectTimeout:    "1s",
+	}
+}
+
+func TestConnectRetryWaitsForDatabase(t *testing.T) {
+	addr := freeAddress(t)
+	startFakePostgres(t, addr, 300*time.Millisecond, false)
+
+	config := newRetryTestConfig(addr)
+	config.ConnectAttempts = 50
+	start := time.Now()
+	db, err := config.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	_ = db.Close()
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("expected to wait for the database, connected after %s", elapsed)
+	}
+}
+
+func TestConnectRetryStopsOnAuthError(t *testing.T) {
+	addr := freeAddress(t)
+	startFakePostgres(t, addr, 200*time.Millisecond, true)
+
+	config := newRetryTestConfig(addr)
+	config.ConnectAttempts = 50
+	_, err := config.Connect(context.Background())
+
+	var connectErr *ConnectError
+	if !errors.As(err, &connectErr) {
+		t.Fatalf("expected a ConnectError, got %v", err)
+	}
+	if connectErr.Retryable || connectErr.Attempts < 2 || connectErr.Attempts >= 50 {
+		t.Errorf("expected to retry until the server rejected the login, got %+v", connectErr)
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Errorf("expected the authentication error, got %v", err)
+	}
+}
+
+func TestConnectRetryLimits(t *testing.T) {
+	addr := freeAddress(t)
+
+	t.Run("single attempt", func(t *testing.T) {
+		_, err := newRetryTestConfig(addr).Connect(context.Background())
+		var connectErr *ConnectError
+		if !errors.As(err, &connectErr) {
+			t.Fatalf("expected a ConnectError, got %v", err)
+		}
+		if connectErr.Attempts != 1 || !connectErr.Retryable {
+			t.Errorf("expected a single retryable attempt, got %+v", connectErr)
+		}
+	})
+
+	t.Run("connect-wait", func(t *testing.T) {
+		config := newRetryTestConfig(addr)
+		config.ConnectWait = "200ms"
+		start := time.Now()
+		_, err := config.Connect(context.Background())
+		var connectErr *ConnectError
+		if !errors.As(err, &connectErr) {
+			t.Fatalf("expected a ConnectError, got %v", err)
+		}
+		if connectErr.Attempts < 2 {
+			t.Errorf("expected several attempts, got %+v", connectErr)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected to give up after connect-wait, took %s", elapsed)
+		}
+	})
+}
+
+func TestGetConnectRetrySettings(t *testing.T) {
+	settings, err := (&DatabaseConfig{}).GetConnectRetrySettings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &ConnectRetrySettings{
+		Attempts:       1,
+		InitialBackoff: defaultConnectBackoff,
+		MaxBackoff:     defaultConnectMaxBackoff,
+		Timeout:        defaultConnectTimeout,
+	}
+	if *settings != *want {
+		t.Errorf("got %+v, want %+v", settings, want)
+	}
+
+	settings, err = (&DatabaseConfig{ConnectWait: "1m", ConnectBackoff: "30s"}).GetConnectRetrySettings()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if settings.Attempts != 0 || settings.MaxWait != time.Minute || settings.MaxBackoff != 30*time.Second {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+
+	for _, config := range []*DatabaseConfig{
+		{ConnectAttempts: -1},
+		{ConnectBackoff: "soon"},
+		{ConnectWait: "-1s"},
+	} {
+		if _, err := config.GetConnectRetrySettings(); err == nil {
+			t.Errorf("expected an error for %+v", config)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package sql
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/cmds/schema"
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// SplitStatements splits a script into its statements, separated by semicolons.
+// Semicolons inside string literals, quoted identifiers, comments, (for Postgres and
+// DuckDB) dollar-quoted strings and the BEGIN ... END bodies of CREATE TRIGGER, PROCEDURE
+// and FUNCTION statements (or BEGIN ATOMIC and BEGIN NOT ATOMIC blocks) don't end a
+// statement. The statements are returned without the trailing semicolon; statements made
+// only of comments are dropped.
+func (d *Dialect) SplitStatements(script string) ([]string, error) {
+	ret := []string{}
+	start := 0
+	hasCode := false
+	blocks := &blockTracker{}
+
+	addStatement := func(end int) {
+		if hasCode {
+			ret = append(ret, strings.TrimSpace(script[start:end]))
+		}
+		hasCode = false
+		blocks.reset()
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+		var next byte
+		if i+1 < len(script) {
+			next = script[i+1]
+		}
+
+		var end int
+		var err error
+		switch {
+		case c == '\'':
+			end, err = skipQuoted(script, i, '\'', d.BackslashEscapes || d.isEscapeString(script, i))
+			hasCode = true
+		case c == '"':
+			end, err = skipQuoted(script, i, '"', d.BackslashEscapes)
+			hasCode = true
+		case c == '`' && d.IdentifierQuote == "`":
+			end, err = skipQuoted(script, i, '`', false)
+			hasCode = true
+		case c == '-' && next == '-', c == '#' && d.HashComments:
+			end = strings.IndexByte(script[i:], '\n')
+			if end == -1 {
+				end = len(script)
+			} else {
+				end += i
+			}
+		case c == '/' && next == '*':
+			end, err = d.skipBlockComment(script, i)
+		case c == '$' && d.DollarQuotes:
+			end, err = skipDollarQuoted(script, i)
+			hasCode = true
+		case c == ';' && blocks.depth == 0:
+			addStatement(i)
+			start = i + 1
+			end = i + 1
+		case isIdentifierChar(c):
+			end = wordEnd(script, i)
+			// qualified names such as t.end are not keywords
+			if i == 0 || script[i-1] != '.' {
+				end = blocks.word(script, i, end, !hasCode)
+			}
+			hasCode = true
+		default:
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				hasCode = true
+			}
+			end = i + 1
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", 1+strings.Count(script[:i], "\n"))
+		}
+		i = end
+	}
+	addStatement(len(script))
+
+	return ret, nil
+}
+
+// blockTracker tracks the BEGIN ... END blocks of a statement, in which semicolons don't
+// end the statement. A BEGIN opens a block when it starts the body of a CREATE TRIGGER,
+// PROCEDURE or FUNCTION statement, when it is BEGIN ATOMIC or BEGIN NOT ATOMIC, or inside
+// another block. Other BEGINs start a transaction (BEGIN; BEGIN TRANSACTION) or are
+// identifiers. Inside a block, CASE ... END is counted as well, since its END would
+// otherwise close the block.
+type blockTracker struct {
+	depth int
+	// create is set if the statement starts with CREATE, routine if it then creates a
+	// trigger, procedure or function
+	create  bool
+	routine bool
+}
+
+func (b *blockTracker) reset() {
+	*b = blockTracker{}
+}
+
+// word updates the blocks with the word of script between start and end, and returns the
+// index after the words it consumed.
+func (b *blockTracker) word(script string, start int, end int, startsStatement bool) int {
+	next, nextEnd := nextWord(script, end)
+	switch strings.ToUpper(script[start:end]) {
+	case "CREATE":
+		if startsStatement && b.depth == 0 {
+			b.create = true
+		}
+	case "TRIGGER", "PROCEDURE", "FUNCTION":
+		if b.create {
+			b.routine = true
+		}
+	case "BEGIN":
+		switch strings.ToUpper(next) {
+		case "ATOMIC", "NOT":
+			b.depth++
+			return end
+		}
+		if b.depth > 0 || b.routine {
+			b.depth++
+		}
+	case "CASE":
+		if b.depth > 0 {
+			b.depth++
+		}
+	case "END":
+		switch strings.ToUpper(next) {
+		case "IF", "LOOP", "WHILE", "REPEAT", "FOR":
+			// MySQL control flow statements, whose start isn't counted
+			return nextEnd
+		case "CASE":
+			// END CASE closes a counted CASE statement, the CASE doesn't open another
+			if b.depth > 0 {
+				b.depth--
+			}
+			return nextEnd
+		}
+		if b.depth > 0 {
+			b.depth--
+		}
+	}
+	return end
+}
+
+// wordEnd returns the index after the word starting at start.
+func wordEnd(script string, start int) int {
+	end := start
+	for end < len(script) && isIdentifierChar(script[end]) {
+		end++
+	}
+	return end
+}
+
+// nextWord returns the word following the whitespace at i, and the index after it.
+func nextWord(script string, i int) (string, int) {
+	for i < len(script) && strings.IndexByte(" \t\n\r", script[i]) >= 0 {
+		i++
+	}
+	end := wordEnd(script, i)
+	return script[i:end], end
+}
+
+// isEscapeString returns true if the quote at i starts a Postgres E'...' string.
+func (d *Dialect) isEscapeString(script string, i int) bool {
+	if !d.DollarQuotes || i == 0 || (script[i-1] != 'E' && script[i-1] != 'e') {
+		return false
+	}
+	return i == 1 || !isIdentifierChar(script[i-2])
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// skipQuoted returns the index after the literal starting with quote at start.
+// Doubled quotes and, if backslashEscapes is set, backslash escapes don't end it.
+func skipQuoted(script string, start int, quote byte, backslashEscapes bool) (int, error) {
+	for i := start + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, errors.Errorf("unterminated %c quote", quote)
+}
+
+func (d *Dialect) skipBlockComment(script string, start int) (int, error) {
+	depth := 0
+	for i := start; i+1 < len(script); i++ {
+		switch {
+		case script[i] == '/' && script[i+1] == '*':
+			if depth == 0 || d.NestedComments {
+				depth++
+			}
+			i++
+		case script[i] == '*' && script[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, errors.New("unterminated /* comment")
+}
+
+// skipDollarQuoted returns the index after the dollar-quoted string starting at start,
+// or start+1 if the $ doesn't start one (e.g. a $1 placeholder).
+func skipDollarQuoted(script string, start int) (int, error) {
+	i := start + 1
+	for i < len(script) && isIdentifierChar(script[i]) {
+		if i == start+1 && script[i] >= '0' && script[i] <= '9' {
+			return start + 1, nil
+		}
+		i++
+	}
+	if i >= len(script) || script[i] != '$' {
+		return start + 1, nil
+	}
+
+	tag := script[start : i+1]
+	end := strings.Index(script[i+1:], tag)
+	if end == -1 {
+		return 0, errors.Errorf("unterminated %s quote", tag)
+	}
+	return i + 1 + end + len(tag), nil
+}
+
+// RenderScript renders a script template and splits it into statements. The values of the
+// value functions (sqlString, sqlIn, ...) are interpolated into the statements, escaped for
+// the dialect of db, since the statements are run separately.
+func RenderScript(
+	ctx context.Context,
+	db *sqlx.DB,
+	script string,
+	subQueries map[string]string,
+	data map[string]interface{},
+) ([]string, error) {
+	t, err := createTemplate(ctx, subQueries, data, db, nil).Parse(script)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse script template")
+	}
+
+	rendered, err := templating.RenderTemplate(t, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not render script template")
+	}
+
+	return DialectForDB(db).SplitStatements(rendered)
+}
+
+type scriptSettings struct {
+	dryRun        bool
+	noTransaction bool
+}
+
+type ScriptOption func(*scriptSettings)
+
+// WithDryRun makes RunScriptIntoGlaze only output the statements, without running them.
+func WithDryRun(dryRun bool) ScriptOption {
+	return func(s *scriptSettings) {
+		s.dryRun = dryRun
+	}
+}
+
+// WithoutTransaction runs the statements one by one, for statements that can't run inside
+// a transaction (e.g. VACUUM, CREATE INDEX CONCURRENTLY). A failure doesn't undo the
+// statements that already ran.
+func WithoutTransaction() ScriptOption {
+	return func(s *scriptSettings) {
+		s.noTransaction = true
+	}
+}
+
+// StatementError is returned by RunScriptIntoGlaze when a statement of the script fails.
+type StatementError struct {
+	// Index is the position of the statement in the script, starting at 1.
+	Index     int
+	Statement string
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d failed: %s\n%s", e.Index, e.Err, e.Statement)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// RunScriptIntoGlaze runs the statements in a single transaction, rolled back if a statement
+// fails, and outputs a row per statement with its rows_affected and duration_ms.
+// With WithDryRun, the rows only contain the statements.
+//
+// Note that MySQL commits DDL statements (CREATE, ALTER, DROP, ...) implicitly.
+func RunScriptIntoGlaze(
+	ctx context.Context,
+	db *sqlx.DB,
+	statements []string,
+	gp middlewares.Processor,
+	options ...ScriptOption,
+) error {
+	settings := &scriptSettings{}
+	for _, o := range options {
+		o(settings)
+	}
+
+	if settings.dryRun {
+		for i, statement := range statements {
+			row := types.NewRow(
+				types.MRP("statement", i+1),
+				types.MRP("query", statement),
+			)
+			if err := gp.AddRow(ctx, row); err != nil {
+				return errors.Wrapf(err, "Could not process input object")
+			}
+		}
+		return nil
+	}
+
+	var tx *sqlx.Tx
+	exec := db.ExecContext
+	if !settings.noTransaction {
+		var err error
+		tx, err = db.BeginTxx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "Could not start transaction")
+		}
+		exec = tx.ExecContext
+	}
+
+	rows := make([]types.Row, 0, len(statements))
+	for i, statement := range statements {
+		start := time.Now()
+		result, err := exec(ctx, statement)
+		if err != nil {
+			if tx != nil {
+				if rollbackErr := tx.Rollback(); rollbackErr != nil {
+					log.Warn().Err(rollbackErr).Msg("Could not roll back script transaction")
+				}
+			}
+			return &StatementError{Index: i + 1, Statement: statement, Err: err}
+		}
+		duration := time.Since(start)
+
+		var rowsAffected interface{}
+		if n, err := result.RowsAffected(); err == nil {
+			rowsAffected = n
+		}
+		rows = append(rows, types.NewRow(
+			types.MRP("statement", i+1),
+			types.MRP("query", statement),
+			types.MRP("rows_affected", rowsAffected),
+			types.MRP("duration_ms", float64(duration.Microseconds())/1000),
+		))
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return errors.Wrap(err, "Could not commit script transaction")
+		}
+	}
+
+	for _, row := range rows {
+		if err := gp.AddRow(ctx, row); err != nil {
+			return errors.Wrapf(err, "Could not process input object")
+		}
+	}
+	return nil
+}
+
+//go:embed "flags/sql-script.yaml"
+var scriptFlagsYaml []byte
+
+const SqlScriptSlug = "sql-script"
+
+type SqlScriptSettings struct {
+	DryRun        bool `glazed:"dry-run"`
+	NoTransaction bool `glazed:"no-transaction"`
+}
+
+// Options returns the ScriptOptions for the settings.
+func (s *SqlScriptSettings) Options() []ScriptOption {
+	ret := []ScriptOption{WithDryRun(s.DryRun)}
+	if s.NoTransaction {
+		ret = append(ret, WithoutTransaction())
+	}
+	return ret
+}
+
+type SqlScriptParameterLayer struct {
+	schema.SectionImpl `yaml:",inline"`
+}
+
+func NewSqlScriptParameterLayer(
+	options ...schema.SectionOption,
+) (*SqlScriptParameterLayer, error) {
+	ret, err := schema.NewSectionFromYAML(scriptFlagsYaml, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize sql-script section")
+	}
+	return &SqlScriptParameterLayer{
+		SectionImpl: *ret,
+	}, nil
+}
@@ -0,0 +1,253 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect *Dialect
+		script  string
+		want    []string
+	}{
+		{
+			name:    "simple",
+			dialect: DialectSQLite,
+			script:  "CREATE TABLE a (id INT);\n INSERT INTO a VALUES (1) ;\n\n",
+			want:    []string{"CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"},
+		},
+		{
+			name:    "no trailing semicolon",
+			dialect: DialectSQLite,
+			script:  "SELECT 1; SELECT 2",
+			want:    []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:    "semicolons in strings and identifiers",
+			dialect: DialectSQLite,
+			script:  `INSERT INTO "a;b" VALUES ('x;y', 'it''s;'); SELECT 1`,
+			want:    []string{`INSERT INTO "a;b" VALUES ('x;y', 'it''s;')`, "SELECT 1"},
+		},
+		{
+			name:    "comments",
+			dialect: DialectSQLite,
+			script:  "-- drop; everything\nSELECT 1; /* a; b */ SELECT 2; -- trailing;\n",
+			want:    []string{"-- drop; everything\nSELECT 1", "/* a; b */ SELECT 2"},
+		},
+		{
+			name:    "mysql backslash escapes, backticks and hash comments",
+			dialect: DialectMySQL,
+			script:  "INSERT INTO `t;` VALUES ('a\\';b', \"c\\\";d\"); # comment;\nSELECT 1",
+			want:    []string{"INSERT INTO `t;` VALUES ('a\\';b', \"c\\\";d\")", "# comment;\nSELECT 1"},
+		},
+		{
+			name:    "backslashes are literal in sqlite",
+			dialect: DialectSQLite,
+			script:  `SELECT 'a\'; SELECT 2`,
+			want:    []string{`SELECT 'a\'`, "SELECT 2"},
+		},
+		{
+			name:    "postgres dollar quotes",
+			dialect: DialectPostgres,
+			script: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;\n" +
+				"DO $body$ BEGIN PERFORM 1; END $body$; SELECT $1",
+			want: []string{
+				"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+				"DO $body$ BEGIN PERFORM 1; END $body$",
+				"SELECT $1",
+			},
+		},
+		{
+			name:    "postgres escape strings and nested comments",
+			dialect: DialectPostgres,
+			script:  `SELECT E'a\';b', 'c\'; /* x /* y; */ z; */ SELECT 2`,
+			want:    []string{`SELECT E'a\';b', 'c\'`, `/* x /* y; */ z; */ SELECT 2`},
+		},
+		{
+			name:    "sqlite trigger in a transaction",
+			dialect: DialectSQLite,
+			script: "BEGIN TRANSACTION;\n" +
+				"CREATE TRIGGER log_items AFTER INSERT ON items BEGIN\n" +
+				"  INSERT INTO log VALUES (NEW.id, CASE WHEN NEW.name = 'end' THEN 1 ELSE 0 END);\n" +
+				"  UPDATE items SET name = upper(NEW.name) WHERE id = NEW.id;\n" +
+				"END;\n" +
+				"INSERT INTO items VALUES (1, 'foo');\n" +
+				"END;",
+			want: []string{
+				"BEGIN TRANSACTION",
+				"CREATE TRIGGER log_items AFTER INSERT ON items BEGIN\n" +
+					"  INSERT INTO log VALUES (NEW.id, CASE WHEN NEW.name = 'end' THEN 1 ELSE 0 END);\n" +
+					"  UPDATE items SET name = upper(NEW.name) WHERE id = NEW.id;\n" +
+					"END",
+				"INSERT INTO items VALUES (1, 'foo')",
+				"END",
+			},
+		},
+		{
+			name:    "mysql trigger with nested blocks",
+			dialect: DialectMySQL,
+			script: "CREATE TRIGGER t BEFORE UPDATE ON items FOR EACH ROW BEGIN\n" +
+				"  IF NEW.qty < 0 THEN\n" +
+				"    BEGIN SET NEW.qty = 0; END;\n" +
+				"  END IF;\n" +
+				"  SET NEW.updated = NOW();\n" +
+				"END;\n" +
+				"BEGIN; SELECT t.end FROM t; COMMIT;",
+			want: []string{
+				"CREATE TRIGGER t BEFORE UPDATE ON items FOR EACH ROW BEGIN\n" +
+					"  IF NEW.qty < 0 THEN\n" +
+					"    BEGIN SET NEW.qty = 0; END;\n" +
+					"  END IF;\n" +
+					"  SET NEW.updated = NOW();\n" +
+					"END",
+				"BEGIN",
+				"SELECT t.end FROM t",
+				"COMMIT",
+			},
+		},
+		{
+			name:    "mysql procedure with a CASE statement",
+			dialect: DialectMySQL,
+			script: "CREATE PROCEDURE p(IN x INT) BEGIN\n" +
+				"  CASE x WHEN 1 THEN SELECT 1; ELSE SELECT 2; END CASE;\n" +
+				"END;\n" +
+				"SELECT 3;",
+			want: []string{
+				"CREATE PROCEDURE p(IN x INT) BEGIN\n" +
+					"  CASE x WHEN 1 THEN SELECT 1; ELSE SELECT 2; END CASE;\n" +
+					"END",
+				"SELECT 3",
+			},
+		},
+		{
+			name:    "begin as an identifier",
+			dialect: DialectSQLite,
+			script:  "INSERT INTO t (begin) VALUES (1); SELECT 2;",
+			want:    []string{"INSERT INTO t (begin) VALUES (1)", "SELECT 2"},
+		},
+		{
+			name:    "begin atomic",
+			dialect: DialectPostgres,
+			script:  "CREATE FUNCTION f() RETURNS int LANGUAGE sql BEGIN ATOMIC SELECT 1; END; SELECT 2",
+			want:    []string{"CREATE FUNCTION f() RETURNS int LANGUAGE sql BEGIN ATOMIC SELECT 1; END", "SELECT 2"},
+		},
+		{
+			name:    "only comments",
+			dialect: DialectSQLite,
+			script:  "-- nothing\n; /* here */ ;",
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.dialect.SplitStatements(tt.script)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, script := range []string{"SELECT 'a", "SELECT \"a", "SELECT 1 /* a", "SELECT $$ a"} {
+		if _, err := DialectPostgres.SplitStatements(script); err == nil {
+			t.Errorf("expected error for %q", script)
+		}
+	}
+}
+
+func TestRunScriptIntoGlaze(t *testing.T) {
+	ctx := context.Background()
+	db := openCacheTestDB(t)
+
+	statements, err := RenderScript(ctx, db, `
+		-- maintenance
+		INSERT INTO items VALUES (3, {{ sqlString .name }});
+		UPDATE items SET name = 'x;' WHERE id IN ({{ sqlIn .ids }});
+	`, nil, map[string]interface{}{"name": "it's", "ids": []interface{}{1, 2}})
+	if err != nil {
+		t.Fatalf("could not render script: %v", err)
+	}
+	want := []string{
+		"-- maintenance\n\t\tINSERT INTO items VALUES (3, 'it''s')",
+		"UPDATE items SET name = 'x;' WHERE id IN (1,2)",
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Fatalf("got %q, want %q", statements, want)
+	}
+
+	dryRun := &rowCollector{}
+	if err := RunScriptIntoGlaze(ctx, db, statements, dryRun, WithDryRun(true)); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(dryRun.rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(dryRun.rows))
+	}
+	var count int
+	if err := db.Get(&count, "SELECT COUNT(*) FROM items"); err != nil || count != 2 {
+		t.Fatalf("expected dry run not to modify the database, got %d rows (%v)", count, err)
+	}
+
+	c := &rowCollector{}
+	if err := RunScriptIntoGlaze(ctx, db, statements, c); err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+	if len(c.rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(c.rows))
+	}
+	if n, _ := c.rows[1].Get("rows_affected"); n != int64(2) {
+		t.Errorf("expected 2 rows affected by the update, got %v", n)
+	}
+	if _, ok := c.rows[0].Get("duration_ms"); !ok {
+		t.Errorf("expected a duration")
+	}
+
+	// the failing statement rolls back the whole script
+	err = RunScriptIntoGlaze(ctx, db, []string{"DELETE FROM items", "INSERT INTO missing VALUES (1)"}, &rowCollector{})
+	var statementErr *StatementError
+	if !errors.As(err, &statementErr) || statementErr.Index != 2 {
+		t.Fatalf("expected error of statement 2, got %v", err)
+	}
+	if err := db.Get(&count, "SELECT COUNT(*) FROM items"); err != nil || count != 3 {
+		t.Fatalf("expected the delete to be rolled back, got %d rows (%v)", count, err)
+	}
+
+	// without a transaction, the statements before the failure stay applied
+	err = RunScriptIntoGlaze(ctx, db, []string{"DELETE FROM items", "INSERT INTO missing VALUES (1)"}, &rowCollector{},
+		WithoutTransaction())
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := db.Get(&count, "SELECT COUNT(*) FROM items"); err != nil || count != 0 {
+		t.Fatalf("expected the delete to be applied, got %d rows (%v)", count, err)
+	}
+}
+
+func TestRunScriptWithTrigger(t *testing.T) {
+	ctx := context.Background()
+	db := openCacheTestDB(t)
+
+	statements, err := DialectSQLite.SplitStatements(`CREATE TABLE log (id INTEGER, name TEXT);
+CREATE TRIGGER log_items AFTER INSERT ON items BEGIN
+  INSERT INTO log VALUES (NEW.id, NEW.name);
+  INSERT INTO log VALUES (NEW.id, 'again');
+END;
+INSERT INTO items VALUES (3, 'baz');`)
+	if err != nil {
+		t.Fatalf("could not split script: %v", err)
+	}
+	if err := RunScriptIntoGlaze(ctx, db, statements, &rowCollector{}); err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+
+	var count int
+	if err := db.Get(&count, "SELECT COUNT(*) FROM log"); err != nil || count != 2 {
+		t.Fatalf("expected the trigger to log 2 rows, got %d (%v)", count, err)
+	}
+}
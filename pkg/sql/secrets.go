@@ -0,0 +1,192 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Secret references can be used instead of plaintext values for the connection fields
+// (password, user, host, database, schema, dsn and the dsn-options values):
+//   - env:NAME reads the environment variable NAME
+//   - file:/path reads the file, which must not be accessible by group or others
+//   - cmd:command runs command with the shell and reads its output, e.g. cmd:pass show db.
+//     Only the password and the dsn can be commands, the other fields are used as is.
+//   - raw:value is value itself, for values that start with one of the prefixes
+//
+// They are resolved when connecting, so that the config never holds the resolved values.
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+	secretCmdPrefix  = "cmd:"
+	secretRawPrefix  = "raw:"
+)
+
+// secretKinds are the kinds of references resolved for a field.
+type secretKinds int
+
+const (
+	secretEnv secretKinds = 1 << iota
+	secretFile
+	secretCmd
+
+	allSecretKinds = secretEnv | secretFile | secretCmd
+)
+
+// IsSecretReference returns true if value is an env:, file: or cmd: reference.
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, secretEnvPrefix) ||
+		strings.HasPrefix(value, secretFilePrefix) ||
+		strings.HasPrefix(value, secretCmdPrefix)
+}
+
+// ResolveSecret returns the value of a secret reference, or value itself if it isn't one.
+// A trailing newline is removed from the content of files and the output of commands.
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	return resolveSecret(ctx, value, allSecretKinds)
+}
+
+// resolveSecret resolves value if it is a reference of one of the given kinds, and
+// returns it as is otherwise. The dsn and database of sqlite3 connections may be file: URIs.
+func resolveSecret(ctx context.Context, value string, kinds secretKinds) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretRawPrefix):
+		return strings.TrimPrefix(value, secretRawPrefix), nil
+
+	case strings.HasPrefix(value, secretEnvPrefix) && kinds&secretEnv != 0:
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", errors.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+
+	case strings.HasPrefix(value, secretFilePrefix) && kinds&secretFile != 0:
+		return readSecretFile(strings.TrimPrefix(value, secretFilePrefix))
+
+	case strings.HasPrefix(value, secretCmdPrefix) && kinds&secretCmd != 0:
+		return runSecretCommand(ctx, strings.TrimPrefix(value, secretCmdPrefix))
+
+	default:
+		return value, nil
+	}
+}
+
+func readSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read secret file %s", path)
+	}
+	// windows doesn't have unix permissions
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", errors.Errorf(
+			"secret file %s must not be accessible by group or others (mode %04o), use chmod 600",
+			path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read secret file %s", path)
+	}
+	return trimNewline(string(data)), nil
+}
+
+func runSecretCommand(ctx context.Context, command string) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", errors.New("empty secret command")
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// the output is the secret, only report stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "secret command %q failed: %s", command, strings.TrimSpace(stderr.String()))
+	}
+	return trimNewline(stdout.String()), nil
+}
+
+func trimNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
+
+// ResolveSecrets replaces the secret references in the fields of the source by their values.
+// The database of a sqlite3 source is a path, file: is left to the driver there. Only the
+// password is resolved with cmd:, since the other fields may come from files such as dbt
+// profiles that aren't expected to run commands.
+func (s *Source) ResolveSecrets(ctx context.Context) error {
+	databaseKinds := secretEnv | secretFile
+	if s.Type == "sqlite3" {
+		databaseKinds = secretEnv
+	}
+	fields := []struct {
+		name  string
+		value *string
+		kinds secretKinds
+	}{
+		{"host", &s.Hostname, secretEnv | secretFile},
+		{"user", &s.Username, secretEnv | secretFile},
+		{"password", &s.Password, allSecretKinds},
+		{"database", &s.Database, databaseKinds},
+		{"schema", &s.Schema, secretEnv | secretFile},
+	}
+	for _, f := range fields {
+		v, err := resolveSecret(ctx, *f.value, f.kinds)
+		if err != nil {
+			return errors.Wrapf(err, "could not resolve %s", f.name)
+		}
+		*f.value = v
+	}
+
+	if len(s.Options) > 0 {
+		options := map[string]string{}
+		for k, v := range s.Options {
+			resolved, err := resolveSecret(ctx, v, secretEnv|secretFile)
+			if err != nil {
+				return errors.Wrapf(err, "could not resolve dsn option %s", k)
+			}
+			options[k] = resolved
+		}
+		s.Options = options
+	}
+	return nil
+}
+
+const redactedSecret = "xxxxx"
+
+var (
+	postgresPasswordRegexp = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+	mysqlPasswordRegexp    = regexp.MustCompile(`^([^:@/]*):(.*)@([a-z]*\(|/)`)
+)
+
+// redactDSN masks the password of a DSN so that it can be logged. Secret references are
+// returned as is, since they don't contain the secret.
+func redactDSN(dsn string) string {
+	if IsSecretReference(dsn) {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, secretRawPrefix) {
+		return secretRawPrefix + redactDSN(strings.TrimPrefix(dsn, secretRawPrefix))
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		return u.Redacted()
+	}
+	if postgresPasswordRegexp.MatchString(dsn) {
+		return postgresPasswordRegexp.ReplaceAllString(dsn, "${1}"+redactedSecret)
+	}
+	return mysqlPasswordRegexp.ReplaceAllString(dsn, "${1}:"+redactedSecret+"@${3}")
+}
@@ -32,6 +32,24 @@ type SqlConnectionSettings struct {
 	Dsn        string `glazed:"dsn"`
 	Driver     string `glazed:"driver"`
 	SSLDisable bool   `glazed:"ssl-disable"`
+	ReadOnly   bool   `glazed:"read-only"`
+
+	MaxOpenConns    int    `glazed:"max-open-conns"`
+	MaxIdleConns    int    `glazed:"max-idle-conns"`
+	ConnMaxLifetime string `glazed:"conn-max-lifetime"`
+	ConnMaxIdleTime string `glazed:"conn-max-idle-time"`
+
+	QueryTimeout string            `glazed:"query-timeout"`
+	DSNOptions   map[string]string `glazed:"dsn-options"`
+
+	ConnectTimeout    string `glazed:"connect-timeout"`
+	ConnectAttempts   int    `glazed:"connect-attempts"`
+	ConnectBackoff    string `glazed:"connect-backoff"`
+	ConnectMaxBackoff string `glazed:"connect-max-backoff"`
+	ConnectWait       string `glazed:"connect-wait"`
+
+	Connection      string `glazed:"connection"`
+	ConnectionsFile string `glazed:"connections-file"`
 }
 
 func NewSqlConnectionParameterLayer(
@@ -60,6 +78,7 @@ type DbtSettings struct {
 	DbtProfilesPath string `glazed:"dbt-profiles-path"`
 	UseDbtProfiles  bool   `glazed:"use-dbt-profiles"`
 	DbtProfile      string `glazed:"dbt-profile"`
+	DbtTarget       string `glazed:"dbt-target"`
 }
 
 func NewDbtParameterLayer(
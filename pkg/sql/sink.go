@@ -0,0 +1,675 @@
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// The kinds of values of a column, inferred from the rows written to a TableSink.
+const (
+	columnKindText      = "text"
+	columnKindBoolean   = "boolean"
+	columnKindInteger   = "integer"
+	columnKindFloat     = "float"
+	columnKindTimestamp = "timestamp"
+	columnKindBinary    = "binary"
+	columnKindJSON      = "json"
+)
+
+// TableSink is a glaze processor writing the rows it receives into a table, so that the
+// output of a command can be queried later. The rows are inserted in batches; the columns
+// are the fields of the first batch, and later rows can't add columns.
+//
+// The rows are inserted with COPY on Postgres, the appender on DuckDB and multi-row INSERT
+// statements otherwise, or always with INSERT statements when upserting.
+type TableSink struct {
+	db          *sqlx.DB
+	dialect     *Dialect
+	table       string
+	createTable bool
+	inferRows   int
+	batchSize   int
+	upsertKey   []string
+
+	columnTypes []*ColumnType
+	columns     []string
+	kinds       map[string]string
+	pending     []types.Row
+}
+
+type TableSinkOption func(*TableSink)
+
+// WithCreateTable creates the table if it doesn't exist, with the column types inferred
+// from the first inferRows rows (100 if inferRows is 0). A column with only NULL values
+// or values of different types is a text column.
+func WithCreateTable(inferRows int) TableSinkOption {
+	return func(s *TableSink) {
+		s.createTable = true
+		if inferRows > 0 {
+			s.inferRows = inferRows
+		}
+	}
+}
+
+// WithBatchSize sets the number of rows inserted at once, 1000 by default.
+func WithBatchSize(batchSize int) TableSinkOption {
+	return func(s *TableSink) {
+		if batchSize > 0 {
+			s.batchSize = batchSize
+		}
+	}
+}
+
+// WithUpsertKey updates the rows with the same values for the key columns instead of
+// inserting them. The key must be the primary key or a unique constraint of the table;
+// with WithCreateTable, it is the primary key of the created table.
+func WithUpsertKey(columns ...string) TableSinkOption {
+	return func(s *TableSink) {
+		s.upsertKey = columns
+	}
+}
+
+// NewTableSink creates a TableSink writing into table, which can be schema-qualified.
+func NewTableSink(db *sqlx.DB, table string, options ...TableSinkOption) (*TableSink, error) {
+	ret := &TableSink{
+		db:        db,
+		dialect:   DialectForDB(db),
+		table:     table,
+		inferRows: 100,
+		batchSize: 1000,
+	}
+	for _, o := range options {
+		o(ret)
+	}
+
+	if _, err := ret.dialect.QuoteIdentifier(table); err != nil {
+		return nil, errors.Wrap(err, "Invalid table name")
+	}
+	return ret, nil
+}
+
+func (s *TableSink) AddRow(ctx context.Context, row types.Row) error {
+	if s.columns != nil {
+		if err := s.checkColumns(row); err != nil {
+			return err
+		}
+	}
+	s.pending = append(s.pending, row)
+
+	n := s.batchSize
+	if s.columns == nil && s.createTable && s.inferRows > n {
+		n = s.inferRows
+	}
+	if len(s.pending) >= n {
+		return s.flush(ctx)
+	}
+	return nil
+}
+
+// SetColumnTypes receives the column types of the query results passed to the sink (see
+// ColumnTypesReceiver). They set the order of the columns and the types of the columns
+// without values, so that an empty result creates an empty table.
+func (s *TableSink) SetColumnTypes(ctx context.Context, columns []*ColumnType) error {
+	if s.columns == nil {
+		s.columnTypes = columns
+	}
+	return nil
+}
+
+// Close inserts the pending rows. It doesn't close the connection.
+func (s *TableSink) Close(ctx context.Context) error {
+	if s.columns == nil && len(s.pending) == 0 && s.createTable && len(s.columnTypes) > 0 {
+		return s.initColumns(ctx)
+	}
+	return s.flush(ctx)
+}
+
+func (s *TableSink) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	if s.columns == nil {
+		if err := s.initColumns(ctx); err != nil {
+			return err
+		}
+	}
+
+	values := make([][]interface{}, len(s.pending))
+	for i, row := range s.pending {
+		v, err := s.rowValues(row)
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+
+	var err error
+	switch {
+	case len(s.upsertKey) > 0:
+		err = s.insert(ctx, values)
+	case s.dialect == DialectDuckDB:
+		err = s.append(ctx, values)
+	case s.dialect == DialectPostgres:
+		err = s.copyFrom(ctx, values)
+	default:
+		err = s.insert(ctx, values)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Could not insert rows into %s", s.table)
+	}
+
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// initColumns sets the columns and their kinds from the pending rows and the column types,
+// and creates the table with WithCreateTable.
+func (s *TableSink) initColumns(ctx context.Context) error {
+	columns := []string{}
+	columnValues := map[string][]interface{}{}
+	for _, ct := range s.columnTypes {
+		if _, ok := columnValues[ct.Name]; !ok {
+			columns = append(columns, ct.Name)
+			columnValues[ct.Name] = []interface{}{}
+		}
+	}
+	for _, row := range s.pending {
+		for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+			if _, ok := columnValues[pair.Key]; !ok {
+				columns = append(columns, pair.Key)
+			}
+			columnValues[pair.Key] = append(columnValues[pair.Key], pair.Value)
+		}
+	}
+	if len(columns) == 0 {
+		return errors.New("Could not write rows without columns")
+	}
+
+	for _, key := range s.upsertKey {
+		if _, ok := columnValues[key]; !ok {
+			return errors.Errorf("Upsert key column %s is not a column of the rows", key)
+		}
+	}
+
+	databaseKinds := map[string]string{}
+	for _, ct := range s.columnTypes {
+		databaseKinds[ct.Name] = ct.kind()
+	}
+
+	kinds := map[string]string{}
+	for _, column := range columns {
+		kinds[column] = inferColumnKind(columnValues[column])
+		// the values are NULL or there are no rows
+		if k := databaseKinds[column]; k != "" && !hasNonNilValue(columnValues[column]) {
+			kinds[column] = k
+		}
+	}
+
+	if s.createTable {
+		query, err := s.createTableQuery(columns, kinds)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return errors.Wrapf(err, "Could not create table %s", s.table)
+		}
+	}
+
+	s.columns = columns
+	s.kinds = kinds
+	return nil
+}
+
+func hasNonNilValue(values []interface{}) bool {
+	for _, v := range values {
+		if v != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *TableSink) createTableQuery(columns []string, kinds map[string]string) (string, error) {
+	table, err := s.dialect.QuoteIdentifier(s.table)
+	if err != nil {
+		return "", err
+	}
+
+	isKey := map[string]bool{}
+	for _, key := range s.upsertKey {
+		isKey[key] = true
+	}
+
+	definitions := make([]string, 0, len(columns)+1)
+	for _, column := range columns {
+		name, err := s.dialect.quoteColumn(column)
+		if err != nil {
+			return "", err
+		}
+		definitions = append(definitions, name+" "+s.dialect.columnType(kinds[column], isKey[column]))
+	}
+	if len(s.upsertKey) > 0 {
+		key, err := s.dialect.quoteColumns(s.upsertKey)
+		if err != nil {
+			return "", err
+		}
+		definitions = append(definitions, "PRIMARY KEY ("+key+")")
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", table, strings.Join(definitions, ",\n  ")), nil
+}
+
+// rowValues returns the values of row in the order of the columns, converted to the kind
+// of their column.
+func (s *TableSink) rowValues(row types.Row) ([]interface{}, error) {
+	if err := s.checkColumns(row); err != nil {
+		return nil, err
+	}
+
+	ret := make([]interface{}, len(s.columns))
+	for i, column := range s.columns {
+		v, _ := row.Get(column)
+		value, err := columnValue(v, s.kinds[column])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not convert value of column %s", column)
+		}
+		ret[i] = value
+	}
+	return ret, nil
+}
+
+func (s *TableSink) checkColumns(row types.Row) error {
+	for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+		if _, ok := s.kinds[pair.Key]; !ok {
+			return errors.Errorf("Column %s is not a column of %s", pair.Key, s.table)
+		}
+	}
+	return nil
+}
+
+// insert runs multi-row INSERT statements in a transaction, with as many rows per statement
+// as the driver supports bound parameters.
+func (s *TableSink) insert(ctx context.Context, values [][]interface{}) error {
+	table, err := s.dialect.QuoteIdentifier(s.table)
+	if err != nil {
+		return err
+	}
+	columns, err := s.dialect.quoteColumns(s.columns)
+	if err != nil {
+		return err
+	}
+	upsert, err := s.upsertClause()
+	if err != nil {
+		return err
+	}
+
+	rowsPerStatement := s.dialect.maxBindParameters() / len(s.columns)
+	if rowsPerStatement < 1 {
+		return errors.Errorf("Too many columns for %s", s.dialect.Name)
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for start := 0; start < len(values); start += rowsPerStatement {
+		end := start + rowsPerStatement
+		if end > len(values) {
+			end = len(values)
+		}
+
+		args := NewQueryArgs(BindTypeForDB(s.db))
+		tuples := make([]string, 0, end-start)
+		for _, v := range values[start:end] {
+			tuples = append(tuples, "("+args.BindList(v)+")")
+		}
+		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s%s", table, columns, strings.Join(tuples, ", "), upsert)
+		if _, err := tx.ExecContext(ctx, query, args.Args()...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// upsertClause returns the clause updating the existing rows with the key of an inserted row.
+func (s *TableSink) upsertClause() (string, error) {
+	if len(s.upsertKey) == 0 {
+		return "", nil
+	}
+
+	isKey := map[string]bool{}
+	for _, key := range s.upsertKey {
+		isKey[key] = true
+	}
+
+	mysql := s.dialect == DialectMySQL
+	updates := []string{}
+	for _, column := range s.columns {
+		if isKey[column] {
+			continue
+		}
+		name, err := s.dialect.quoteColumn(column)
+		if err != nil {
+			return "", err
+		}
+		if mysql {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", name, name))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", name, name))
+		}
+	}
+
+	if mysql {
+		if len(updates) == 0 {
+			// only the key: keep the existing row
+			name, err := s.dialect.quoteColumn(s.upsertKey[0])
+			if err != nil {
+				return "", err
+			}
+			updates = append(updates, name+" = "+name)
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", "), nil
+	}
+
+	key, err := s.dialect.quoteColumns(s.upsertKey)
+	if err != nil {
+		return "", err
+	}
+	if len(updates) == 0 {
+		return " ON CONFLICT (" + key + ") DO NOTHING", nil
+	}
+	return " ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(updates, ", "), nil
+}
+
+// splitTableName returns the schema and the name of a possibly schema-qualified table.
+func splitTableName(table string) (string, string) {
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// append inserts the rows with the DuckDB appender. The appender encodes the values of
+// JSON columns itself, so the already encoded values of the JSON columns created by the
+// sink are passed as json.RawMessage.
+func (s *TableSink) append(ctx context.Context, values [][]interface{}) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	schema, table := splitTableName(s.table)
+	return conn.Raw(func(driverConn interface{}) error {
+		appender, err := duckdb.NewAppenderWithColumns(driverConn.(driver.Conn), "", schema, table, s.columns)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			row := make([]driver.Value, len(v))
+			for i, value := range v {
+				if str, ok := value.(string); ok && s.createTable && s.kinds[s.columns[i]] == columnKindJSON {
+					value = json.RawMessage(str)
+				}
+				row[i] = value
+			}
+			if err := appender.AppendRow(row...); err != nil {
+				_ = appender.Close()
+				return err
+			}
+		}
+		return appender.CloseWithCancel(ctx)
+	})
+}
+
+// copyFrom inserts the rows with COPY on Postgres.
+func (s *TableSink) copyFrom(ctx context.Context, values [][]interface{}) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+		_, err := pgxConn.CopyFrom(ctx, pgx.Identifier(strings.Split(s.table, ".")), s.columns, pgx.CopyFromRows(values))
+		return err
+	})
+}
+
+// inferColumnKind returns the kind of the non-NULL values, or text if they are of
+// different kinds. Integers and floats are floats.
+func inferColumnKind(values []interface{}) string {
+	ret := ""
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		kind := valueKind(v)
+		switch {
+		case ret == "" || ret == kind:
+			ret = kind
+		case (ret == columnKindInteger && kind == columnKindFloat) ||
+			(ret == columnKindFloat && kind == columnKindInteger):
+			ret = columnKindFloat
+		default:
+			return columnKindText
+		}
+	}
+	if ret == "" {
+		return columnKindText
+	}
+	return ret
+}
+
+func valueKind(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return columnKindText
+	case bool:
+		return columnKindBoolean
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return columnKindInteger
+	case float32, float64:
+		return columnKindFloat
+	case time.Time:
+		return columnKindTimestamp
+	case []byte:
+		return columnKindBinary
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return columnKindJSON
+	default:
+		return columnKindText
+	}
+}
+
+// kind returns the kind of the values of a database type, or "" if it has no kind.
+func (c *ColumnType) kind() string {
+	switch c.baseType() {
+	case "BOOL", "BOOLEAN":
+		return columnKindBoolean
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "INT2", "INT4", "INT8",
+		"UTINYINT", "USMALLINT", "UINTEGER", "UBIGINT", "SERIAL", "BIGSERIAL":
+		return columnKindInteger
+	case "REAL", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE":
+		return columnKindFloat
+	case "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ", "TIMESTAMP_S", "TIMESTAMP_MS", "TIMESTAMP_NS":
+		return columnKindTimestamp
+	case "BLOB", "BYTEA", "BINARY", "VARBINARY", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB":
+		return columnKindBinary
+	case "JSON", "JSONB":
+		return columnKindJSON
+	default:
+		return ""
+	}
+}
+
+// columnValue converts v to the type inserted into a column of kind: JSON values are
+// encoded, numbers converted to int64 and float64, and other values of text columns
+// formatted.
+func columnValue(v interface{}, kind string) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch kind {
+	case columnKindJSON:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case columnKindInteger:
+		if i, ok := toInt64(v); ok {
+			return i, nil
+		}
+	case columnKindFloat:
+		if i, ok := toInt64(v); ok {
+			return float64(i), nil
+		}
+		if f, ok := v.(float32); ok {
+			return float64(f), nil
+		}
+	case columnKindText:
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		case []byte:
+			return string(v), nil
+		}
+		if valueKind(v) == columnKindJSON {
+			return columnValue(v, columnKindJSON)
+		}
+		return fmt.Sprint(v), nil
+	}
+	return v, nil
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+// columnType returns the type of the columns of kind created by a TableSink.
+// Key columns are VARCHAR(255) on MySQL, where TEXT columns can't be keys.
+func (d *Dialect) columnType(kind string, key bool) string {
+	switch kind {
+	case columnKindBoolean:
+		return "BOOLEAN"
+	case columnKindInteger:
+		return "BIGINT"
+	case columnKindFloat:
+		switch d {
+		case DialectMySQL, DialectDuckDB:
+			return "DOUBLE"
+		case DialectSQLite:
+			return "REAL"
+		default:
+			return "DOUBLE PRECISION"
+		}
+	case columnKindTimestamp:
+		switch d {
+		case DialectPostgres, DialectDuckDB:
+			return "TIMESTAMPTZ"
+		case DialectMySQL:
+			return "DATETIME(6)"
+		default:
+			return "TIMESTAMP"
+		}
+	case columnKindBinary:
+		switch d {
+		case DialectPostgres:
+			return "BYTEA"
+		case DialectMySQL:
+			return "LONGBLOB"
+		default:
+			return "BLOB"
+		}
+	case columnKindJSON:
+		switch d {
+		case DialectPostgres:
+			return "JSONB"
+		case DialectMySQL, DialectDuckDB:
+			return "JSON"
+		}
+	}
+
+	switch {
+	case d == DialectMySQL && key:
+		return "VARCHAR(255)"
+	case d == DialectDuckDB:
+		return "VARCHAR"
+	default:
+		return "TEXT"
+	}
+}
+
+// quoteColumn quotes a column name. Unlike QuoteIdentifier, dots are part of the name.
+func (d *Dialect) quoteColumn(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("empty column name")
+	}
+	if strings.ContainsRune(name, 0) {
+		return "", errors.Errorf("column name %q contains a NUL character", name)
+	}
+	return d.IdentifierQuote +
+		strings.ReplaceAll(name, d.IdentifierQuote, d.IdentifierQuote+d.IdentifierQuote) +
+		d.IdentifierQuote, nil
+}
+
+func (d *Dialect) quoteColumns(names []string) (string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		var err error
+		quoted[i], err = d.quoteColumn(name)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+// maxBindParameters returns the maximum number of parameters of a statement.
+func (d *Dialect) maxBindParameters() int {
+	switch d {
+	case DialectSQLite:
+		return 32766
+	case DialectMySQL, DialectPostgres:
+		return 65535
+	default:
+		return 999
+	}
+}
@@ -0,0 +1,208 @@
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestInferColumnKind(t *testing.T) {
+	tests := []struct {
+		values []interface{}
+		want   string
+	}{
+		{[]interface{}{1, nil, int64(2)}, columnKindInteger},
+		{[]interface{}{1, 2.5}, columnKindFloat},
+		{[]interface{}{true, false}, columnKindBoolean},
+		{[]interface{}{time.Now()}, columnKindTimestamp},
+		{[]interface{}{map[string]interface{}{"a": 1}, []string{"b"}}, columnKindJSON},
+		{[]interface{}{[]byte("x")}, columnKindBinary},
+		{[]interface{}{1, "a"}, columnKindText},
+		{[]interface{}{nil, nil}, columnKindText},
+	}
+	for _, tt := range tests {
+		if got := inferColumnKind(tt.values); got != tt.want {
+			t.Errorf("inferColumnKind(%v) = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+}
+
+func newSinkTestRows(n int) []types.Row {
+	ret := make([]types.Row, n)
+	for i := range ret {
+		ret[i] = types.NewRow(
+			types.MRP("id", i),
+			types.MRP("name", "item"),
+			types.MRP("price", float64(i)/2),
+			types.MRP("active", i%2 == 0),
+			types.MRP("tags", []string{"a", "b"}),
+			types.MRP("created_at", time.Date(2024, 3, 1, 12, 0, i, 0, time.UTC)),
+		)
+	}
+	return ret
+}
+
+func TestTableSink(t *testing.T) {
+	ctx := context.Background()
+
+	for _, driver := range []string{"sqlite3", "duckdb"} {
+		t.Run(driver, func(t *testing.T) {
+			dsn := ""
+			if driver == "sqlite3" {
+				dsn = ":memory:"
+			}
+			db, err := sqlx.Open(driver, dsn)
+			if err != nil {
+				t.Fatalf("could not open database: %v", err)
+			}
+			db.SetMaxOpenConns(1)
+			defer func() {
+				_ = db.Close()
+			}()
+
+			sink, err := NewTableSink(db, "items", WithCreateTable(10), WithBatchSize(7))
+			if err != nil {
+				t.Fatalf("could not create sink: %v", err)
+			}
+			for _, row := range newSinkTestRows(25) {
+				if err := sink.AddRow(ctx, row); err != nil {
+					t.Fatalf("could not add row: %v", err)
+				}
+			}
+			// a row without some of the columns
+			if err := sink.AddRow(ctx, types.NewRow(types.MRP("id", 100))); err != nil {
+				t.Fatalf("could not add row: %v", err)
+			}
+			if err := sink.Close(ctx); err != nil {
+				t.Fatalf("could not close sink: %v", err)
+			}
+
+			var count int
+			var sum float64
+			if err := db.QueryRowx("SELECT COUNT(*), SUM(price) FROM items").Scan(&count, &sum); err != nil {
+				t.Fatalf("could not query table: %v", err)
+			}
+			if count != 26 || sum != 150 {
+				t.Errorf("got %d rows with a total of %v, want 26 and 150", count, sum)
+			}
+
+			var name string
+			var active bool
+			var tags string
+			if err := db.QueryRowx("SELECT name, active, CAST(tags AS VARCHAR) FROM items WHERE id = 4").Scan(&name, &active, &tags); err != nil {
+				t.Fatalf("could not query table: %v", err)
+			}
+			if name != "item" || !active || tags != `["a","b"]` {
+				t.Errorf("got %s %v %s", name, active, tags)
+			}
+
+			var createdAt time.Time
+			if err := db.QueryRowx("SELECT created_at FROM items WHERE id = 3").Scan(&createdAt); err != nil {
+				t.Fatalf("could not query table: %v", err)
+			}
+			if want := time.Date(2024, 3, 1, 12, 0, 3, 0, time.UTC); !createdAt.Equal(want) {
+				t.Errorf("got created_at %v, want %v", createdAt, want)
+			}
+
+			err = sink.AddRow(ctx, types.NewRow(types.MRP("id", 1), types.MRP("unknown", 1)))
+			if err == nil {
+				t.Errorf("expected rows with new columns to be rejected")
+			}
+		})
+	}
+}
+
+func TestTableSinkUpsert(t *testing.T) {
+	ctx := context.Background()
+
+	for _, driver := range []string{"sqlite3", "duckdb"} {
+		t.Run(driver, func(t *testing.T) {
+			dsn := ""
+			if driver == "sqlite3" {
+				dsn = ":memory:"
+			}
+			db, err := sqlx.Open(driver, dsn)
+			if err != nil {
+				t.Fatalf("could not open database: %v", err)
+			}
+			defer func() {
+				_ = db.Close()
+			}()
+
+			write := func(rows ...types.Row) {
+				sink, err := NewTableSink(db, "prices", WithCreateTable(0), WithUpsertKey("sku"))
+				if err != nil {
+					t.Fatalf("could not create sink: %v", err)
+				}
+				for _, row := range rows {
+					if err := sink.AddRow(ctx, row); err != nil {
+						t.Fatalf("could not add row: %v", err)
+					}
+				}
+				if err := sink.Close(ctx); err != nil {
+					t.Fatalf("could not close sink: %v", err)
+				}
+			}
+
+			write(
+				types.NewRow(types.MRP("sku", "a"), types.MRP("price", 1)),
+				types.NewRow(types.MRP("sku", "b"), types.MRP("price", 2)),
+			)
+			write(
+				types.NewRow(types.MRP("sku", "b"), types.MRP("price", 20)),
+				types.NewRow(types.MRP("sku", "c"), types.MRP("price", 30)),
+			)
+
+			prices := map[string]int{}
+			rows, err := db.Queryx("SELECT sku, price FROM prices")
+			if err != nil {
+				t.Fatalf("could not query table: %v", err)
+			}
+			defer func() {
+				_ = rows.Close()
+			}()
+			for rows.Next() {
+				var sku string
+				var price int
+				if err := rows.Scan(&sku, &price); err != nil {
+					t.Fatalf("could not scan row: %v", err)
+				}
+				prices[sku] = price
+			}
+			if want := map[string]int{"a": 1, "b": 20, "c": 30}; !reflect.DeepEqual(prices, want) {
+				t.Errorf("got %v, want %v", prices, want)
+			}
+		})
+	}
+}
+
+func TestTableSinkCreateTableQuery(t *testing.T) {
+	sink, err := NewTableSink(sqlx.NewDb(nil, "mysql"), "app.events", WithUpsertKey("id"))
+	if err != nil {
+		t.Fatalf("could not create sink: %v", err)
+	}
+	query, err := sink.createTableQuery([]string{"id", "payload"}, map[string]string{
+		"id":      columnKindText,
+		"payload": columnKindJSON,
+	})
+	if err != nil {
+		t.Fatalf("could not create query: %v", err)
+	}
+	want := "CREATE TABLE IF NOT EXISTS `app`.`events` (\n  `id` VARCHAR(255),\n  `payload` JSON,\n  PRIMARY KEY (`id`)\n)"
+	if query != want {
+		t.Errorf("got %q, want %q", query, want)
+	}
+
+	sink.columns = []string{"id", "payload"}
+	upsert, err := sink.upsertClause()
+	if err != nil {
+		t.Fatalf("could not create upsert clause: %v", err)
+	}
+	if want := " ON DUPLICATE KEY UPDATE `payload` = VALUES(`payload`)"; upsert != want {
+		t.Errorf("got %q, want %q", upsert, want)
+	}
+}
@@ -2,8 +2,14 @@ package sql
 
 import (
 	"fmt"
-	"gopkg.in/yaml.v3"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
 )
 
 // Source is the generic structure we use to represent
@@ -18,39 +24,239 @@ type Source struct {
 	Schema     string `yaml:"schema"`
 	Database   string `yaml:"database"`
 	SSLDisable bool   `yaml:"ssl_disable"`
+	// Options are additional driver parameters, see BuildConnectionString.
+	Options map[string]string `yaml:"options,omitempty"`
 }
 
+// ToConnectionString returns the DSN of the source, or an empty string if it can't be built.
+// Use BuildConnectionString to get the error.
 func (s *Source) ToConnectionString() string {
-	switch s.Type {
-	case "pgx":
-		sslMode := "disable"
-		if !s.SSLDisable {
-			sslMode = "require"
-		}
-		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", s.Hostname, s.Port, s.Username, s.Password, s.Database, sslMode)
-	case "mysql":
-		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", s.Username, s.Password, s.Hostname, s.Port, s.Database)
-	case "sqlite":
-		fallthrough
-	case "sqlite3":
-		return s.Database
-	case "duckdb":
-		return s.Database
-	default:
+	ret, err := s.BuildConnectionString()
+	if err != nil {
 		return ""
 	}
+	return ret
 }
 
 type dbtProfile struct {
-	Target  string             `yaml:"target"`
-	Outputs map[string]*Source `yaml:"outputs"`
+	Target  string                            `yaml:"target"`
+	Outputs map[string]map[string]interface{} `yaml:"outputs"`
 }
 
 type dbtProfiles = map[string]*dbtProfile
 
-// ParseDbtProfiles parses a dbt profiles.yml file and returns a map of sources
-func ParseDbtProfiles(profilesPath string) ([]*Source, error) {
-	// Read the file contents
+// envVarRegexp matches {{ env_var('NAME') }} and {{ env_var('NAME', 'default') }},
+// optionally followed by filters such as `| as_number`, which are ignored.
+var envVarRegexp = regexp.MustCompile(
+	`\{\{\s*env_var\(\s*(['"])([^'"]+)(['"])\s*(?:,\s*(['"])(.*?)(['"])\s*)?\)\s*(?:\|\s*\w+\s*)*\}\}`,
+)
+
+// expandDbtEnvVars replaces the env_var() calls in value by the value of the environment variable,
+// or the default if it isn't set.
+func expandDbtEnvVars(value string) (string, error) {
+	var err error
+	ret := envVarRegexp.ReplaceAllStringFunc(value, func(match string) string {
+		m := envVarRegexp.FindStringSubmatch(match)
+		if v, ok := os.LookupEnv(m[2]); ok {
+			return v
+		}
+		if m[4] != "" {
+			return m[5]
+		}
+		if err == nil {
+			err = errors.Errorf("environment variable %s is not set and has no default", m[2])
+		}
+		return ""
+	})
+	return ret, err
+}
+
+// dbtOutput gives access to the fields of a dbt output, with env_var() expanded.
+// The first error is kept in err and makes the following accessors return zero values.
+type dbtOutput struct {
+	name   string
+	fields map[string]interface{}
+	err    error
+}
+
+// lookup returns the first of keys that is set.
+func (o *dbtOutput) lookup(keys ...string) (interface{}, string, bool) {
+	for _, key := range keys {
+		if v, ok := o.fields[key]; ok && v != nil {
+			return v, key, true
+		}
+	}
+	return nil, "", false
+}
+
+func (o *dbtOutput) string(keys ...string) string {
+	v, key, ok := o.lookup(keys...)
+	if !ok || o.err != nil {
+		return ""
+	}
+	s, err := expandDbtEnvVars(fmt.Sprintf("%v", v))
+	if err != nil {
+		o.err = errors.Wrapf(err, "dbt output %s: field %s", o.name, key)
+		return ""
+	}
+	return s
+}
+
+func (o *dbtOutput) int(keys ...string) int {
+	v, key, ok := o.lookup(keys...)
+	if !ok || o.err != nil {
+		return 0
+	}
+	if i, ok := v.(int); ok {
+		return i
+	}
+	s := o.string(key)
+	if s == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		o.err = errors.Errorf("dbt output %s: field %s must be a number, got %q", o.name, key, s)
+		return 0
+	}
+	return i
+}
+
+func (o *dbtOutput) bool(keys ...string) bool {
+	v, key, ok := o.lookup(keys...)
+	if !ok || o.err != nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	s := o.string(key)
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		o.err = errors.Errorf("dbt output %s: field %s must be a boolean, got %q", o.name, key, s)
+		return false
+	}
+	return b
+}
+
+// options expands the values of an options map of the output.
+func (o *dbtOutput) options(options map[string]interface{}) map[string]string {
+	ret := map[string]string{}
+	values := &dbtOutput{name: o.name, fields: options}
+	for k := range options {
+		ret[k] = values.string(k)
+	}
+	if o.err == nil {
+		o.err = values.err
+	}
+	return ret
+}
+
+// postgresDbtOptions are the dbt-postgres fields passed on as connection parameters.
+var postgresDbtOptions = []string{
+	"sslrootcert", "sslcert", "sslkey", "connect_timeout", "keepalives_idle", "search_path", "application_name",
+}
+
+// require records an error naming the first of fields that is empty.
+func (o *dbtOutput) require(adapter string, fields ...[2]string) {
+	for _, f := range fields {
+		if o.err == nil && f[1] == "" {
+			o.err = errors.Errorf("dbt output %s (%s): missing field %s", o.name, adapter, f[0])
+		}
+	}
+}
+
+// dbtOutputToSource maps the fields of a dbt output to a Source, using the field names
+// of the adapter given by the output type:
+//   - postgres: host, port, user, password/pass, dbname/database, schema, sslmode
+//   - mysql: server/host, port, username/user, password/pass, database (or schema, as dbt-mysql does)
+//   - sqlite: the entry of schemas_and_paths for the schema (or main), or path
+//   - duckdb: path
+//
+// The legacy keys server, username and ssl_disable are accepted for every adapter, as is an
+// options map of additional driver parameters.
+func dbtOutputToSource(name string, fields map[string]interface{}) (*Source, error) {
+	o := &dbtOutput{name: name, fields: fields}
+	ret := &Source{
+		Name:       name,
+		Type:       o.string("type"),
+		Hostname:   o.string("host", "server"),
+		Port:       o.int("port"),
+		Username:   o.string("user", "username"),
+		Password:   o.string("password", "pass"),
+		Schema:     o.string("schema"),
+		SSLDisable: o.bool("ssl_disable"),
+	}
+
+	if options, ok := fields["options"].(map[string]interface{}); ok {
+		ret.Options = o.options(options)
+	}
+
+	adapter := strings.ToLower(ret.Type)
+	switch adapter {
+	case "postgres", "postgresql", "pgx", "redshift":
+		ret.Type = "pgx"
+		ret.Database = o.string("dbname", "database")
+		for _, key := range postgresDbtOptions {
+			if v := o.string(key); v != "" {
+				if ret.Options == nil {
+					ret.Options = map[string]string{}
+				}
+				ret.Options[key] = v
+			}
+		}
+		if sslMode := o.string("sslmode"); sslMode == "disable" {
+			ret.SSLDisable = true
+		} else if sslMode != "" {
+			if ret.Options == nil {
+				ret.Options = map[string]string{}
+			}
+			ret.Options["sslmode"] = sslMode
+		}
+		if ret.Port == 0 {
+			ret.Port = 5432
+		}
+		o.require(adapter, [2]string{"host", ret.Hostname}, [2]string{"user", ret.Username}, [2]string{"dbname", ret.Database})
+	case "mysql", "mariadb":
+		ret.Type = "mysql"
+		ret.Database = o.string("database", "schema")
+		if ret.Port == 0 {
+			ret.Port = 3306
+		}
+		o.require(adapter, [2]string{"server", ret.Hostname}, [2]string{"username", ret.Username}, [2]string{"database", ret.Database})
+	case "sqlite", "sqlite3":
+		ret.Type = "sqlite3"
+		// dbt-sqlite always sets database to 'database', the files are in schemas_and_paths
+		if paths, ok := fields["schemas_and_paths"].(map[string]interface{}); ok {
+			schema := ret.Schema
+			if _, ok := paths[schema]; !ok {
+				schema = "main"
+			}
+			schemaPaths := &dbtOutput{name: name, fields: paths}
+			ret.Database = schemaPaths.string(schema)
+			if o.err == nil {
+				o.err = schemaPaths.err
+			}
+		}
+		if ret.Database == "" {
+			ret.Database = o.string("path")
+		}
+		o.require(adapter, [2]string{"path", ret.Database})
+	case "duckdb", "duck":
+		ret.Type = "duckdb"
+		ret.Database = o.string("path", "database")
+		o.require(adapter, [2]string{"path", ret.Database})
+	default:
+		ret.Database = o.string("database", "dbname")
+	}
+
+	if o.err != nil {
+		return nil, o.err
+	}
+	return ret, nil
+}
+
+func readDbtProfiles(profilesPath string) (dbtProfiles, error) {
 	if profilesPath == "" {
 		// replace ~ with $HOME in the path
 		profilesPath = os.ExpandEnv("$HOME/.dbt/profiles.yml")
@@ -62,39 +268,52 @@ func ParseDbtProfiles(profilesPath string) ([]*Source, error) {
 	}
 
 	var profiles dbtProfiles
-
-	// Parse the YAML file
 	err = yaml.Unmarshal(data, &profiles)
 	if err != nil {
 		return nil, err
 	}
 
+	// the config key holds global settings, not a profile
+	delete(profiles, "config")
+	return profiles, nil
+}
+
+// dbtProfileTarget returns the target of profile, which is override if set.
+func dbtProfileTarget(profile *dbtProfile, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	return expandDbtEnvVars(profile.Target)
+}
+
+// ParseDbtProfiles parses a dbt profiles.yml file and returns a map of sources.
+// Outputs that can't be mapped to a source (for example because an environment
+// variable is missing) are skipped, use FindDbtSource to get the error.
+func ParseDbtProfiles(profilesPath string) ([]*Source, error) {
+	profiles, err := readDbtProfiles(profilesPath)
+	if err != nil {
+		return nil, err
+	}
+
 	var ret []*Source
 
 	// Create sources for all profile.output combinations
 	for name, profile := range profiles {
-		for outputName, source := range profile.Outputs {
-			source.Name = fmt.Sprintf("%s.%s", name, outputName)
-
-			// Convert postgres type to pgx for proper driver usage
-			if source.Type == "postgres" {
-				source.Type = "pgx"
+		if profile == nil {
+			continue
+		}
+		for outputName, output := range profile.Outputs {
+			source, err := dbtOutputToSource(fmt.Sprintf("%s.%s", name, outputName), output)
+			if err != nil {
+				log.Warn().Err(err).Msg("Skipping dbt output")
+				continue
 			}
-
 			ret = append(ret, source)
-		}
-
-		// Also create a source for the default target of each profile
-		if profile.Target != "" {
-			if defaultOutput, exists := profile.Outputs[profile.Target]; exists {
-				defaultSource := *defaultOutput // copy the source
-				defaultSource.Name = name       // just use profile name
-
-				// Convert postgres type to pgx for proper driver usage
-				if defaultSource.Type == "postgres" {
-					defaultSource.Type = "pgx"
-				}
 
+			// Also create a source for the default target of each profile
+			if target, err := dbtProfileTarget(profile, ""); err == nil && target == outputName {
+				defaultSource := *source  // copy the source
+				defaultSource.Name = name // just use profile name
 				ret = append(ret, &defaultSource)
 			}
 		}
@@ -102,3 +321,41 @@ func ParseDbtProfiles(profilesPath string) ([]*Source, error) {
 
 	return ret, nil
 }
+
+// FindDbtSource returns the source for a dbt profile. name is either a profile name,
+// in which case target (or the target of the profile if empty) selects the output,
+// or profile.output.
+func FindDbtSource(profilesPath string, name string, target string) (*Source, error) {
+	profiles, err := readDbtProfiles(profilesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	profileName, outputName, hasOutput := strings.Cut(name, ".")
+	profile, ok := profiles[profileName]
+	if !ok || profile == nil {
+		return nil, errors.Errorf("dbt profile %s not found", profileName)
+	}
+	if target != "" || !hasOutput {
+		outputName, err = dbtProfileTarget(profile, target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dbt profile %s: field target", profileName)
+		}
+		if outputName == "" {
+			return nil, errors.Errorf("dbt profile %s has no target, use --dbt-target", profileName)
+		}
+	}
+
+	output, ok := profile.Outputs[outputName]
+	if !ok {
+		return nil, errors.Errorf("dbt profile %s has no output %s", profileName, outputName)
+	}
+	source, err := dbtOutputToSource(profileName+"."+outputName, output)
+	if err != nil {
+		return nil, err
+	}
+	if !hasOutput && target == "" {
+		source.Name = profileName
+	}
+	return source, nil
+}
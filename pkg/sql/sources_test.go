@@ -0,0 +1,194 @@
+package sql
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDbtProfiles = `
+config:
+  send_anonymous_usage_stats: false
+
+analytics:
+  target: "{{ env_var('CLAY_TEST_DBT_TARGET', 'dev') }}"
+  outputs:
+    dev:
+      type: postgres
+      host: "{{ env_var('CLAY_TEST_PG_HOST') }}"
+      port: "{{ env_var('CLAY_TEST_PG_PORT', '6543') | as_number }}"
+      user: analyst
+      pass: "{{ env_var('CLAY_TEST_PG_PASSWORD') }}"
+      dbname: warehouse
+      schema: public
+      sslmode: disable
+      sslrootcert: /etc/ssl/root.crt
+    prod:
+      type: postgres
+      host: prod.example.com
+      user: analyst
+      password: secret
+      dbname: warehouse
+      sslmode: verify-full
+    incomplete:
+      type: postgres
+      host: localhost
+      dbname: warehouse
+    broken:
+      type: postgres
+      host: "{{ env_var('CLAY_TEST_UNSET') }}"
+      user: analyst
+      dbname: warehouse
+
+shop:
+  target: local
+  outputs:
+    local:
+      type: mysql
+      server: localhost
+      username: root
+      password: root
+      schema: shop
+      options:
+        parseTime: "true"
+        charset: "{{ env_var('CLAY_TEST_CHARSET', 'utf8mb4') }}"
+    lite:
+      type: sqlite
+      threads: 1
+      database: database
+      schema: main
+      schemas_and_paths:
+        main: /data/shop.db
+      schema_directory: /data
+    litepath:
+      type: sqlite
+      path: /data/legacy.db
+    duck:
+      type: duckdb
+      path: /data/shop.duckdb
+`
+
+func writeTestDbtProfiles(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "profiles.yml")
+	if err := os.WriteFile(path, []byte(testDbtProfiles), 0o600); err != nil {
+		t.Fatalf("could not write profiles: %v", err)
+	}
+	t.Setenv("CLAY_TEST_PG_HOST", "db.example.com")
+	t.Setenv("CLAY_TEST_PG_PASSWORD", "hunter2")
+	return path
+}
+
+func TestFindDbtSource(t *testing.T) {
+	path := writeTestDbtProfiles(t)
+
+	tests := []struct {
+		name    string
+		profile string
+		target  string
+		want    Source
+	}{
+		{
+			name:    "default target with env vars",
+			profile: "analytics",
+			want: Source{
+				Name: "analytics", Type: "pgx", Hostname: "db.example.com", Port: 6543,
+				Username: "analyst", Password: "hunter2", Database: "warehouse", Schema: "public", SSLDisable: true,
+				Options: map[string]string{"sslrootcert": "/etc/ssl/root.crt"},
+			},
+		},
+		{
+			name:    "target override",
+			profile: "analytics",
+			target:  "prod",
+			want: Source{
+				Name: "analytics.prod", Type: "pgx", Hostname: "prod.example.com", Port: 5432,
+				Username: "analyst", Password: "secret", Database: "warehouse",
+				Options: map[string]string{"sslmode": "verify-full"},
+			},
+		},
+		{
+			name:    "mysql with legacy keys and schema as database",
+			profile: "shop",
+			want: Source{
+				Name: "shop", Type: "mysql", Hostname: "localhost", Port: 3306,
+				Username: "root", Password: "root", Database: "shop", Schema: "shop",
+				Options: map[string]string{"parseTime": "true", "charset": "utf8mb4"},
+			},
+		},
+		{
+			name:    "sqlite schemas_and_paths",
+			profile: "shop.lite",
+			want:    Source{Name: "shop.lite", Type: "sqlite3", Database: "/data/shop.db", Schema: "main"},
+		},
+		{
+			name:    "sqlite path",
+			profile: "shop",
+			target:  "litepath",
+			want:    Source{Name: "shop.litepath", Type: "sqlite3", Database: "/data/legacy.db"},
+		},
+		{
+			name:    "duckdb path",
+			profile: "shop",
+			target:  "duck",
+			want:    Source{Name: "shop.duck", Type: "duckdb", Database: "/data/shop.duckdb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindDbtSource(path, tt.profile, tt.target)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDbtSourceErrors(t *testing.T) {
+	path := writeTestDbtProfiles(t)
+
+	tests := []struct {
+		profile string
+		target  string
+		want    string
+	}{
+		{"analytics", "broken", "environment variable CLAY_TEST_UNSET is not set"},
+		{"analytics.incomplete", "", "dbt output analytics.incomplete (postgres): missing field user"},
+		{"analytics", "staging", "dbt profile analytics has no output staging"},
+		{"missing", "", "dbt profile missing not found"},
+	}
+	for _, tt := range tests {
+		_, err := FindDbtSource(path, tt.profile, tt.target)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("FindDbtSource(%s, %s): expected error containing %q, got %v", tt.profile, tt.target, tt.want, err)
+		}
+	}
+}
+
+func TestParseDbtProfilesSkipsInvalidOutputs(t *testing.T) {
+	path := writeTestDbtProfiles(t)
+
+	sources, err := ParseDbtProfiles(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := map[string]bool{}
+	for _, s := range sources {
+		names[s.Name] = true
+	}
+	for _, name := range []string{"analytics", "analytics.dev", "analytics.prod", "shop", "shop.local", "shop.lite", "shop.duck"} {
+		if !names[name] {
+			t.Errorf("expected source %s in %v", name, names)
+		}
+	}
+	for _, name := range []string{"analytics.broken", "analytics.incomplete", "config"} {
+		if names[name] {
+			t.Errorf("expected source %s to be skipped", name)
+		}
+	}
+}
@@ -14,42 +14,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-// sqlEscape escapes single quotes in a string for SQL queries.
-// It doubles any single quote characters to prevent SQL injection.
-func sqlEscape(value string) string {
-	return strings.ReplaceAll(value, "'", "''")
-}
-
-// sqlString wraps a string value in single quotes for SQL queries.
-func sqlString(value string) string {
-	return fmt.Sprintf("'%s'", value)
-}
-
-// sqlStringLike formats a string for use in SQL LIKE queries, wrapping the value with '%' and escaping it.
-func sqlStringLike(value string) string {
-	return fmt.Sprintf("'%%%s%%'", sqlEscape(value))
-}
-
-// sqlStringIn converts a slice of values into a SQL IN clause string, properly escaping and quoting each value.
-// Returns an error if the input cannot be cast to a slice of strings.
-func sqlStringIn(values interface{}) (string, error) {
-	strList, err := cast.CastListToStringList(values)
-	if err != nil {
-		return "", errors.Errorf("could not cast %v to []string", values)
-	}
-	return fmt.Sprintf("'%s'", strings.Join(strList, "','")), nil
-}
-
-// sqlIn converts a slice of interface{} values into a comma-separated string for SQL queries.
-// Each value is formatted using fmt.Sprintf with the %v verb.
-func sqlIn(values []interface{}) string {
-	strValues := make([]string, len(values))
-	for i, v := range values {
-		strValues[i] = fmt.Sprintf("%v", v)
-	}
-	return strings.Join(strValues, ",")
-}
-
 // sqlIntIn converts a slice of integer values into a comma-separated string for SQL queries.
 // Returns an empty string if the input cannot be cast to a slice of int64.
 func sqlIntIn(values interface{}) string {
@@ -64,10 +28,10 @@ func sqlIntIn(values interface{}) string {
 	return strings.Join(strValues, ",")
 }
 
-// sqlDate_ formats a date value for SQL queries, using different formats based on the date's timezone.
+// formatSqlDate formats a date value for SQL queries, using defaultFormat for dates in the
+// local timezone and fullFormat otherwise.
 // Returns an error if the date cannot be parsed or formatted.
-// This is a helper function used by other date formatting functions.
-func sqlDate_(date interface{}, fullFormat string, defaultFormat string) (string, error) {
+func formatSqlDate(date interface{}, fullFormat string, defaultFormat string) (string, error) {
 	switch v := date.(type) {
 	case string:
 		parsedDate, err := fields.ParseDate(v)
@@ -76,29 +40,57 @@ func sqlDate_(date interface{}, fullFormat string, defaultFormat string) (string
 		}
 		// if timezone is local, output YYYY-mm-dd
 		if parsedDate.Location() == time.Local {
-			return "'" + parsedDate.Format(defaultFormat) + "'", nil
+			return parsedDate.Format(defaultFormat), nil
 		}
-		return "'" + parsedDate.Format(fullFormat) + "'", nil
+		return parsedDate.Format(fullFormat), nil
 	case time.Time:
 		if v.Location() == time.Local {
-			return "'" + v.Format(defaultFormat) + "'", nil
+			return v.Format(defaultFormat), nil
 		}
-		return "'" + v.Format(fullFormat) + "'", nil
+		return v.Format(fullFormat), nil
 	default:
 		return "", errors.Errorf("could not parse date %v", date)
 	}
 }
 
+// sqlDate_ formats a date value for SQL queries as a quoted string, see formatSqlDate.
+// This is a helper function used by other date formatting functions.
+func sqlDate_(date interface{}, fullFormat string, defaultFormat string) (string, error) {
+	s, err := formatSqlDate(date, fullFormat, defaultFormat)
+	if err != nil {
+		return "", err
+	}
+	return "'" + s + "'", nil
+}
+
+// quoteDate formats a date value as a quoted string, see Dialect.FormatDate.
+func (d *Dialect) quoteDate(date interface{}) (string, error) {
+	s, err := d.FormatDate(date)
+	if err != nil {
+		return "", err
+	}
+	return "'" + s + "'", nil
+}
+
+// quoteDateTime formats a datetime value as a quoted string, see Dialect.FormatDateTime.
+func (d *Dialect) quoteDateTime(date interface{}) (string, error) {
+	s, err := d.FormatDateTime(date)
+	if err != nil {
+		return "", err
+	}
+	return "'" + s + "'", nil
+}
+
 // sqlDate formats a date value for SQL queries as YYYY-MM-DD or RFC3339, based on the date's timezone.
 // Returns an error if the date cannot be parsed or formatted.
 func sqlDate(date interface{}) (string, error) {
-	return sqlDate_(date, time.RFC3339, "2006-01-02")
+	return DialectANSI.quoteDate(date)
 }
 
 // sqlDateTime formats a datetime value for SQL queries as YYYY-MM-DDTHH:MM:SS or RFC3339, based on the datetime's timezone.
 // Returns an error if the datetime cannot be parsed or formatted.
 func sqlDateTime(date interface{}) (string, error) {
-	return sqlDate_(date, time.RFC3339, "2006-01-02T15:04:05")
+	return DialectANSI.quoteDateTime(date)
 }
 
 // sqliteDate formats a date value specifically for SQLite queries as YYYY-MM-DD.
@@ -113,34 +105,77 @@ func sqliteDateTime(date interface{}) (string, error) {
 	return sqlDate_(date, "2006-01-02 15:04:05", "2006-01-02 15:04:05")
 }
 
-// sqlLike formats a string for use in SQL LIKE queries by wrapping the value with '%'.
-func sqlLike(value string) string {
-	return "'%" + value + "%'"
+// CreateTemplate creates the query template, with the value functions (sqlString, sqlIn, ...)
+// interpolating their values into the query text. The functions of the TemplateFuncRegistry
+// of ctx (see ContextWithTemplateFuncRegistry) are added to the built-in ones.
+func CreateTemplate(
+	ctx context.Context,
+	subQueries map[string]string,
+	ps map[string]interface{},
+	db *sqlx.DB,
+) *template.Template {
+	return createTemplate(ctx, subQueries, ps, db, nil)
 }
 
-// TODO(manuel, 2023-11-19) Wrap this in a templating class that can accept additional funcmaps
-// (and maybe more templating functionality)
+// CreateBoundTemplate creates the query template, with the value functions binding their
+// values into args instead of interpolating them.
+func CreateBoundTemplate(
+	ctx context.Context,
+	subQueries map[string]string,
+	ps map[string]interface{},
+	db *sqlx.DB,
+	args *QueryArgs,
+) *template.Template {
+	return createTemplate(ctx, subQueries, ps, db, args)
+}
 
-func CreateTemplate(
+func createTemplate(
 	ctx context.Context,
 	subQueries map[string]string,
 	ps map[string]interface{},
 	db *sqlx.DB,
+	args *QueryArgs,
+) *template.Template {
+	return createDialectTemplate(ctx, subQueries, ps, db, args, DialectForDB(db))
+}
+
+// createDialectTemplate creates the query template, quoting values for dialect instead of
+// the dialect of db, for example to render a query without a connection.
+func createDialectTemplate(
+	ctx context.Context,
+	subQueries map[string]string,
+	ps map[string]interface{},
+	db *sqlx.DB,
+	args *QueryArgs,
+	dialect *Dialect,
 ) *template.Template {
 	t2 := templating.CreateTemplate("query").
 		Funcs(templating.TemplateFuncs).
 		Funcs(template.FuncMap{
-			"sqlStringIn":    sqlStringIn,
-			"sqlStringLike":  sqlStringLike,
+			"sqlStringIn": func(values interface{}) (string, error) {
+				strList, err := cast.CastListToStringList(values)
+				if err != nil {
+					return "", errors.Errorf("could not cast %v to []string", values)
+				}
+				quoted := make([]string, len(strList))
+				for i, s := range strList {
+					quoted[i] = dialect.QuoteString(s)
+				}
+				return strings.Join(quoted, ","), nil
+			},
+			"sqlStringLike":  dialect.LikeContains,
 			"sqlIntIn":       sqlIntIn,
-			"sqlIn":          sqlIn,
-			"sqlDate":        sqlDate,
-			"sqlDateTime":    sqlDateTime,
+			"sqlIn":          dialect.LiteralList,
+			"sqlDate":        dialect.quoteDate,
+			"sqlDateTime":    dialect.quoteDateTime,
 			"sqliteDate":     sqliteDate,
 			"sqliteDateTime": sqliteDateTime,
-			"sqlLike":        sqlLike,
-			"sqlString":      sqlString,
-			"sqlEscape":      sqlEscape,
+			"sqlLike":        dialect.LikeContains,
+			"sqlString":      dialect.QuoteString,
+			"sqlEscape":      dialect.EscapeString,
+			"sqlArg":         dialect.Literal,
+			"sqlIdent":       dialect.QuoteIdentifier,
+			"sqlIdentList":   dialect.QuoteIdentifierList,
 			"subQuery": func(name string) (string, error) {
 				s, ok := subQueries[name]
 				if !ok {
@@ -153,151 +188,145 @@ func CreateTemplate(
 				if err != nil {
 					return nil, err
 				}
-				_, rows, err := RunQuery(ctx, subQueries, query, data, db)
+				ret, _, err := runSubQuery(ctx, "sqlSlice", subQueries, query, data, db,
+					func(renderedQuery string, rows *sqlx.Rows) (interface{}, error) {
+						ret := []interface{}{}
+
+						for rows.Next() {
+							ret_, err := rows.SliceScan()
+							if err != nil {
+								return nil, errors.Wrap(err, "Could not scan row")
+							}
+
+							row := make([]interface{}, len(ret_))
+							for i, v := range ret_ {
+								row[i] = sqlEltToTemplateValue(v)
+							}
+
+							ret = append(ret, row)
+						}
+
+						return ret, nil
+					})
 				if err != nil {
-					// TODO(manuel, 2023-03-27) This nesting of errors in nested templates becomes quite unpalatable
-					// This is what can be output for just one level deep:
-					//
-					// Error: Could not generate query: template: query:1:13: executing "query" at <sqlColumn (subQuery "post_types")>: error calling sqlColumn: Could not run query: SELECT post_type
-					// FROM wp_posts
-					// GROUP BY post_type
-					// ORDER BY post_type
-					// : Error 1146 (42S02): Table 'ttc_analytics.wp_posts' doesn't exist
-					// exit status 1
-					//
-					// Make better error messages:
-					return nil, errors.Wrapf(err, "Could not run query: %s", query)
-				}
-				defer func(rows *sqlx.Rows) {
-					_ = rows.Close()
-				}(rows)
-
-				ret := []interface{}{}
-
-				for rows.Next() {
-					ret_, err := rows.SliceScan()
-					if err != nil {
-						return nil, errors.Wrapf(err, "Could not scan query: %s", query)
-					}
-
-					row := make([]interface{}, len(ret_))
-					for i, v := range ret_ {
-						row[i] = sqlEltToTemplateValue(v)
-					}
-
-					ret = append(ret, row)
+					return nil, err
 				}
 
-				return ret, nil
+				return ret.([]interface{}), nil
 			},
 			"sqlColumn": func(query string, args ...interface{}) ([]interface{}, error) {
 				data, err := mergeQueryData(ps, args)
 				if err != nil {
 					return nil, err
 				}
-				renderedQuery, rows, err := RunQuery(ctx, subQueries, query, data, db)
+				ret, _, err := runSubQuery(ctx, "sqlColumn", subQueries, query, data, db,
+					func(renderedQuery string, rows *sqlx.Rows) (interface{}, error) {
+						ret := make([]interface{}, 0)
+						for rows.Next() {
+							rows_, err := rows.SliceScan()
+							if err != nil {
+								return nil, errors.Wrap(err, "Could not scan row")
+							}
+
+							if len(rows_) != 1 {
+								return nil, errors.Errorf("Expected 1 column, got %d", len(rows_))
+							}
+							elt := rows_[0]
+
+							v := sqlEltToTemplateValue(elt)
+
+							ret = append(ret, v)
+						}
+
+						return ret, nil
+					})
 				if err != nil {
-					return nil, errors.Wrapf(err, "Could not run query: %s", renderedQuery)
-				}
-				defer func(rows *sqlx.Rows) {
-					_ = rows.Close()
-				}(rows)
-
-				ret := make([]interface{}, 0)
-				for rows.Next() {
-					rows_, err := rows.SliceScan()
-					if err != nil {
-						return nil, errors.Wrapf(err, "Could not scan query: %s", renderedQuery)
-					}
-
-					if len(rows_) != 1 {
-						return nil, errors.Errorf("Expected 1 column, got %d", len(rows_))
-					}
-					elt := rows_[0]
-
-					v := sqlEltToTemplateValue(elt)
-
-					ret = append(ret, v)
+					return nil, err
 				}
 
-				return ret, nil
+				return ret.([]interface{}), nil
 			},
 			"sqlSingle": func(query string, args ...interface{}) (interface{}, error) {
 				data, err := mergeQueryData(ps, args)
 				if err != nil {
 					return nil, err
 				}
-				renderedQuery, rows, err := RunQuery(ctx, subQueries, query, data, db)
+				ret, _, err := runSubQuery(ctx, "sqlSingle", subQueries, query, data, db,
+					func(renderedQuery string, rows *sqlx.Rows) (interface{}, error) {
+						ret := make([]interface{}, 0)
+						if rows.Next() {
+							rows_, err := rows.SliceScan()
+							if err != nil {
+								return nil, errors.Wrap(err, "Could not scan row")
+							}
+
+							if len(rows_) != 1 {
+								return nil, errors.Errorf("Expected 1 column, got %d", len(rows_))
+							}
+
+							ret = append(ret, rows_[0])
+						}
+
+						if rows.Next() {
+							return nil, errors.Errorf("Expected 1 row, got more")
+						}
+
+						if len(ret) == 0 {
+							return nil, nil
+						}
+
+						if len(ret) > 1 {
+							return nil, errors.Errorf("Expected 1 row, got %d", len(ret))
+						}
+
+						return sqlEltToTemplateValue(ret[0]), nil
+					})
 				if err != nil {
-					return nil, errors.Wrapf(err, "Could not run query: %s", renderedQuery)
-				}
-				defer func(rows *sqlx.Rows) {
-					_ = rows.Close()
-				}(rows)
-
-				ret := make([]interface{}, 0)
-				if rows.Next() {
-					rows_, err := rows.SliceScan()
-					if err != nil {
-						return nil, errors.Wrapf(err, "Could not scan query: %s", renderedQuery)
-					}
-
-					if len(rows_) != 1 {
-						return nil, errors.Errorf("Expected 1 column, got %d", len(rows_))
-					}
-
-					ret = append(ret, rows_[0])
-				}
-
-				if rows.Next() {
-					return nil, errors.Errorf("Expected 1 row, got more")
-				}
-
-				if len(ret) == 0 {
-					return nil, nil
-				}
-
-				if len(ret) > 1 {
-					return nil, errors.Errorf("Expected 1 row, got %d", len(ret))
+					return nil, err
 				}
 
-				return sqlEltToTemplateValue(ret[0]), nil
+				return ret, nil
 			},
 			"sqlMap": func(query string, args ...interface{}) (interface{}, error) {
 				data, err := mergeQueryData(ps, args)
 				if err != nil {
 					return nil, err
 				}
-				renderedQuery, rows, err := RunQuery(ctx, subQueries, query, data, db)
+				ret, _, err := runSubQuery(ctx, "sqlMap", subQueries, query, data, db,
+					func(renderedQuery string, rows *sqlx.Rows) (interface{}, error) {
+						ret := []map[string]interface{}{}
+
+						for rows.Next() {
+							ret_ := make(map[string]interface{})
+							err := rows.MapScan(ret_)
+							if err != nil {
+								return nil, errors.Wrap(err, "Could not scan row")
+							}
+
+							row := make(map[string]interface{})
+							for k, v := range ret_ {
+								row[k] = sqlEltToTemplateValue(v)
+							}
+
+							ret = append(ret, row)
+						}
+
+						return ret, nil
+					})
 				if err != nil {
-					return nil, errors.Wrapf(err, "Could not run query: %s", renderedQuery)
-				}
-				defer func(rows *sqlx.Rows) {
-					_ = rows.Close()
-				}(rows)
-
-				ret := []map[string]interface{}{}
-
-				for rows.Next() {
-					ret_ := make(map[string]interface{})
-					err = rows.MapScan(ret_)
-					if err != nil {
-						return nil, errors.Wrapf(err, "Could not scan query: %s", renderedQuery)
-					}
-
-					row := make(map[string]interface{})
-					for k, v := range ret_ {
-						row[k] = sqlEltToTemplateValue(v)
-					}
-
-					ret = append(ret, row)
+					return nil, err
 				}
 
 				return ret, nil
 			},
 		})
 
-	return t2
+	if args != nil {
+		t2 = t2.Funcs(args.TemplateFuncsForDialect(dialect))
+	}
+
+	env := &TemplateEnv{Context: ctx, DB: db, Dialect: dialect, Data: ps, Args: args}
+	return t2.Funcs(templateFuncRegistryFromContext(ctx).Funcs(env))
 }
 
 func sqlEltToTemplateValue(elt interface{}) interface{} {
@@ -0,0 +1,99 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// WithQueryTimeout bounds the execution of the query, including reading its rows. The
+// connections opened by DatabaseConfig.Connect default to the query-timeout flag.
+func WithQueryTimeout(timeout time.Duration) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.timeout = timeout
+	}
+}
+
+// WithQueryName sets the name used to refer to the query in errors, usually the command name.
+func WithQueryName(name string) QueryResultOption {
+	return func(s *queryResultSettings) {
+		s.name = name
+	}
+}
+
+// QueryTimeoutError is returned when a query runs into the query timeout, either through
+// the context deadline or through the statement timeout of the database.
+type QueryTimeoutError struct {
+	Name    string
+	Timeout time.Duration
+	Elapsed time.Duration
+	Err     error
+}
+
+func (e *QueryTimeoutError) Error() string {
+	name := "query"
+	if e.Name != "" {
+		name = fmt.Sprintf("query %s", e.Name)
+	}
+	msg := fmt.Sprintf("%s timed out after %s", name, e.Elapsed.Round(time.Millisecond))
+	if e.Timeout > 0 {
+		msg += fmt.Sprintf(" (query-timeout %s)", e.Timeout)
+	}
+	return msg
+}
+
+func (e *QueryTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// withConnectionTimeout sets the timeout to the query timeout of db if it isn't set.
+func (s *queryResultSettings) withConnectionTimeout(db *sqlx.DB) *queryResultSettings {
+	if info := getConnectionInfo(db); info != nil && s.timeout <= 0 {
+		s.timeout = info.queryTimeout
+	}
+	return s
+}
+
+// withTimeout returns ctx with the query timeout applied.
+func (s *queryResultSettings) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// wrapTimeout turns err into a *QueryTimeoutError if it was caused by a timeout.
+func (s *queryResultSettings) wrapTimeout(ctx context.Context, err error, start time.Time) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || isStatementTimeoutError(err) {
+		return &QueryTimeoutError{
+			Name:    s.name,
+			Timeout: s.timeout,
+			Elapsed: time.Since(start),
+			Err:     err,
+		}
+	}
+	return err
+}
+
+// isStatementTimeoutError returns true for the errors of the driver-native statement timeouts.
+func isStatementTimeoutError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// query_canceled, raised for statement_timeout
+		return pgErr.Code == "57014"
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_QUERY_TIMEOUT, raised for max_execution_time
+		return mysqlErr.Number == 3024
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package sql
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+func TestStatementTimeoutConnectionString(t *testing.T) {
+	tests := []struct {
+		driver string
+		dsn    string
+		want   string
+	}{
+		{"pgx", "postgres://u@h/db", "postgres://u@h/db?statement_timeout=1500"},
+		{"pgx", "host=h dbname=db", "host=h dbname=db statement_timeout=1500"},
+		{"mysql", "u:p@tcp(h:3306)/db", "u:p@tcp(h:3306)/db?max_execution_time=1500"},
+		{"mysql", "u:p?w@tcp(h:3306)/db?parseTime=true", "u:p?w@tcp(h:3306)/db?parseTime=true&max_execution_time=1500"},
+		{"sqlite3", "test.db", "test.db"},
+	}
+	for _, tt := range tests {
+		got, err := statementTimeoutConnectionString(tt.driver, tt.dsn, 1500*time.Millisecond)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("statementTimeoutConnectionString(%s, %s) = %s, want %s", tt.driver, tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestIsStatementTimeoutError(t *testing.T) {
+	if !isStatementTimeoutError(errors.Wrap(&pgconn.PgError{Code: "57014"}, "query")) {
+		t.Errorf("expected postgres query_canceled to be a timeout")
+	}
+	if !isStatementTimeoutError(&mysql.MySQLError{Number: 3024}) {
+		t.Errorf("expected mysql ER_QUERY_TIMEOUT to be a timeout")
+	}
+	if isStatementTimeoutError(&pgconn.PgError{Code: "42P01"}) {
+		t.Errorf("expected undefined_table not to be a timeout")
+	}
+}
+
+func TestRunQueryIntoGlazeTimeout(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	query := `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n)
+SELECT COUNT(*) FROM n`
+	err = RunQueryIntoGlaze(context.Background(), db, query, nil, &rowCollector{},
+		WithQueryTimeout(50*time.Millisecond),
+		WithQueryName("count-forever"),
+	)
+
+	var timeoutErr *QueryTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a QueryTimeoutError, got %v", err)
+	}
+	if timeoutErr.Elapsed < 50*time.Millisecond {
+		t.Errorf("expected elapsed time of at least 50ms, got %s", timeoutErr.Elapsed)
+	}
+	if !strings.HasPrefix(err.Error(), "query count-forever timed out after ") {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestConnectAppliesQueryTimeout(t *testing.T) {
+	c := &DatabaseConfig{Type: "sqlite", Database: ":memory:", QueryTimeout: "50ms"}
+	db, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	query := `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n)
+SELECT COUNT(*) FROM n`
+	var timeoutErr *QueryTimeoutError
+
+	err = RunQueryIntoGlaze(context.Background(), db, query, nil, &rowCollector{})
+	if !errors.As(err, &timeoutErr) || timeoutErr.Timeout != 50*time.Millisecond {
+		t.Fatalf("expected a QueryTimeoutError with the query-timeout of the config, got %v", err)
+	}
+
+	_, _, err = RenderQuery(context.Background(), db, `SELECT {{ sqlSingle (subQuery "count") }}`,
+		map[string]string{"count": query}, nil)
+	if !errors.As(err, &timeoutErr) || timeoutErr.Name != "count" {
+		t.Fatalf("expected a QueryTimeoutError for the sub-query, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package yaml_editor
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -43,7 +44,12 @@ func (e *YAMLEditor) Save(filename string) error {
 		}
 	}()
 
-	encoder := yaml.NewEncoder(f)
+	return e.Encode(f)
+}
+
+// Encode writes the YAML content to w
+func (e *YAMLEditor) Encode(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
 	encoder.SetIndent(2)
 	if err := encoder.Encode(e.root); err != nil {
 		return fmt.Errorf("could not encode YAML: %w", err)
@@ -121,6 +127,32 @@ func (e *YAMLEditor) SetNode(node *yaml.Node, path ...string) error {
 	return nil
 }
 
+// DeleteNode removes the key at the given path from its parent mapping node
+func (e *YAMLEditor) DeleteNode(path ...string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("cannot delete the root node")
+	}
+
+	parent, err := e.GetNode(path[:len(path)-1]...)
+	if err != nil {
+		return err
+	}
+
+	if parent.Kind != yaml.MappingNode {
+		return fmt.Errorf("parent at path %v is not a mapping node", path[:len(path)-1])
+	}
+
+	lastKey := path[len(path)-1]
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == lastKey {
+			parent.Content = slices.Delete(parent.Content, i, i+2)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("key %s not found at path %v", lastKey, path)
+}
+
 // AppendToSequence appends a node to a sequence at the given path
 func (e *YAMLEditor) AppendToSequence(node *yaml.Node, path ...string) error {
 	target, err := e.GetNode(path...)